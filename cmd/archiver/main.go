@@ -0,0 +1,68 @@
+package main
+
+import (
+	// standard Packages
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	// internal Packages
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// read by the scheduler once the job has finished
+const terminationLog = "/dev/termination-log"
+
+func main() {
+	/*
+		Runs in the short-lived jobs volume-cleaner creates to archive a pvc before deleting it, and to
+		restore it afterwards. Either way the archive is only reached through ARCHIVE_URL, a presigned
+		url, so the archive keys never reach the namespace of the pvc:
+
+		  - ARCHIVE_SOURCE: the pvc is mounted read-only there and uploaded as a gzipped tarball
+		  - ARCHIVE_TARGET: the archive is extracted there, its checksum has to match ARCHIVE_SHA256
+	*/
+	log.Print("[INFO] Volume cleaner archiver started.")
+
+	source := os.Getenv("ARCHIVE_SOURCE")
+	target := os.Getenv("ARCHIVE_TARGET")
+	url := os.Getenv("ARCHIVE_URL")
+	if (source == "") == (target == "") || url == "" {
+		log.Fatal("[ERROR] ARCHIVE_URL and one of ARCHIVE_SOURCE or ARCHIVE_TARGET must be set.")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the job deadline bounds the transfer, the client adds none of its own
+	client := &http.Client{}
+
+	var result utilsInternal.ArchiveResult
+	var err error
+	if source != "" {
+		result, err = utilsInternal.UploadArchive(ctx, client, source, url)
+		if err != nil {
+			log.Fatalf("[ERROR] Failed to archive %s: %s", source, err)
+		}
+	} else {
+		result, err = utilsInternal.RestoreArchive(ctx, client, url, target, os.Getenv("ARCHIVE_SHA256"))
+		if err != nil {
+			log.Fatalf("[ERROR] Failed to restore %s: %s", target, err)
+		}
+	}
+
+	message, _ := json.Marshal(result)
+	if err := os.WriteFile(terminationLog, message, 0o644); err != nil {
+		log.Fatalf("[ERROR] Failed to report the archive: %s", err)
+	}
+
+	if source != "" {
+		log.Printf("[INFO] Archived %s: %d bytes, sha256 %s", source, result.Size, result.SHA256)
+	} else {
+		log.Printf("[INFO] Restored %s: %d bytes, sha256 %s", target, result.Size, result.SHA256)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	// internal packages
+	kubeInternal "volume-cleaner/internal/kubernetes"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// lists the entries of the audit log, oldest first
+
+func runAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	kubeconfig, configFile := commonFlags(fs)
+	auditFlags(fs)
+	name := fs.String("name", "", "only show entries of this PVC or PV")
+	action := fs.String("action", "", "only show entries of this action: mark, unmark, extend, delete or restore")
+	days := fs.Int("days", 0, "only show entries of the last days, all of them when 0")
+	asJSON := fs.Bool("json", false, "print every entry as a line of json, including the spec of the volume")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	src, err := loadSource(fs, *configFile)
+	if err != nil {
+		return err
+	}
+
+	cfg := utilsInternal.LoadAuditConfig(src)
+	if cfg.Namespace == "" {
+		return fmt.Errorf("the audit log is not stored in configmaps, set AUDIT_NAMESPACE or -audit-namespace")
+	}
+
+	kube, err := kubeInternal.InitKubeClientFromKubeconfig(*kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	entries, err := kubeInternal.ReadAudit(ctx, kube, cfg.Namespace)
+	if err != nil {
+		return err
+	}
+
+	since := time.Time{}
+	if *days > 0 {
+		since = time.Now().AddDate(0, 0, -*days)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if entry.Matches(src.Get("NAMESPACE"), *name, *action, since) {
+				if err := encoder.Encode(entry); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "TIME\tACTOR\tACTION\tKIND\tNAMESPACE\tNAME\tREASON")
+	for _, entry := range entries {
+		if entry.Matches(src.Get("NAMESPACE"), *name, *action, since) {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Format(time.RFC3339), entry.Actor, entry.Action, entry.Kind, entry.Namespace, entry.Name, entry.Reason)
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	// standard packages
+	"context"
+	"flag"
+	"os"
+	"os/user"
+
+	// external packages
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// maps the flags shared by the commands onto the config values they override
+var flagKeys = map[string]string{
+	"namespace":  "NAMESPACE",
+	"time-label": "TIME_LABEL",
+	"prices":     "PRICES",
+	"currency":   "CURRENCY",
+
+	"audit-namespace": "AUDIT_NAMESPACE",
+}
+
+// registers the flags shared by every command
+func commonFlags(fs *flag.FlagSet) (kubeconfig *string, configFile *string) {
+	kubeconfig = fs.String("kubeconfig", "", "path to the kubeconfig, defaults to $KUBECONFIG or ~/.kube/config")
+	configFile = fs.String("config", os.Getenv("CONFIG_FILE"), "path to a yaml/json config file, defaults to $CONFIG_FILE")
+	fs.String("namespace", "", "only look at this namespace (overrides NAMESPACE)")
+	fs.String("time-label", "", "label holding the unattached time (overrides TIME_LABEL)")
+	return kubeconfig, configFile
+}
+
+// returns the config values from the env vars and the config file, with the flags that were set on top
+
+func loadSource(fs *flag.FlagSet, configFile string) (utilsInternal.ConfigSource, error) {
+	src := utilsInternal.EnvSource()
+
+	if configFile != "" {
+		fileValues, err := utilsInternal.LoadConfigFile(configFile)
+		if err != nil {
+			return src, err
+		}
+		src.File = fileValues
+	}
+
+	overrides := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			overrides[key] = f.Value.String()
+		}
+	})
+
+	env := src.Env
+	src.Env = func(key string) (string, bool) {
+		if value, ok := overrides[key]; ok {
+			return value, true
+		}
+		return env(key)
+	}
+
+	return src, nil
+}
+
+// returns the time label, using the default of the manifests when none is configured
+func timeLabel(src utilsInternal.ConfigSource) string {
+	if label := src.Get("TIME_LABEL"); label != "" {
+		return label
+	}
+	return "volume-cleaner/unattached-time"
+}
+
+// registers the flag of the commands reading or writing the audit log
+func auditFlags(fs *flag.FlagSet) {
+	fs.String("audit-namespace", "", "namespace of the audit configmaps (overrides AUDIT_NAMESPACE)")
+}
+
+// returns the keys of the volume state, using the defaults of the manifests when none are configured
+func stateKeys(src utilsInternal.ConfigSource) structInternal.StateKeys {
+	keys := structInternal.StateKeys{
+		TimeLabel:       timeLabel(src),
+		NotifLabel:      src.Get("NOTIF_LABEL"),
+		StateAnnotation: utilsInternal.LoadStateAnnotation(src),
+		TimeFormat:      src.Get("TIME_FORMAT"),
+	}
+	if keys.NotifLabel == "" {
+		keys.NotifLabel = "volume-cleaner/notification-count"
+	}
+	if keys.TimeFormat == "" {
+		keys.TimeFormat = "2006-01-02_15-04-05Z"
+	}
+	return keys
+}
+
+// returns the actor of the audit entries written by the cli, the user of the kubeconfig when the
+// cluster tells who that is and the local user otherwise
+
+func cliActor(ctx context.Context, kube kubernetes.Interface) string {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	review, err := kube.AuthenticationV1().SelfSubjectReviews().Create(callCtx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err == nil && review.Status.UserInfo.Username != "" {
+		return structInternal.ActorCLIPrefix + review.Status.UserInfo.Username
+	}
+
+	if local, err := user.Current(); err == nil {
+		return structInternal.ActorCLIPrefix + local.Username
+	}
+	return structInternal.ActorCLIPrefix + "unknown"
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	// standard packages
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	// internal packages
+	kubeInternal "volume-cleaner/internal/kubernetes"
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// lists every unattached pvc with its monthly cost, followed by the total of each namespace
+
+func runCost(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cost", flag.ContinueOnError)
+	kubeconfig, configFile := commonFlags(fs)
+	fs.String("prices", "", "price per GiB-month of each storage class, e.g. \"standard=0.05, premium=0.15\" (overrides PRICES)")
+	fs.String("currency", "", "currency shown next to the costs (overrides CURRENCY)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	src, err := loadSource(fs, *configFile)
+	if err != nil {
+		return err
+	}
+
+	prices, err := utilsInternal.LoadPriceTable(src)
+	if err != nil {
+		return err
+	}
+	if !prices.IsSet() {
+		return fmt.Errorf("no prices configured, set PRICES or -prices")
+	}
+
+	kube, err := kubeInternal.InitKubeClientFromKubeconfig(*kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	label := timeLabel(src)
+	totals := make(map[string]float64)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "NAMESPACE\tPVC\tSTORAGE CLASS\tSIZE\tUNATTACHED SINCE\tMONTHLY COST")
+
+	pvcs, err := kubeInternal.PvcList(ctx, kube, src.Get("NAMESPACE"), kubeInternal.Selector{Label: label})
+	if err != nil {
+		return err
+	}
+
+	for _, pvc := range pvcs {
+		since, ok := pvc.Labels[label]
+		if !ok {
+			continue
+		}
+
+		size, storageClass := utilsInternal.VolumeDetails(ctx, kube, pvc)
+
+		cost := "-"
+		if amount, ok := prices.MonthlyCost(storageClass, size.Value()); ok {
+			cost = prices.Format(amount)
+			totals[pvc.Namespace] += amount
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\n", pvc.Namespace, pvc.Name, storageClass, size.String(), since, cost)
+	}
+
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	printTotals(prices, totals)
+	return nil
+}
+
+// prints the monthly cost of each namespace and of the whole cluster
+
+func printTotals(prices structInternal.PriceTable, totals map[string]float64) {
+	namespaces := make([]string, 0, len(totals))
+	for ns := range totals {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	fmt.Println()
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "NAMESPACE\tMONTHLY COST")
+
+	cluster := 0.0
+	for _, ns := range namespaces {
+		fmt.Fprintf(out, "%s\t%s\n", ns, prices.Format(totals[ns]))
+		cluster += totals[ns]
+	}
+	fmt.Fprintf(out, "TOTAL\t%s\n", prices.Format(cluster))
+
+	out.Flush()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	// standard packages
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	// internal packages
+	kubeInternal "volume-cleaner/internal/kubernetes"
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// adds days to the grace period of an unattached pvc, e.g. while its owner moves the data elsewhere
+
+func runExtend(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("extend", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: volume-cleaner extend -days <days> -reason <reason> [flags] <namespace>/<pvc>")
+		fs.PrintDefaults()
+	}
+	kubeconfig, configFile := commonFlags(fs)
+	auditFlags(fs)
+	days := fs.Int("days", 0, "days added to the grace period")
+	reason := fs.String("reason", "", "why the grace period is extended, kept in the state and the audit log")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ns, name, found := strings.Cut(fs.Arg(0), "/")
+	if fs.NArg() != 1 || !found || ns == "" || name == "" {
+		fs.Usage()
+		return fmt.Errorf("expected a single <namespace>/<pvc>")
+	}
+	if extension := (structInternal.Extension{Days: *days}); !extension.Valid() {
+		return fmt.Errorf("-days must be between 1 and %d days, got %d", structInternal.MaxExtensionDays, *days)
+	}
+	if *reason == "" {
+		return fmt.Errorf("-reason must be set")
+	}
+
+	src, err := loadSource(fs, *configFile)
+	if err != nil {
+		return err
+	}
+
+	auditCfg := utilsInternal.LoadAuditConfig(src)
+	if err := auditCfg.Validate(); err != nil {
+		return err
+	}
+
+	kube, err := kubeInternal.InitKubeClientFromKubeconfig(*kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	extension := structInternal.Extension{At: now.UTC().Truncate(time.Second), Days: *days, Reason: *reason}
+	pvc, err := kubeInternal.ExtendPvc(ctx, kube, stateKeys(src), ns, name, extension)
+	if err != nil {
+		return err
+	}
+
+	actor := cliActor(ctx, kube)
+	entry := kubeInternal.PvcAuditEntry(now, actor, structInternal.AuditExtend, pvc, fmt.Sprintf("extended by %d days: %s", *days, *reason))
+	if err := kubeInternal.RecordAudit(ctx, kube, auditCfg, entry); err != nil {
+		return fmt.Errorf("grace period extended, but not recorded in the audit log: %w", err)
+	}
+
+	fmt.Printf("Extended the grace period of PVC %s in NS %s by %d days\n", name, ns, *days)
+	return nil
+}
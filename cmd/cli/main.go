@@ -0,0 +1,64 @@
+package main
+
+import (
+	// standard packages
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// command line tool for cluster operators
+// it reads the same env vars and config file as the scheduler, flags override both
+
+const usage = `Usage: volume-cleaner <command> [flags]
+
+Commands:
+  cost     Show the monthly cost of every unattached PVC
+  extend   Add days to the grace period of an unattached PVC
+  restore  Recreate a deleted PVC from its archive
+  audit    Show the label changes, extensions, deletions and restores of the audit log
+
+Run "volume-cleaner <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// ctrl-c abandons the api calls in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+
+	switch os.Args[1] {
+	case "cost":
+		err = runCost(ctx, os.Args[2:])
+	case "extend":
+		err = runExtend(ctx, os.Args[2:])
+	case "restore":
+		err = runRestore(ctx, os.Args[2:])
+	case "audit":
+		err = runAudit(ctx, os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	// the flag package already printed the usage of the command
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	// internal packages
+	kubeInternal "volume-cleaner/internal/kubernetes"
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// recreates a deleted pvc from its archive, given the url recorded in its Archived event or the key
+// of the archive in the bucket
+
+func runRestore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: volume-cleaner restore [flags] <archive url or key>")
+		fs.PrintDefaults()
+	}
+	kubeconfig, configFile := commonFlags(fs)
+	auditFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected the url or key of a single archive")
+	}
+
+	src, err := loadSource(fs, *configFile)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := utilsInternal.LoadArchiveConfig(src)
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled() {
+		return fmt.Errorf("archiving is not configured, set ARCHIVE_ENDPOINT")
+	}
+	auditCfg := utilsInternal.LoadAuditConfig(src)
+	if err := errors.Join(cfg.Validate(), auditCfg.Validate()); err != nil {
+		return err
+	}
+
+	key, err := utilsInternal.ArchiveKeyFromReference(cfg, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// the manifest holds everything the pvc is recreated with
+	manifest, err := utilsInternal.PresignGet(cfg, utilsInternal.ManifestKey(key), time.Now(), time.Minute)
+	if err != nil {
+		return err
+	}
+	body, err := utilsInternal.GetObject(ctx, &http.Client{Timeout: 30 * time.Second}, manifest)
+	if err != nil {
+		return fmt.Errorf("failed to read the manifest of %s: %w", key, err)
+	}
+
+	var record structInternal.ArchiveRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return fmt.Errorf("invalid manifest of %s: %w", key, err)
+	}
+
+	kube, err := kubeInternal.InitKubeClientFromKubeconfig(*kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	pvc, err := kubeInternal.RestorePvc(ctx, kube, cfg, structInternal.RealClock{}, record)
+	if err != nil {
+		return err
+	}
+
+	entry := kubeInternal.PvcAuditEntry(time.Now(), cliActor(ctx, kube), structInternal.AuditRestore, *pvc, "restored from "+record.URL)
+	if err := kubeInternal.RecordAudit(ctx, kube, auditCfg, entry); err != nil {
+		return fmt.Errorf("PVC restored, but not recorded in the audit log: %w", err)
+	}
+
+	fmt.Printf("Restored PVC %s in NS %s from %s (%s, sha256 %s)\n", pvc.Name, pvc.Namespace, record.URL, utilsInternal.FormatBytes(record.Size), record.SHA256)
+	return nil
+}
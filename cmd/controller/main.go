@@ -5,6 +5,9 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	// internal Packages
 	kubeInternal "volume-cleaner/internal/kubernetes"
@@ -26,16 +29,28 @@ func main() {
 
 	log.Print("[INFO] Volume cleaner controller started.")
 
+	// cancelled when kubernetes stops the pod, the watchers return and events in progress are finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// controller config
 	// there is also a config for the scheduler
+	// values come from env vars, optionally layered over a mounted config file
+
+	src := utilsInternal.EnvSource()
 
-	cfg := structInternal.ControllerConfig{
-		Namespace:      os.Getenv("NAMESPACE"),
-		TimeLabel:      os.Getenv("TIME_LABEL"),
-		NotifLabel:     os.Getenv("NOTIF_LABEL"),
-		TimeFormat:     os.Getenv("TIME_FORMAT"),
-		StorageClasses: utilsInternal.ParseStrList(os.Getenv("STORAGE_CLASSES")),
-		ResetRun:       os.Getenv("RESET_RUN") == "true" || os.Getenv("RESET_RUN") == "1",
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile != "" {
+		fileValues, err := utilsInternal.LoadConfigFile(configFile)
+		if err != nil {
+			log.Fatalf("[ERROR] Invalid configuration:\n%s", err)
+		}
+		src.File = fileValues
+	}
+
+	cfg, err := utilsInternal.LoadControllerConfig(src)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid configuration:\n%s", err)
 	}
 
 	// init client to interact with k8s cluster
@@ -47,14 +62,124 @@ func main() {
 		log.Fatalf("[ERROR] Failed to create kube client: %s", err)
 	}
 
+	// config shared with the watcher, swapped when the config file changes
+	live := structInternal.NewLive(cfg)
+
+	// every loop below returns once ctx is cancelled
+	var wg sync.WaitGroup
+
+	// filled in by the initial scan and the watchers, served to the probes
+	health := structInternal.NewHealth(structInternal.RealClock{})
+
+	// started first so the liveness probe passes during a long initial scan
+	if cfg.HealthAddr != "" {
+		run(&wg, func() {
+			if err := kubeInternal.ServeHealth(ctx, cfg.HealthAddr, kubeClient, live, health); err != nil {
+				log.Fatalf("[ERROR] Failed to serve health endpoints: %s", err)
+			}
+		})
+	}
+
+	// warns about workloads using pvcs marked as unattached, answers before the initial scan as it
+	// reads the pvcs from the api server
+	if cfg.Webhook.Addr != "" {
+		run(&wg, func() {
+			if err := kubeInternal.ServeAdmission(ctx, cfg.Webhook, kubeClient, live); err != nil {
+				log.Fatalf("[ERROR] Failed to serve admission webhook: %s", err)
+			}
+		})
+	}
+
 	if cfg.ResetRun {
-		kubeInternal.ResetLabels(kubeClient, cfg)
+		if err := kubeInternal.ResetLabels(ctx, kubeClient, cfg); err != nil {
+			log.Fatalf("[ERROR] Failed to reset labels: %s", err)
+		}
 	}
 
 	// scans pvcs to find already unattached ones
 
-	kubeInternal.InitialScan(kubeClient, cfg)
+	if err := kubeInternal.InitialScan(ctx, kubeClient, cfg); err != nil {
+		// nothing can be done without the initial state so crash the program
+		log.Fatalf("[ERROR] Initial scan failed: %s", err)
+	}
+	health.SetSynced()
+
+	// protection rules are usually kept in a configmap of their own
+	protectionFile := src.Get("PROTECTION_FILE")
+
+	if configFile != "" || protectionFile != "" {
+		// how often the files are checked for changes
+		interval := cfg.ConfigReloadInterval
+
+		for _, path := range []string{configFile, protectionFile} {
+			if path == "" {
+				continue
+			}
+			run(&wg, func() {
+				utilsInternal.WatchConfigFile(ctx, path, interval, func() {
+					reloadConfig(configFile, live)
+				})
+			})
+		}
+	}
+
+	// periodically corrects labels the watchers missed
+	run(&wg, func() { kubeInternal.ReconcileLoop(ctx, kubeClient, live) })
+
+	// records when pvcs were last mounted so the scheduler can count from the last use
+	if cfg.UsageAnnotation != "" {
+		run(&wg, func() { kubeInternal.WatchPods(ctx, kubeClient, live, health) })
+		run(&wg, func() { kubeInternal.WatchVolumeAttachments(ctx, kubeClient, live, health) })
+	}
+
+	// watches pvcs to label standalone ones as soon as they are created
+	run(&wg, func() { kubeInternal.WatchPvc(ctx, kubeClient, live, health) })
 
 	// watches stateful sets to discover newly unattached pvcs
-	kubeInternal.WatchSts(context.TODO(), kubeClient, cfg)
+	run(&wg, func() { kubeInternal.WatchSts(ctx, kubeClient, live, health) })
+
+	<-ctx.Done()
+	log.Print("[INFO] Shutting down, waiting for events in progress...")
+	wg.Wait()
+	log.Print("[INFO] Volume cleaner controller stopped.")
+}
+
+// starts a long running loop in its own goroutine, tracked so shutdown can wait for it
+
+func run(wg *sync.WaitGroup, loop func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		loop()
+	}()
+}
+
+// reads the config file and the protection rules again and applies the values that can change at runtime
+// an invalid file is reported and the running config is kept
+
+func reloadConfig(configFile string, live *structInternal.Live[structInternal.ControllerConfig]) {
+	src := utilsInternal.EnvSource()
+
+	if configFile != "" {
+		fileValues, err := utilsInternal.LoadConfigFile(configFile)
+		if err != nil {
+			log.Printf("[ERROR] Ignoring config reload:\n%s", err)
+			return
+		}
+		src.File = fileValues
+	}
+
+	next, err := utilsInternal.LoadControllerConfig(src)
+	if err != nil {
+		log.Printf("[ERROR] Ignoring config reload:\n%s", err)
+		return
+	}
+
+	merged, skipped := utilsInternal.ReloadControllerConfig(live.Get(), next)
+	for _, key := range skipped {
+		log.Printf("[WARNING] %s cannot change while running, restart the controller to apply it.", key)
+	}
+
+	live.Set(merged)
+	log.Printf("[INFO] Config reloaded. Storage classes: %v, reconcile interval: %s, protection rules: %d", merged.StorageClasses, merged.ReconcileInterval, len(merged.Protection.Rules))
 }
@@ -2,46 +2,57 @@ package main
 
 import (
 	// standard Packages
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+
+	// embed the timezone database, the alpine image does not ship with one
+	_ "time/tzdata"
 
 	// internal Packages
 	kubeInternal "volume-cleaner/internal/kubernetes"
-	structInternal "volume-cleaner/internal/structure"
 	utilsInternal "volume-cleaner/internal/utils"
 )
 
 func main() {
 	log.Print("[INFO] Volume cleaner scheduler started.")
 
-	// Initialize an EmailConfig struct
-	emailCfg := structInternal.EmailConfig{
-		BaseURL:         os.Getenv("BASE_URL"),
-		Endpoint:        os.Getenv("ENDPOINT"),
-		EmailTemplateID: os.Getenv("EMAIL_TEMPLATE_ID"),
-		APIKey:          os.Getenv("API_KEY"),
+	// values come from env vars, optionally layered over a mounted config file
+	src := utilsInternal.EnvSource()
+
+	if configFile := os.Getenv("CONFIG_FILE"); configFile != "" {
+		fileValues, err := utilsInternal.LoadConfigFile(configFile)
+		if err != nil {
+			log.Fatalf("[ERROR] Invalid configuration:\n%s", err)
+		}
+		src.File = fileValues
 	}
 
 	// Scheduler struct which composes an EmailConfig
 	// there is also a config for the controller
-	cfg := structInternal.SchedulerConfig{
-		Namespace:   os.Getenv("NAMESPACE"),
-		TimeLabel:   os.Getenv("TIME_LABEL"),
-		NotifLabel:  os.Getenv("NOTIF_LABEL"),
-		IgnoreLabel: os.Getenv("IGNORE_LABEL"),
-		TimeFormat:  os.Getenv("TIME_FORMAT"),
-		GracePeriod: utilsInternal.ParseGracePeriod(os.Getenv("GRACE_PERIOD")),
-		DryRun:      os.Getenv("DRY_RUN") == "true" || os.Getenv("DRY_RUN") == "1",
-		NotifTimes:  utilsInternal.ParseNotifTimes(os.Getenv("NOTIF_TIMES")),
-		EmailCfg:    emailCfg,
+	cfg, err := utilsInternal.LoadSchedulerConfig(src)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid configuration:\n%s", err)
 	}
 
-	// init client to interact with k8s cluster
-	kubeClient, err := kubeInternal.InitKubeClient()
+	// init client to interact with k8s cluster, shared by the workers so the rate limit holds overall
+	kubeClient, err := kubeInternal.InitRateLimitedKubeClient(cfg.KubeQPS, utilsInternal.RateBurst(cfg.KubeQPS))
 	if err != nil {
 		log.Fatalf("[ERROR] Failed to create kube client: %s", err)
 	}
 
+	// a stopped pod or a run past its deadline starts no new volumes, volumes in progress are finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.RunTimeout)
+		defer cancel()
+	}
+
 	// run main scheduler logic
-	kubeInternal.FindStale(kubeClient, cfg)
+	kubeInternal.FindStale(ctx, kubeClient, cfg)
 }
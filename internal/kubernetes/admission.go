@@ -0,0 +1,282 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	// external packages
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+/*
+The controller only removes the state of a pvc once the workload using it exists, e.g. after the Added
+event of a stateful set. When WEBHOOK_ADDR is set, it also serves a validating admission webhook on
+/validate, called by the api server before pods, stateful sets, deployments, jobs and kubeflow notebooks
+are created or updated:
+
+  - every request is allowed, the webhook never blocks a workload
+  - each pvc the workload mounts that is marked as unattached is returned as a warning, shown by kubectl
+    and the kubeflow ui
+  - unless the request is a dry run, those pvcs get the admissionAnnotation. The scheduler does not delete
+    a pvc whose annotation is younger than admissionInFlightTTL, the workload is being created and the
+    controller has not seen it yet. The annotation is only written when it is missing or expired, so that
+    updates of a running workload do not change the pvc every time, and is removed with the state
+
+The controller only removes the state for stateful sets. A pvc used by any other kind stays marked,
+and after admissionInFlightTTL only the check for live workloads before a deletion keeps it (see safety.go)
+*/
+
+// set on a marked pvc when a workload using it is admitted
+const admissionAnnotation = "volume-cleaner/admission-in-flight"
+
+// how long after its admission a workload is expected to exist, long enough for the controller to
+// see it and remove the state of its pvcs
+const admissionInFlightTTL = 5 * time.Minute
+
+// value of the admission annotation
+
+type admissionInFlight struct {
+	// e.g. "statefulset/web"
+	Workload string    `json:"workload"`
+	At       time.Time `json:"at"`
+}
+
+// the fields of an admitted object that can hold volumes, decoded from any of the reviewed kinds
+
+type admittedObject struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		// pods
+		Volumes []corev1.Volume `json:"volumes"`
+
+		// stateful sets, deployments, jobs and notebooks
+		Template struct {
+			Spec corev1.PodSpec `json:"spec"`
+		} `json:"template"`
+
+		// stateful sets
+		VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volumeClaimTemplates"`
+	} `json:"spec"`
+}
+
+// returns true if the object mounts the claim or, for stateful sets, creates it from a template
+
+func (obj admittedObject) usesClaim(kind string, claim string) bool {
+	if mountsClaim(corev1.PodSpec{Volumes: obj.Spec.Volumes}, claim) || mountsClaim(obj.Spec.Template.Spec, claim) {
+		return true
+	}
+	return kind == "StatefulSet" && templateClaim(obj.Metadata.Name, obj.Spec.VolumeClaimTemplates, claim)
+}
+
+// serves the admission webhook over https on the configured address until ctx is cancelled
+
+func ServeAdmission(ctx context.Context, cfg structInternal.WebhookConfig, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig]) error {
+	certificate := &certificateFiles{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+	// fail at startup rather than on the first request
+	if _, err := certificate.get(nil); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           admissionHandler(kube, live),
+		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificate.get,
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[ERROR] Failed to stop admission webhook: %s", err)
+		}
+	}()
+
+	log.Printf("[INFO] Serving admission webhook on %s", cfg.Addr)
+
+	// the certificate comes from TLSConfig
+	err := server.ListenAndServeTLS("", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// routes the admission reviews, split from the server so it can be tested without a port or certificate
+
+func admissionHandler(kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig]) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /validate", func(w http.ResponseWriter, r *http.Request) {
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "expected an AdmissionReview with a request", http.StatusBadRequest)
+			return
+		}
+
+		response := &admissionv1.AdmissionResponse{
+			UID:      review.Request.UID,
+			Allowed:  true,
+			Warnings: reviewWorkload(r.Context(), kube, live.Get(), review.Request),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(admissionv1.AdmissionReview{
+			TypeMeta: review.TypeMeta,
+			Response: response,
+		})
+	})
+
+	return mux
+}
+
+// returns a warning for every pvc marked as unattached that the admitted workload uses, and marks
+// those pvcs as being admitted. failures are logged, the workload is allowed either way
+
+func reviewWorkload(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, request *admissionv1.AdmissionRequest) []string {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil
+	}
+	if cfg.Namespace != "" && request.Namespace != cfg.Namespace {
+		return nil
+	}
+
+	var obj admittedObject
+	if err := json.Unmarshal(request.Object.Raw, &obj); err != nil {
+		log.Printf("[ERROR] Failed to decode admitted %s %s in NS %s: %s", request.Kind.Kind, request.Name, request.Namespace, err)
+		return nil
+	}
+
+	// pods of deployments and jobs are created with a generated name
+	name := obj.Metadata.Name
+	if name == "" {
+		name = obj.Metadata.GenerateName
+	}
+	workload := strings.ToLower(request.Kind.Kind) + "/" + name
+
+	if !namespaceManaged(ctx, kube, cfg.Scope, request.Namespace) {
+		return nil
+	}
+
+	marked, err := PvcList(ctx, kube, request.Namespace, Selector{Label: cfg.TimeLabel})
+	if err != nil {
+		log.Printf("[ERROR] Failed to review %s in NS %s: %s", workload, request.Namespace, err)
+		return nil
+	}
+
+	dryRun := request.DryRun != nil && *request.DryRun
+	now := cfg.Clock.Now()
+
+	var warnings []string
+	for _, pvc := range marked {
+		if !obj.usesClaim(request.Kind.Kind, pvc.Name) {
+			continue
+		}
+
+		state, ok, _, err := cfg.StateKeys().Read(pvc.Labels, pvc.Annotations)
+		if err != nil || !ok {
+			continue
+		}
+
+		log.Printf("[INFO] %s in NS %s uses PVC %s, which is marked as unattached.", workload, request.Namespace, pvc.Name)
+		warnings = append(warnings, admissionWarning(pvc.Name, state))
+
+		// a workload admitted a moment ago already holds back the deletion
+		if _, inFlight := admittedWorkload(pvc, now); !dryRun && !inFlight {
+			// only strings and a time, marshalling cannot fail
+			value, _ := json.Marshal(admissionInFlight{Workload: workload, At: now.UTC()})
+			quoted, _ := json.Marshal(string(value))
+			patchPvcMetadata(ctx, kube, "annotations", admissionAnnotation, string(quoted), pvc.Namespace, pvc.Name)
+		}
+	}
+
+	return warnings
+}
+
+// returns the warning shown to the user creating a workload that uses a marked pvc
+// the grace period is only known once the scheduler recorded its policy
+
+func admissionWarning(claim string, state structInternal.VolumeState) string {
+	warning := fmt.Sprintf("PVC %s is marked for deletion by volume-cleaner, it has been unattached since %s", claim, state.DetachedAt.UTC().Format(time.DateOnly))
+	if state.Policy != nil {
+		warning += fmt.Sprintf(" and is deleted after %d days", state.Policy.GracePeriod+state.ExtensionDays())
+	}
+	return warning + ". It is not deleted while a workload uses it, but stays marked until it is mounted by a stateful set"
+}
+
+// returns the workload being admitted with the pvc, if it was admitted less than admissionInFlightTTL ago
+
+func admittedWorkload(pvc corev1.PersistentVolumeClaim, now time.Time) (string, bool) {
+	value, ok := pvc.Annotations[admissionAnnotation]
+	if !ok {
+		return "", false
+	}
+
+	var admission admissionInFlight
+	if err := json.Unmarshal([]byte(value), &admission); err != nil {
+		log.Printf("[ERROR] Ignoring invalid annotation %s of PVC %s in NS %s: %s", admissionAnnotation, pvc.Name, pvc.Namespace, err)
+		return "", false
+	}
+
+	return admission.Workload, now.Sub(admission.At) < admissionInFlightTTL
+}
+
+// the certificate served by the webhook, read again whenever the certificate file changes so that
+// rotated certificates are picked up without a restart
+
+type certificateFiles struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	modified    time.Time
+}
+
+func (c *certificateFiles) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.certificate != nil && info.ModTime().Equal(c.modified) {
+		return c.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		// keep serving the previous certificate while the files are being replaced
+		if c.certificate != nil {
+			log.Printf("[ERROR] Failed to reload webhook certificate: %s", err)
+			return c.certificate, nil
+		}
+		return nil, fmt.Errorf("failed to load webhook certificate: %w", err)
+	}
+
+	c.certificate = &certificate
+	c.modified = info.ModTime()
+	return c.certificate, nil
+}
@@ -0,0 +1,260 @@
+package kubernetes
+
+import (
+	// standard packages
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+// a managed namespace with the marked pvc "data" and the unmarked pvc "home"
+func admissionSetup(t *testing.T) (*testInternal.FakeClient, http.Handler, structInternal.ControllerConfig) {
+	kube := testInternal.NewFakeClient()
+
+	labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+	if namespaceErr := kube.CreateNamespace(context.TODO(), "ns1", labels); namespaceErr != nil {
+		t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+	}
+	for _, name := range []string{"data", "home"} {
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "ns1"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+	}
+
+	cfg := structInternal.ControllerConfig{
+		Scope:           structInternal.NamespaceScope{Selector: structInternal.DefaultNamespaceSelector},
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC)),
+	}
+
+	SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+		DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		Policy:     &structInternal.Policy{GracePeriod: 30},
+		Extensions: []structInternal.Extension{{Days: 7}},
+	}, getPvc(t, kube, "ns1", "data"))
+
+	return kube, admissionHandler(kube, structInternal.NewLive(cfg)), cfg
+}
+
+// sends an admission review of object to the handler and returns its response
+func review(t *testing.T, handler http.Handler, kind string, namespace string, object any, dryRun bool) *admissionv1.AdmissionResponse {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		t.Fatalf("Error encoding object: %v", err)
+	}
+
+	body, _ := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "705ab4f5-6393-11e8-b7cc-42010a800002",
+			Kind:      metav1.GroupVersionKind{Kind: kind},
+			Namespace: namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			DryRun:    ptr.To(dryRun),
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var reviewed admissionv1.AdmissionReview
+	if err := json.Unmarshal(recorder.Body.Bytes(), &reviewed); err != nil {
+		t.Fatalf("Error decoding review: %v", err)
+	}
+	assert.Equal(t, "admission.k8s.io/v1", reviewed.APIVersion)
+	assert.EqualValues(t, "705ab4f5-6393-11e8-b7cc-42010a800002", reviewed.Response.UID)
+	// workloads are never denied
+	assert.True(t, reviewed.Response.Allowed)
+	return reviewed.Response
+}
+
+// returns a pod template mounting the claims
+func claimsTemplate(claims ...string) corev1.PodTemplateSpec {
+	var volumes []corev1.Volume
+	for _, claim := range claims {
+		volumes = append(volumes, corev1.Volume{
+			Name:         claim,
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		})
+	}
+	return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Volumes: volumes}}
+}
+
+func TestAdmissionHandler(t *testing.T) {
+	t.Run("workloads using a marked pvc are warned", func(t *testing.T) {
+		kube, handler, _ := admissionSetup(t)
+		sts := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns1"},
+			Spec:       appsv1.StatefulSetSpec{Template: claimsTemplate("data", "home")},
+		}
+
+		response := review(t, handler, "StatefulSet", "ns1", sts, false)
+		assert.Equal(t, []string{
+			"PVC data is marked for deletion by volume-cleaner, it has been unattached since 2025-07-01 and is deleted after 37 days. It is not deleted while a workload uses it, but stays marked until it is mounted by a stateful set",
+		}, []string(response.Warnings))
+
+		workload, ok := admittedWorkload(getPvc(t, kube, "ns1", "data"), time.Date(2025, time.July, 15, 0, 4, 0, 0, time.UTC))
+		assert.True(t, ok)
+		assert.Equal(t, "statefulset/web", workload)
+		assert.NotContains(t, getPvc(t, kube, "ns1", "home").Annotations, admissionAnnotation)
+
+		// the annotation expires, the workload should exist by then
+		_, ok = admittedWorkload(getPvc(t, kube, "ns1", "data"), time.Date(2025, time.July, 15, 0, 5, 0, 0, time.UTC))
+		assert.False(t, ok)
+	})
+
+	t.Run("the annotation is only written when missing or expired", func(t *testing.T) {
+		kube, handler, cfg := admissionSetup(t)
+		clock := cfg.Clock.(*testInternal.FakeClock)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns1"}, Spec: claimsTemplate("data").Spec}
+
+		review(t, handler, "Pod", "ns1", pod, false)
+
+		// updates of the running workload leave the pvc as it is
+		clientset := kube.Interface.(*fake.Clientset)
+		clientset.ClearActions()
+		clock.Advance(time.Minute)
+		review(t, handler, "Pod", "ns1", pod, false)
+		for _, action := range clientset.Actions() {
+			assert.NotEqual(t, "patch", action.GetVerb())
+		}
+
+		clock.Advance(admissionInFlightTTL)
+		review(t, handler, "Pod", "ns1", pod, false)
+		_, ok := admittedWorkload(getPvc(t, kube, "ns1", "data"), clock.Now())
+		assert.True(t, ok)
+
+		// removed along with the state once the pvc is attached again
+		assert.True(t, clearPvcState(context.TODO(), kube, cfg, getPvc(t, kube, "ns1", "data"), "mounted by statefulset/web"))
+		assert.NotContains(t, getPvc(t, kube, "ns1", "data").Annotations, admissionAnnotation)
+	})
+
+	t.Run("dry runs are warned without marking the pvc", func(t *testing.T) {
+		kube, handler, _ := admissionSetup(t)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "web-", Namespace: "ns1"},
+			Spec:       claimsTemplate("data").Spec,
+		}
+
+		response := review(t, handler, "Pod", "ns1", pod, true)
+		assert.Len(t, response.Warnings, 1)
+		assert.NotContains(t, getPvc(t, kube, "ns1", "data").Annotations, admissionAnnotation)
+	})
+
+	t.Run("claims of volume claim templates and notebooks are checked", func(t *testing.T) {
+		kube, handler, cfg := admissionSetup(t)
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "workspace-web-0", "ns1"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+		// marked by the controller, not seen by the scheduler yet
+		SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+			DetachedAt: time.Date(2025, time.July, 10, 0, 0, 0, 0, time.UTC),
+		}, getPvc(t, kube, "ns1", "workspace-web-0"))
+
+		sts := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns1"},
+			Spec: appsv1.StatefulSetSpec{
+				VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "workspace"}}},
+			},
+		}
+		response := review(t, handler, "StatefulSet", "ns1", sts, false)
+		assert.Equal(t, []string{
+			"PVC workspace-web-0 is marked for deletion by volume-cleaner, it has been unattached since 2025-07-10. It is not deleted while a workload uses it, but stays marked until it is mounted by a stateful set",
+		}, []string(response.Warnings))
+
+		// kubeflow notebooks hold a pod template like the built-in workloads
+		notebook := &notebookObject{
+			APIVersion: "kubeflow.org/v1",
+			Kind:       "Notebook",
+			Metadata:   metav1.ObjectMeta{Name: "analysis", Namespace: "ns1"},
+			Spec:       appsv1.DeploymentSpec{Template: claimsTemplate("data")},
+		}
+		response = review(t, handler, "Notebook", "ns1", notebook, false)
+		assert.Len(t, response.Warnings, 1)
+
+		workload, _ := admittedWorkload(getPvc(t, kube, "ns1", "data"), cfg.Clock.Now())
+		assert.Equal(t, "notebook/analysis", workload)
+	})
+
+	t.Run("namespaces out of scope are not checked", func(t *testing.T) {
+		kube, handler, cfg := admissionSetup(t)
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "other", nil); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "data", "other"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+		SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+			DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		}, getPvc(t, kube, "other", "data"))
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other"}, Spec: claimsTemplate("data").Spec}
+		response := review(t, handler, "Pod", "other", pod, false)
+		assert.Empty(t, response.Warnings)
+		assert.NotContains(t, getPvc(t, kube, "other", "data").Annotations, admissionAnnotation)
+	})
+
+	t.Run("requests that are not reviews are rejected", func(t *testing.T) {
+		_, handler, _ := admissionSetup(t)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte(`{"kind": "AdmissionReview"}`))))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+// a kubeflow notebook, only the fields the webhook reads
+type notebookObject struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Metadata   metav1.ObjectMeta     `json:"metadata"`
+	Spec       appsv1.DeploymentSpec `json:"spec"`
+}
+
+func TestAdmittedPvcNotDeleted(t *testing.T) {
+	kube, cfg, _ := archiveSetup(t)
+	cfg.Archive = structInternal.ArchiveConfig{}
+
+	admit := func(at time.Time) {
+		value, _ := json.Marshal(admissionInFlight{Workload: "notebook/analysis", At: at})
+		quoted, _ := json.Marshal(string(value))
+		patchPvcMetadata(context.TODO(), kube, "annotations", admissionAnnotation, string(quoted), "test", "pvc1")
+	}
+
+	// admitted a minute ago, the controller has not seen the notebook yet
+	admit(cfg.Clock.Now().Add(-time.Minute))
+	report := FindStale(context.TODO(), kube, cfg)
+	assert.Equal(t, 0, report.Deleted)
+	assert.Equal(t, 0, report.Errors)
+	assert.Len(t, listPvcs(t, kube, "test"), 1)
+
+	// the notebook was never created
+	admit(cfg.Clock.Now().Add(-time.Hour))
+	report = FindStale(context.TODO(), kube, cfg)
+	assert.Equal(t, 1, report.Deleted)
+}
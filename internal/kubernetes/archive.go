@@ -0,0 +1,329 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	// external packages
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+/*
+When ARCHIVE_ENDPOINT is set, the contents of a stale pvc are uploaded to s3 compatible storage before
+it is deleted:
+
+  - the pvc is annotated with volume-cleaner/archiving, so the controller does not take the mount of
+    the archive job for usage and start the grace period over
+  - a job in the namespace of the pvc mounts it read-only and streams a gzipped tarball to a presigned
+    url (see cmd/archiver), the archive keys stay with the scheduler
+  - the job reports the size and sha256 of the upload in its termination message
+  - a manifest is stored next to the archive (<time>.json) with what the pvc is recreated with,
+    see RestorePvc
+  - the pvc is deleted once the upload succeeded and the volume was detached again
+
+A failed or timed out job keeps the pvc, it is archived again on the next run. Block volumes have
+no files to archive and are never deleted while archiving is on.
+*/
+
+// marks a pvc while it is being archived
+const archivingAnnotation = "volume-cleaner/archiving"
+
+// where the archive job mounts the pvc
+const archiveSource = "/data"
+
+// how often the archive job is checked, a var so tests do not wait
+var archivePollInterval = 5 * time.Second
+
+// longest the volume may stay attached once the archive job is done
+var archiveDetachTimeout = 5 * time.Minute
+
+// stores and reads the manifests, archives themselves never go through the scheduler
+var archiveClient = &http.Client{Timeout: 30 * time.Second}
+
+// uploads the contents of a pvc to the archive and returns where they were stored
+// the archiving annotation is left on the pvc, it is removed by the caller if the pvc is not deleted
+// ctx is the context of the run, nothing is started once it ends and the waits stop with it
+
+func archivePvc(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, pvc corev1.PersistentVolumeClaim) (structInternal.ArchiveRecord, error) {
+	if ctx.Err() != nil {
+		return structInternal.ArchiveRecord{}, fmt.Errorf("the run ended before the archive started: %w", context.Cause(ctx))
+	}
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		return structInternal.ArchiveRecord{}, errors.New("block volumes cannot be archived")
+	}
+
+	now := cfg.Clock.Now()
+	key := utilsInternal.ArchiveKey(pvc.Namespace, pvc.Name, now)
+
+	object, err := utilsInternal.ObjectURL(cfg.Archive, key)
+	if err != nil {
+		return structInternal.ArchiveRecord{}, err
+	}
+
+	// valid for as long as the job may run
+	upload, err := utilsInternal.PresignPut(cfg.Archive, key, now, cfg.Archive.Timeout)
+	if err != nil {
+		return structInternal.ArchiveRecord{}, err
+	}
+
+	patch := metadataPatch("annotations", archivingAnnotation, fmt.Sprintf("%q", now.UTC().Format(time.RFC3339)))
+	if err := patchPvc(ctx, kube, patch, pvc.Namespace, pvc.Name); err != nil {
+		return structInternal.ArchiveRecord{}, err
+	}
+
+	log.Printf("[INFO] Archiving PVC %s from NS %s to %s", pvc.Name, pvc.Namespace, object)
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	job, err := kube.BatchV1().Jobs(pvc.Namespace).Create(callCtx, archiveJob(cfg.Archive, pvc, upload), metav1.CreateOptions{})
+	if err != nil {
+		return structInternal.ArchiveRecord{}, fmt.Errorf("failed to create archive job: %w", err)
+	}
+
+	// the job is deleted even when the run ended while waiting for it
+	defer deleteArchiveJob(context.WithoutCancel(ctx), kube, job)
+
+	// the volume is only deleted once the node let go of it, whatever the outcome of the job
+	result, err := waitArchiveJob(ctx, kube, cfg.Archive.Timeout, job)
+	if detachErr := waitDetached(ctx, kube, pvc.Spec.VolumeName); err == nil {
+		err = detachErr
+	}
+	if err != nil {
+		return structInternal.ArchiveRecord{}, err
+	}
+
+	size, storageClass := utilsInternal.VolumeDetails(ctx, kube, pvc)
+	record := structInternal.ArchiveRecord{
+		Namespace:    pvc.Namespace,
+		Name:         pvc.Name,
+		URL:          object.String(),
+		SHA256:       result.SHA256,
+		Size:         result.Size,
+		StorageClass: storageClass,
+		Capacity:     size.String(),
+		ArchivedAt:   now.UTC(),
+	}
+	for _, mode := range pvc.Spec.AccessModes {
+		record.AccessModes = append(record.AccessModes, string(mode))
+	}
+
+	// an archive without its manifest cannot be restored, so the pvc is kept
+	if err := putManifest(ctx, cfg.Archive, now, key, record); err != nil {
+		return structInternal.ArchiveRecord{}, err
+	}
+
+	return record, nil
+}
+
+// stores the record of an archive next to it
+
+func putManifest(ctx context.Context, cfg structInternal.ArchiveConfig, now time.Time, key string, record structInternal.ArchiveRecord) error {
+	manifest, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	upload, err := utilsInternal.PresignPut(cfg, utilsInternal.ManifestKey(key), now, cfg.Timeout)
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	if err := utilsInternal.PutObject(callCtx, archiveClient, upload, manifest, "application/json"); err != nil {
+		return fmt.Errorf("failed to store archive manifest: %w", err)
+	}
+	return nil
+}
+
+// returns the job uploading the pvc to the presigned url
+
+func archiveJob(cfg structInternal.ArchiveConfig, pvc corev1.PersistentVolumeClaim, upload string) *batchv1.Job {
+	job := archiverJob(cfg, pvc, true, []corev1.EnvVar{
+		{Name: "ARCHIVE_SOURCE", Value: archiveSource},
+		{Name: "ARCHIVE_URL", Value: upload},
+	})
+	job.GenerateName = "volume-cleaner-archive-"
+	job.Annotations = map[string]string{archivingAnnotation: pvc.Name}
+	return job
+}
+
+// returns a job running the archiver with the pvc mounted at archiveSource
+
+func archiverJob(cfg structInternal.ArchiveConfig, pvc corev1.PersistentVolumeClaim, readOnly bool, env []corev1.EnvVar) *batchv1.Job {
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "volume-cleaner-archiver",
+		"app.kubernetes.io/managed-by": "volume-cleaner",
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pvc.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			// a failed transfer is retried from the start, along with the checks before it
+			BackoffLimit:          ptr.To[int32](0),
+			ActiveDeadlineSeconds: ptr.To(int64(cfg.Timeout.Seconds())),
+
+			// removed by kubernetes if volume-cleaner stops before cleaning up
+			TTLSecondsAfterFinished: ptr.To[int32](3600),
+
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+					// a sidecar would keep the job running after the transfer
+					Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:         "archiver",
+						Image:        cfg.Image,
+						Command:      []string{"/volume-cleaner-archiver"},
+						Env:          env,
+						VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: archiveSource, ReadOnly: readOnly}},
+						// the error of a failed transfer is reported from the logs
+						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "data",
+						VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: pvc.Name,
+							ReadOnly:  readOnly,
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// waits for an archive or restore job to finish and returns the archive it reported
+// the job deadline ends it after timeout, the extra minute leaves kubernetes time to report it
+
+func waitArchiveJob(ctx context.Context, kube kubernetes.Interface, timeout time.Duration, job *batchv1.Job) (utilsInternal.ArchiveResult, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout+time.Minute)
+	defer cancel()
+
+	ticker := time.NewTicker(archivePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return utilsInternal.ArchiveResult{}, fmt.Errorf("job %s did not finish before the run ended: %w", job.Name, context.Cause(ctx))
+			}
+			return utilsInternal.ArchiveResult{}, fmt.Errorf("job %s did not finish within %s", job.Name, timeout)
+		case <-ticker.C:
+		}
+
+		callCtx, cancel := utilsInternal.CallContext(waitCtx)
+		latest, err := kube.BatchV1().Jobs(job.Namespace).Get(callCtx, job.Name, metav1.GetOptions{})
+		cancel()
+		if err != nil {
+			log.Printf("[ERROR] Failed to read job %s: %s", job.Name, err)
+			continue
+		}
+
+		for _, condition := range latest.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				message, err := terminationMessage(ctx, kube, job)
+				if err != nil {
+					return utilsInternal.ArchiveResult{}, err
+				}
+				var result utilsInternal.ArchiveResult
+				if err := json.Unmarshal([]byte(message), &result); err != nil || result.SHA256 == "" {
+					return utilsInternal.ArchiveResult{}, fmt.Errorf("job %s reported %q", job.Name, message)
+				}
+				return result, nil
+			case batchv1.JobFailed:
+				// the message holds the end of the logs of the failed transfer
+				message, _ := terminationMessage(ctx, kube, job)
+				return utilsInternal.ArchiveResult{}, fmt.Errorf("job %s failed: %s: %s", job.Name, condition.Reason, message)
+			}
+		}
+	}
+}
+
+// returns the termination message of the pod of a finished job
+
+func terminationMessage(ctx context.Context, kube kubernetes.Interface, job *batchv1.Job) (string, error) {
+	pods, err := PodList(ctx, kube, job.Namespace, Selector{Label: "job-name=" + job.Name})
+	if err != nil {
+		return "", err
+	}
+
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil {
+				return status.State.Terminated.Message, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no finished pod found for job %s", job.Name)
+}
+
+// waits until the pv is no longer attached to a node, at most archiveDetachTimeout or until ctx is cancelled
+
+func waitDetached(ctx context.Context, kube kubernetes.Interface, pv string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, archiveDetachTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(archivePollInterval)
+	defer ticker.Stop()
+
+	for {
+		attached, err := volumeAttached(waitCtx, kube, pv)
+		if err == nil && attached == "" {
+			return nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return fmt.Errorf("PV %s is still attached after archiving: %w", pv, ctx.Err())
+			}
+			return errors.Join(fmt.Errorf("PV %s is still attached after archiving", pv), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// deletes an archive or restore job along with its pod
+
+func deleteArchiveJob(ctx context.Context, kube kubernetes.Interface, job *batchv1.Job) {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	err := kube.BatchV1().Jobs(job.Namespace).Delete(callCtx, job.Name, metav1.DeleteOptions{
+		PropagationPolicy: ptr.To(metav1.DeletePropagationBackground),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete job %s: %s", job.Name, err)
+	}
+}
+
+// removes the archiving annotation from a pvc that was not deleted
+
+func endArchiving(ctx context.Context, kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim) {
+	patchPvc(ctx, kube, metadataPatch("annotations", archivingAnnotation, "null"), pvc.Namespace, pvc.Name)
+}
@@ -0,0 +1,341 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+// returns a fake client with a stale pvc, a scheduler config archiving pvcs and the store they go to
+func archiveSetup(t *testing.T) (*testInternal.FakeClient, structInternal.SchedulerConfig, *testInternal.FakeObjectStore) {
+	kube := testInternal.NewFakeClient()
+
+	labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+	if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+		t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "test"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: ptr.To("standard"),
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	if _, pvcErr := kube.CoreV1().PersistentVolumeClaims("test").Create(context.TODO(), pvc, metav1.CreateOptions{}); pvcErr != nil {
+		t.Fatalf("Error injecting pvc add: %v", pvcErr)
+	}
+
+	cfg := structInternal.SchedulerConfig{
+		Namespace:       "test",
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		GracePeriod:     10,
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC)),
+		Archive: structInternal.ArchiveConfig{
+			Endpoint:  "http://minio.das:9000", // replaced by the fake store below
+			Bucket:    "volume-archives",
+			Region:    "us-east-1",
+			AccessKey: "Random ACCESSKEY",
+			SecretKey: "Random SECRETKEY",
+			Image:     "volume-cleaner-scheduler:latest",
+			Timeout:   time.Hour,
+		},
+	}
+
+	SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+		DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+	}, getPvc(t, kube, "test", "pvc1"))
+
+	// the jobs do not upload anything, only the manifests reach the store
+	store := testInternal.NewFakeObjectStore(cfg.Archive)
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+	cfg.Archive.Endpoint = server.URL
+
+	// jobs are checked without waiting
+	interval := archivePollInterval
+	archivePollInterval = time.Millisecond
+	t.Cleanup(func() { archivePollInterval = interval })
+
+	return kube, cfg, store
+}
+
+// runs every archive job created to the end, as the job controller and kubelet would
+// the pod of the job reports message and the job ends with the given condition
+func finishArchiveJobs(kube *testInternal.FakeClient, condition batchv1.JobConditionType, message string) *[]batchv1.Job {
+	created := &[]batchv1.Job{}
+	clientset := kube.Interface.(*fake.Clientset)
+
+	clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+
+		// names are generated by the api server
+		job.Name = job.GenerateName + "x7k2p"
+		job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue, Reason: "Finished"}}
+		*created = append(*created, *job.DeepCopy())
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: job.Name + "-abcde", Namespace: job.Namespace, Labels: map[string]string{"job-name": job.Name}},
+			Spec:       job.Spec.Template.Spec,
+			Status: corev1.PodStatus{
+				Phase: corev1.PodSucceeded,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "archiver",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+				}},
+			},
+		}
+		if condition == batchv1.JobFailed {
+			pod.Status.Phase = corev1.PodFailed
+		}
+		if err := clientset.Tracker().Add(pod); err != nil {
+			return true, nil, err
+		}
+
+		// stored by the default reactor
+		return false, nil, nil
+	})
+
+	return created
+}
+
+// returns the events recorded in the namespace
+func listEvents(t *testing.T, kube *testInternal.FakeClient) []corev1.Event {
+	events, err := kube.CoreV1().Events("test").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing events: %v", err)
+	}
+	return events.Items
+}
+
+func TestFindStaleArchive(t *testing.T) {
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	t.Run("pvc is deleted after its upload", func(t *testing.T) {
+		kube, cfg, store := archiveSetup(t)
+		created := finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "`+checksum+`"}`)
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 1, report.Deleted)
+		assert.Equal(t, 0, report.Errors)
+		assert.Empty(t, listPvcs(t, kube, "test"))
+
+		url := cfg.Archive.Endpoint + "/volume-archives/test/pvc1/20250715T000000Z.tar.gz"
+		record := structInternal.ArchiveRecord{
+			Namespace:    "test",
+			Name:         "pvc1",
+			URL:          url,
+			SHA256:       checksum,
+			Size:         2048,
+			StorageClass: "standard",
+			Capacity:     "1Gi",
+			AccessModes:  []string{"ReadWriteOnce"},
+			ArchivedAt:   time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC),
+		}
+		assert.Equal(t, []structInternal.ArchiveRecord{record}, report.Archived)
+
+		// the manifest the pvc is restored from
+		manifest, ok := store.Object("/volume-archives/test/pvc1/20250715T000000Z.json")
+		if assert.True(t, ok) {
+			var stored structInternal.ArchiveRecord
+			assert.NoError(t, json.Unmarshal(manifest, &stored))
+			assert.Equal(t, record, stored)
+		}
+
+		// the job mounts the pvc read-only and only gets a presigned url
+		if assert.Len(t, *created, 1) {
+			spec := (*created)[0].Spec.Template.Spec
+			assert.True(t, spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+			assert.Equal(t, "pvc1", spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+			assert.True(t, spec.Containers[0].VolumeMounts[0].ReadOnly)
+
+			env := spec.Containers[0].Env
+			assert.Equal(t, "ARCHIVE_URL", env[1].Name)
+			assert.Contains(t, env[1].Value, url+"?")
+			assert.Contains(t, env[1].Value, "X-Amz-Signature=")
+			assert.NotContains(t, env[1].Value, "SECRETKEY")
+		}
+
+		// the job is cleaned up
+		jobs, _ := JobList(context.TODO(), kube, "test")
+		assert.Empty(t, jobs)
+
+		events := listEvents(t, kube)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, corev1.EventTypeNormal, events[0].Type)
+			assert.Equal(t, "Archived", events[0].Reason)
+			assert.Contains(t, events[0].Message, url)
+			assert.Contains(t, events[0].Message, checksum)
+		}
+	})
+
+	t.Run("pvc is kept when the upload fails", func(t *testing.T) {
+		kube, cfg, _ := archiveSetup(t)
+		finishArchiveJobs(kube, batchv1.JobFailed, "failed to upload archive: 403 Forbidden")
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 1, report.Errors)
+		assert.Empty(t, report.Archived)
+
+		// usage is tracked again
+		pvc := getPvc(t, kube, "test", "pvc1")
+		assert.NotContains(t, pvc.Annotations, archivingAnnotation)
+
+		events := listEvents(t, kube)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, corev1.EventTypeWarning, events[0].Type)
+			assert.Equal(t, "ArchiveFailed", events[0].Reason)
+			assert.Contains(t, events[0].Message, "403 Forbidden")
+		}
+	})
+
+	t.Run("pvc is kept when the job reports no checksum", func(t *testing.T) {
+		kube, cfg, _ := archiveSetup(t)
+		finishArchiveJobs(kube, batchv1.JobComplete, "")
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 1, report.Errors)
+		assert.Len(t, listPvcs(t, kube, "test"), 1)
+	})
+
+	t.Run("pvc is kept when its manifest cannot be stored", func(t *testing.T) {
+		kube, cfg, _ := archiveSetup(t)
+		cfg.Archive.SecretKey = "Wrong SECRETKEY"
+		finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "`+checksum+`"}`)
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 1, report.Errors)
+		assert.Len(t, listPvcs(t, kube, "test"), 1)
+	})
+
+	t.Run("the archive ends with the run", func(t *testing.T) {
+		kube, cfg, _ := archiveSetup(t)
+
+		// the job is created but never finishes within its hour
+		jobs := 0
+		kube.Interface.(*fake.Clientset).PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			jobs++
+			action.(k8stesting.CreateAction).GetObject().(*batchv1.Job).Name = "volume-cleaner-archive-x7k2p"
+			return false, nil, nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		started := time.Now()
+		report := FindStale(ctx, kube, cfg)
+		assert.Less(t, time.Since(started), 10*time.Second)
+
+		assert.Equal(t, 1, jobs)
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 1, report.Errors)
+		assert.Empty(t, report.Archived)
+
+		// the pvc is kept and tracked again, its job is cleaned up
+		pvc := getPvc(t, kube, "test", "pvc1")
+		assert.NotContains(t, pvc.Annotations, archivingAnnotation)
+		left, _ := JobList(context.TODO(), kube, "test")
+		assert.Empty(t, left)
+
+		events := listEvents(t, kube)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, "ArchiveFailed", events[0].Reason)
+			assert.Contains(t, events[0].Message, "did not finish before the run ended")
+		}
+	})
+
+	t.Run("block volumes are not archived", func(t *testing.T) {
+		kube, cfg, _ := archiveSetup(t)
+		created := finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "`+checksum+`"}`)
+
+		pvc := getPvc(t, kube, "test", "pvc1")
+		pvc.Spec.VolumeMode = ptr.To(corev1.PersistentVolumeBlock)
+		if _, err := kube.CoreV1().PersistentVolumeClaims("test").Update(context.TODO(), &pvc, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Error updating pvc: %v", err)
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 1, report.Errors)
+		assert.Empty(t, *created)
+	})
+
+	t.Run("other storage classes are deleted without an archive", func(t *testing.T) {
+		kube, cfg, _ := archiveSetup(t)
+		cfg.Archive.StorageClasses = []string{"managed-premium"}
+		created := finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "`+checksum+`"}`)
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 1, report.Deleted)
+		assert.Empty(t, report.Archived)
+		assert.Empty(t, *created)
+	})
+}
+
+func TestWaitDetached(t *testing.T) {
+	kube, _, _ := archiveSetup(t)
+
+	attachment := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: "csi-1234"},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To("pv1")},
+		},
+		Status: storagev1.VolumeAttachmentStatus{Attached: true},
+	}
+	if _, err := kube.StorageV1().VolumeAttachments().Create(context.TODO(), attachment, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Error injecting volume attachment add: %v", err)
+	}
+
+	t.Run("the wait ends with the run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, waitDetached(ctx, kube, "pv1"), context.Canceled)
+	})
+
+	t.Run("the wait ends after the timeout", func(t *testing.T) {
+		timeout := archiveDetachTimeout
+		archiveDetachTimeout = 50 * time.Millisecond
+		t.Cleanup(func() { archiveDetachTimeout = timeout })
+
+		err := waitDetached(context.Background(), kube, "pv1")
+		assert.ErrorContains(t, err, "PV pv1 is still attached")
+		assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("detached volumes do not wait", func(t *testing.T) {
+		assert.NoError(t, waitDetached(context.Background(), kube, "pv2"))
+	})
+}
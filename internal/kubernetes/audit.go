@@ -0,0 +1,272 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	// external packages
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+/*
+The label changes and deletions of the controller and the scheduler, and the extensions and restores
+made with the cli, are recorded as audit entries:
+
+  - AUDIT_NAMESPACE: entries are appended to configmaps named volume-cleaner-audit-<yyyy-mm>, one
+    key per entry (<time>-<action>-<uid>). A month that outgrows its configmap continues in
+    volume-cleaner-audit-<yyyy-mm>-2 and so on
+  - AUDIT_WEBHOOK_URL: every entry is posted as json
+
+Entries are added with merge patches that only add their own key, so the controller and the
+scheduler never conflict and nothing that was written is changed. An entry that cannot be written is
+logged in full instead, the action it records is not undone.
+*/
+
+const auditConfigMapPrefix = "volume-cleaner-audit-"
+
+// month of the entries in an audit configmap, e.g. 2025-07
+const auditMonthLabel = "volume-cleaner/audit-month"
+
+// configmaps hold at most 1MiB, the margin covers entries written concurrently by the workers
+// and the other binary after the size was read
+var auditShardLimit = 900 * 1024
+
+var auditClient = &http.Client{Timeout: 10 * time.Second}
+
+// entries that could not be recorded since they were last reported, so that a broken sink shows in
+// the run and drift reports and not only in the logs
+var auditFailures atomic.Int64
+
+// returns the number of entries that could not be recorded since the last call
+func takeAuditFailures() int {
+	return int(auditFailures.Swap(0))
+}
+
+// the configmap each month is written to, so full ones are not read again for every entry
+// the lock only guards the map, entries are written concurrently
+var auditShards = struct {
+	sync.Mutex
+	parts map[string]int
+}{parts: make(map[string]int)}
+
+// returns the configmap entries of a month are written to, starting with the first
+func auditShardPart(month string) int {
+	auditShards.Lock()
+	defer auditShards.Unlock()
+	return max(auditShards.parts[month], 1)
+}
+
+// records the configmap an entry of a month was written to, a writer that is behind never
+// moves the month back to a full configmap
+func setAuditShardPart(month string, part int) {
+	auditShards.Lock()
+	defer auditShards.Unlock()
+	auditShards.parts[month] = max(auditShards.parts[month], part)
+}
+
+// writes an entry to every configured sink, returning the errors of those that failed
+
+func RecordAudit(ctx context.Context, kube kubernetes.Interface, cfg structInternal.AuditConfig, entry structInternal.AuditEntry) error {
+	var errs []error
+
+	if cfg.Namespace != "" {
+		errs = append(errs, appendAudit(ctx, kube, cfg.Namespace, entry))
+	}
+
+	if cfg.WebhookURL != "" {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		errs = append(errs, utilsInternal.SendAuditEntry(callCtx, auditClient, cfg.WebhookURL, entry))
+		cancel()
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		auditFailures.Add(1)
+		encoded, _ := json.Marshal(entry)
+		log.Printf("[ERROR] Failed to record audit entry %s: %s", encoded, err)
+	}
+	return err
+}
+
+// adds an entry to the configmap of its month
+
+func appendAudit(ctx context.Context, kube kubernetes.Interface, namespace string, entry structInternal.AuditEntry) error {
+	month := entry.Time.UTC().Format("2006-01")
+	key := auditKey(entry)
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	part := auditShardPart(month)
+	for {
+		name := auditShardName(month, part)
+
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		shard, err := kube.CoreV1().ConfigMaps(namespace).Get(callCtx, name, metav1.GetOptions{})
+		cancel()
+
+		switch {
+		case k8serrors.IsNotFound(err):
+			err = createAuditShard(ctx, kube, namespace, name, month, key, string(value))
+			if k8serrors.IsAlreadyExists(err) {
+				// another worker or the other binary created it first
+				continue
+			}
+		case err != nil:
+			return fmt.Errorf("failed to read audit configmap %s: %w", name, err)
+		case auditShardSize(shard)+len(key)+len(value) > auditShardLimit:
+			part++
+			continue
+		default:
+			err = patchAuditShard(ctx, kube, namespace, name, key, string(value))
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write audit configmap %s: %w", name, err)
+		}
+		setAuditShardPart(month, part)
+		return nil
+	}
+}
+
+// returns a key sorting the entries of a configmap by time
+func auditKey(entry structInternal.AuditEntry) string {
+	id := string(entry.UID)
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	if id == "" {
+		id = entry.Name
+	}
+	return fmt.Sprintf("%s-%s-%s", entry.Time.UTC().Format("20060102T150405.000000000Z"), entry.Action, id)
+}
+
+func auditShardName(month string, part int) string {
+	if part == 1 {
+		return auditConfigMapPrefix + month
+	}
+	return fmt.Sprintf("%s%s-%d", auditConfigMapPrefix, month, part)
+}
+
+func auditShardSize(shard *corev1.ConfigMap) int {
+	size := 0
+	for key, value := range shard.Data {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+func createAuditShard(ctx context.Context, kube kubernetes.Interface, namespace string, name string, month string, key string, value string) error {
+	shard := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "volume-cleaner",
+				auditMonthLabel:                month,
+			},
+		},
+		Data: map[string]string{key: value},
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	_, err := kube.CoreV1().ConfigMaps(namespace).Create(callCtx, shard, metav1.CreateOptions{})
+	return err
+}
+
+func patchAuditShard(ctx context.Context, kube kubernetes.Interface, namespace string, name string, key string, value string) error {
+	// only strings, marshalling cannot fail
+	patch, _ := json.Marshal(map[string]any{"data": map[string]string{key: value}})
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	_, err := kube.CoreV1().ConfigMaps(namespace).Patch(callCtx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// returns every entry of the audit configmaps in namespace, oldest first
+// entries that cannot be read are reported and skipped
+
+func ReadAudit(ctx context.Context, kube kubernetes.Interface, namespace string) ([]structInternal.AuditEntry, error) {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	shards, err := kube.CoreV1().ConfigMaps(namespace).List(callCtx, metav1.ListOptions{LabelSelector: auditMonthLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit configmaps in NS %s: %w", namespace, err)
+	}
+
+	var entries []structInternal.AuditEntry
+	for _, shard := range shards.Items {
+		// keys start with the time of their entry
+		keys := make([]string, 0, len(shard.Data))
+		for key := range shard.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			var entry structInternal.AuditEntry
+			if err := json.Unmarshal([]byte(shard.Data[key]), &entry); err != nil {
+				log.Printf("[ERROR] Skipping audit entry %s of %s: %s", key, shard.Name, err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// returns the audit entry of an action on a pvc
+
+func PvcAuditEntry(now time.Time, actor string, action string, pvc corev1.PersistentVolumeClaim, reason string) structInternal.AuditEntry {
+	// only plain values, marshalling cannot fail
+	spec, _ := json.Marshal(pvc.Spec)
+	return structInternal.AuditEntry{
+		Time:      now.UTC(),
+		Actor:     actor,
+		Action:    action,
+		Kind:      "PersistentVolumeClaim",
+		Namespace: pvc.Namespace,
+		Name:      pvc.Name,
+		UID:       string(pvc.UID),
+		Reason:    reason,
+		Spec:      spec,
+	}
+}
+
+// returns the audit entry of an action on a pv
+
+func pvAuditEntry(now time.Time, actor string, action string, pv corev1.PersistentVolume, reason string) structInternal.AuditEntry {
+	spec, _ := json.Marshal(pv.Spec)
+	return structInternal.AuditEntry{
+		Time:   now.UTC(),
+		Actor:  actor,
+		Action: action,
+		Kind:   "PersistentVolume",
+		Name:   pv.Name,
+		UID:    string(pv.UID),
+		Reason: reason,
+		Spec:   spec,
+	}
+}
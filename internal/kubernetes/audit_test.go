@@ -0,0 +1,289 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+// returns the audit configmaps of the das namespace
+func listAuditShards(t *testing.T, kube *testInternal.FakeClient) []corev1.ConfigMap {
+	shards, err := kube.CoreV1().ConfigMaps("das").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing configmaps: %v", err)
+	}
+	return shards.Items
+}
+
+// starts every test with an empty shard cache, the months of the tests overlap
+func resetAuditShards(t *testing.T) {
+	auditShards.Lock()
+	auditShards.parts = make(map[string]int)
+	auditShards.Unlock()
+}
+
+func TestRecordAudit(t *testing.T) {
+	cfg := structInternal.AuditConfig{Namespace: "das"}
+	pvc := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "test", UID: "3f2a9c1d-0000-4000-8000-000000000000"}}
+
+	t.Run("entries are appended to the configmap of their month", func(t *testing.T) {
+		resetAuditShards(t)
+		kube := testInternal.NewFakeClient()
+
+		first := PvcAuditEntry(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC), structInternal.ActorController, structInternal.AuditMark, pvc, "detached from statefulset/web")
+		second := PvcAuditEntry(time.Date(2025, time.July, 31, 0, 0, 0, 0, time.UTC), structInternal.ActorScheduler, structInternal.AuditDelete, pvc, "unused")
+		third := PvcAuditEntry(time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC), "cli:jane", structInternal.AuditRestore, pvc, "restored")
+
+		// written out of order, read back in order
+		for _, entry := range []structInternal.AuditEntry{second, first, third} {
+			assert.NoError(t, RecordAudit(context.TODO(), kube, cfg, entry))
+		}
+
+		shards := listAuditShards(t, kube)
+		if assert.Len(t, shards, 2) {
+			assert.Equal(t, "volume-cleaner-audit-2025-07", shards[0].Name)
+			assert.Len(t, shards[0].Data, 2)
+			assert.Contains(t, shards[0].Data, "20250701T000000.000000000Z-mark-3f2a9c1d")
+			assert.Equal(t, "volume-cleaner-audit-2025-08", shards[1].Name)
+			assert.Equal(t, "2025-08", shards[1].Labels[auditMonthLabel])
+		}
+
+		entries, err := ReadAudit(context.TODO(), kube, "das")
+		assert.NoError(t, err)
+		assert.Equal(t, []structInternal.AuditEntry{first, second, third}, entries)
+	})
+
+	t.Run("a full configmap continues in the next one", func(t *testing.T) {
+		resetAuditShards(t)
+		kube := testInternal.NewFakeClient()
+
+		limit := auditShardLimit
+		auditShardLimit = 800
+		t.Cleanup(func() { auditShardLimit = limit })
+
+		for day := 1; day <= 5; day++ {
+			entry := PvcAuditEntry(time.Date(2025, time.July, day, 0, 0, 0, 0, time.UTC), structInternal.ActorController, structInternal.AuditMark, pvc, "not attached to any workload")
+			assert.NoError(t, RecordAudit(context.TODO(), kube, cfg, entry))
+		}
+
+		shards := listAuditShards(t, kube)
+		assert.Greater(t, len(shards), 1)
+		for _, shard := range shards {
+			assert.LessOrEqual(t, auditShardSize(&shard), auditShardLimit)
+		}
+
+		entries, err := ReadAudit(context.TODO(), kube, "das")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 5)
+	})
+
+	t.Run("the api calls are made without holding the shard cache", func(t *testing.T) {
+		resetAuditShards(t)
+		kube := testInternal.NewFakeClient()
+
+		// a worker waiting on the api server would hold back every other entry
+		held := false
+		kube.Interface.(*fake.Clientset).PrependReactor("*", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+			if auditShards.TryLock() {
+				auditShards.Unlock()
+			} else {
+				held = true
+			}
+			return false, nil, nil
+		})
+
+		for day := 1; day <= 2; day++ {
+			entry := PvcAuditEntry(time.Date(2025, time.July, day, 0, 0, 0, 0, time.UTC), structInternal.ActorController, structInternal.AuditMark, pvc, "not attached to any workload")
+			assert.NoError(t, RecordAudit(context.TODO(), kube, cfg, entry))
+		}
+
+		assert.False(t, held)
+		assert.Len(t, listAuditShards(t, kube), 1)
+	})
+
+	t.Run("entries are posted to the webhook", func(t *testing.T) {
+		var mu sync.Mutex
+		var received []structInternal.AuditEntry
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var entry structInternal.AuditEntry
+			if err := json.Unmarshal(body, &entry); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			received = append(received, entry)
+			mu.Unlock()
+		}))
+		defer server.Close()
+
+		kube := testInternal.NewFakeClient()
+		entry := PvcAuditEntry(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC), structInternal.ActorScheduler, structInternal.AuditDelete, pvc, "unused")
+
+		assert.NoError(t, RecordAudit(context.TODO(), kube, structInternal.AuditConfig{WebhookURL: server.URL}, entry))
+		assert.Equal(t, []structInternal.AuditEntry{entry}, received)
+
+		// the configmaps are only written when a namespace is set
+		assert.Empty(t, listAuditShards(t, kube))
+	})
+
+	t.Run("a failing webhook is reported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		entry := PvcAuditEntry(time.Now(), structInternal.ActorScheduler, structInternal.AuditDelete, pvc, "unused")
+		err := RecordAudit(context.TODO(), testInternal.NewFakeClient(), structInternal.AuditConfig{WebhookURL: server.URL}, entry)
+		assert.ErrorContains(t, err, "503")
+	})
+}
+
+func TestAuditedActions(t *testing.T) {
+	t.Run("deletions of the scheduler", func(t *testing.T) {
+		resetAuditShards(t)
+		kube, cfg, _ := archiveSetup(t)
+		cfg.Archive = structInternal.ArchiveConfig{}
+		cfg.Audit = structInternal.AuditConfig{Namespace: "das"}
+
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Deleted)
+
+		entries, err := ReadAudit(context.TODO(), kube, "das")
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, structInternal.ActorScheduler, entries[0].Actor)
+			assert.Equal(t, structInternal.AuditDelete, entries[0].Action)
+			assert.Equal(t, "test", entries[0].Namespace)
+			assert.Equal(t, "pvc1", entries[0].Name)
+			assert.Equal(t, "unused since 2025-07-01T00:00:00Z, the grace period of 10 days ended", entries[0].Reason)
+			assert.JSONEq(t, `{"storageClassName": "standard", "accessModes": ["ReadWriteOnce"], "resources": {"requests": {"storage": "1Gi"}}}`, string(entries[0].Spec))
+		}
+	})
+
+	t.Run("notices of the scheduler and broken sinks", func(t *testing.T) {
+		resetAuditShards(t)
+		takeAuditFailures()
+		kube, cfg, _ := archiveSetup(t)
+		cfg.Archive = structInternal.ArchiveConfig{}
+
+		emails := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer emails.Close()
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer webhook.Close()
+
+		cfg.Audit = structInternal.AuditConfig{Namespace: "das", WebhookURL: webhook.URL}
+		cfg.EmailCfg = structInternal.EmailConfig{BaseURL: emails.URL, Endpoint: "/v2/notifications/email", EmailTemplateID: "template", APIKey: "key"}
+		cfg.NotifTimes = []int{7}
+		// six days before the deletion
+		cfg.Clock = testInternal.NewFakeClock(time.Date(2025, time.July, 5, 0, 0, 0, 0, time.UTC))
+
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Emailed)
+		// written to the configmap but not to the webhook
+		assert.Equal(t, 1, report.AuditErrors)
+
+		entries, err := ReadAudit(context.TODO(), kube, "das")
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, structInternal.AuditUpdate, entries[0].Action)
+			assert.Equal(t, "notice 1 of 1 sent by email", entries[0].Reason)
+		}
+
+		// the controller reports the failures of its watchers with the next reconciliation
+		controllerCfg := structInternal.ControllerConfig{
+			// the reconciliation itself changes nothing
+			Namespace:       "other",
+			TimeLabel:       cfg.TimeLabel,
+			NotifLabel:      cfg.NotifLabel,
+			StateAnnotation: cfg.StateAnnotation,
+			TimeFormat:      cfg.TimeFormat,
+			Audit:           structInternal.AuditConfig{WebhookURL: webhook.URL},
+			Clock:           cfg.Clock,
+		}
+		attachClaim(context.TODO(), kube, controllerCfg, "test", "pvc1", "web")
+		assert.Equal(t, 1, Reconcile(context.TODO(), kube, controllerCfg).AuditErrors)
+	})
+
+	t.Run("label changes of the controller", func(t *testing.T) {
+		resetAuditShards(t)
+		kube := testInternal.NewFakeClient()
+		if _, err := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); err != nil {
+			t.Fatalf("Error injecting pvc add: %v", err)
+		}
+
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC))
+		cfg := structInternal.ControllerConfig{
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Audit:           structInternal.AuditConfig{Namespace: "das"},
+			Clock:           clock,
+		}
+
+		detachClaim(context.TODO(), kube, cfg, "test", "pvc1", "web")
+		clock.Advance(time.Hour)
+		attachClaim(context.TODO(), kube, cfg, "test", "pvc1", "web")
+		// nothing to remove a second time
+		attachClaim(context.TODO(), kube, cfg, "test", "pvc1", "web")
+
+		entries, err := ReadAudit(context.TODO(), kube, "das")
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 2) {
+			assert.Equal(t, structInternal.AuditMark, entries[0].Action)
+			assert.Equal(t, "detached from statefulset/web", entries[0].Reason)
+			assert.Equal(t, structInternal.AuditUnmark, entries[1].Action)
+			assert.Equal(t, "attached to statefulset/web", entries[1].Reason)
+			assert.Equal(t, structInternal.ActorController, entries[1].Actor)
+		}
+	})
+}
+
+func TestExtendPvc(t *testing.T) {
+	kube, cfg, _ := archiveSetup(t)
+	extension := structInternal.Extension{At: time.Date(2025, time.July, 10, 0, 0, 0, 0, time.UTC), Days: 14, Reason: "thesis data"}
+
+	pvc, err := ExtendPvc(context.TODO(), kube, cfg.StateKeys(), "test", "pvc1", extension)
+	assert.NoError(t, err)
+	assert.Equal(t, "pvc1", pvc.Name)
+
+	state, ok, _, err := cfg.StateKeys().Read(getPvc(t, kube, "test", "pvc1").Labels, getPvc(t, kube, "test", "pvc1").Annotations)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []structInternal.Extension{extension}, state.Extensions)
+
+	// the grace period of 10 days now ends on July 25th
+	cfg.Archive = structInternal.ArchiveConfig{}
+	report := FindStale(context.TODO(), kube, cfg)
+	assert.Equal(t, 0, report.Deleted)
+
+	// pvcs in use have no grace period
+	if _, err := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc2", "test"); err != nil {
+		t.Fatalf("Error injecting pvc add: %v", err)
+	}
+	_, err = ExtendPvc(context.TODO(), kube, cfg.StateKeys(), "test", "pvc2", extension)
+	assert.ErrorContains(t, err, "not marked as unattached")
+}
@@ -3,9 +3,14 @@ package kubernetes
 import (
 	// standard packages
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"net/http"
-	"strconv"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	/* Unfortunate that a lot of the kubernetes packages require renaming because
@@ -13,6 +18,9 @@ import (
 	*/
 
 	// external packages
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -23,159 +31,613 @@ import (
 
 // main scheduler logic to find stale pvcs, send emails and delete them
 
-func FindStale(kube kubernetes.Interface, cfg structInternal.SchedulerConfig) (int, int) {
-	// One http client is created for emailing users
-	client := &http.Client{Timeout: 10 * time.Second}
+func FindStale(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig) structInternal.RunReport {
+	// One http client is created for emailing users, shared by the workers so the rate limit holds overall
+	client := utilsInternal.NewRateLimitedClient(10*time.Second, cfg.EmailQPS)
 
-	errCount := 0
-	deleteCount := 0
-	emailCount := 0
+	report := structInternal.NewRunReport()
 
-	log.Print("[INFO] Scanning for stale PVCS...")
+	// deletions and final warnings are held back on weekends and holidays
+	// so they never land when nobody is around to read them
+	businessDay := cfg.Calendar.IsBusinessDay(cfg.Clock.Now())
+	if !businessDay {
+		log.Print("[INFO] Today is not a business day. Deletions and final warnings are deferred.")
+	}
 
-	// iterate through all pvcs in configured namespace(s)
+	log.Print("[INFO] Scanning for stale PVCS...")
 
-	for _, pvc := range PvcList(kube, cfg.Namespace) {
-		log.Printf("[INFO] Found PVC %s from NS %s", pvc.Name, pvc.Namespace)
+	// exactly the namespaces the controller manages, labels left behind anywhere else are never acted upon
+	managed, err := managedNamespaces(ctx, kube, cfg.Scope, cfg.Namespace)
+	if err != nil {
+		// an empty list would look like a cluster without stale pvcs
+		log.Printf("[ERROR] Skipping stale PVCs: %s", err)
+		report.Errors++
+	}
 
-		// check if label exists (meaning pvc is unattached)
-		// if pvc is attached to a sts, it would've had its label removed by the controller
+	// sorted so the summaries read the same between runs
+	namespaces := slices.Sorted(maps.Keys(managed))
 
-		timestamp, ok := pvc.Labels[cfg.TimeLabel]
-		if !ok {
-			log.Printf("[INFO] Label %s not found. Skipping.", cfg.TimeLabel)
-			continue
+	pvcs := make([]corev1.PersistentVolumeClaim, 0)
+	for _, namespace := range namespaces {
+		if ctx.Err() != nil {
+			break
 		}
 
-		ignore, ok := pvc.Labels[cfg.IgnoreLabel]
-		if ok && ignore == "true" {
-			log.Printf("[INFO][IGNORE] Label %s found. Skipping.", cfg.IgnoreLabel)
-			continue
-		}
+		log.Printf("[INFO] Scanning NS %s...", namespace)
 
-		// check if pvc should be deleted
-		stale, staleError := IsStale(timestamp, cfg.TimeFormat, cfg.GracePeriod)
-		if staleError != nil {
-			log.Printf("[ERROR] Failed to parse timestamp: %s", staleError)
-			errCount++
+		// only labelled pvcs are unattached, so the api server is asked for those alone
+		found, err := PvcList(ctx, kube, namespace, Selector{Label: cfg.TimeLabel})
+		if err != nil {
+			log.Printf("[ERROR] Skipping NS %s: %s", namespace, err)
+			report.Errors++
+			report.Namespaces[namespace] = structInternal.NamespaceSummary{Errors: 1}
 			continue
 		}
 
-		// stale means grace period has passed, can be deleted
-		if stale {
-			if cfg.DryRun {
-				log.Printf("[DRY RUN] Delete PVC %s", pvc.Name)
-				deleteCount++
-				continue
-			}
+		report.Namespaces[namespace] = structInternal.NamespaceSummary{Scanned: len(found)}
+		pvcs = append(pvcs, found...)
+	}
+
+	// a pvc that was started is carried through to the end even if the run is stopped,
+	// so a deletion is never separated from its labels. each call still has its own timeout,
+	// only the archive waits, which can last much longer, end with the run
+	work := context.WithoutCancel(ctx)
+
+	// each pvc is processed into its own report, merged here one at a time
+	report.Skipped += processConcurrently(ctx, cfg.Concurrency, pvcs, func(pvc corev1.PersistentVolumeClaim) structInternal.RunReport {
+		return processPvc(work, ctx, kube, cfg, client, businessDay, pvc)
+	}, func(pvc corev1.PersistentVolumeClaim, result structInternal.RunReport) {
+		report.Merge(result)
+
+		summary := report.Namespaces[pvc.Namespace]
+		summary.Errors += result.Errors
+		summary.Deleted += result.Deleted
+		summary.Emailed += result.Emailed
+		report.Namespaces[pvc.Namespace] = summary
+	})
+
+	for _, namespace := range namespaces {
+		summary := report.Namespaces[namespace]
+		log.Printf("[INFO] NS %s: PVCs scanned: %d, emails sent: %d, PVCs deleted: %d, errors: %d",
+			namespace, summary.Scanned, summary.Emailed, summary.Deleted, summary.Errors)
+	}
+
+	if cfg.SweepVolumes && ctx.Err() == nil {
+		sweepVolumes(ctx, kube, cfg, client, businessDay, &report)
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("[INFO] Run stopped early: %s. Volumes left for the next run: %d", context.Cause(ctx), report.Skipped)
+	}
+
+	log.Printf("[INFO] Job errors: %d", report.Errors)
+	log.Printf("[INFO] Emails sent: %d", report.Emailed)
+	log.Printf("[INFO] Pvcs deleted: %d", report.Deleted)
+	if cfg.Archive.Enabled() {
+		log.Printf("[INFO] Pvcs archived: %d", len(report.Archived))
+	}
+	if cfg.SweepVolumes {
+		log.Printf("[INFO] Pvs deleted: %d", report.VolumesDeleted)
+	}
+	for _, protected := range report.Protected {
+		log.Printf("[INFO] PVC %s in NS %s: protected by rule %s", protected.Name, protected.Namespace, protected.Rule)
+	}
+
+	logCapacity(report)
+
+	// entries of this run only, the scheduler runs once per process
+	report.AuditErrors = takeAuditFailures()
+	if report.AuditErrors > 0 {
+		log.Printf("[ERROR] Audit entries not recorded: %d", report.AuditErrors)
+	}
+
+	if cfg.Prices.IsSet() {
+		log.Printf("[INFO] Monthly savings: %s", cfg.Prices.Format(report.MonthlySavings(cfg.Prices)))
+		log.Printf("[INFO] Monthly cost pending reclamation: %s", cfg.Prices.Format(report.PendingMonthlyCost(cfg.Prices)))
+	}
+
+	return report
+
+}
+
+// runs a labelled pvc through the grace period and returns the results as a report of its own
+// called from several workers at once, so it must not touch shared state. run is cancelled when the
+// run ends, an archive is not started after that and stops waiting for its job
+
+func processPvc(ctx context.Context, run context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, client *http.Client, businessDay bool, pvc corev1.PersistentVolumeClaim) structInternal.RunReport {
+	report := structInternal.NewRunReport()
+
+	log.Printf("[INFO] Found PVC %s from NS %s", pvc.Name, pvc.Namespace)
+
+	// check if the state exists (meaning pvc is unattached)
+	// if pvc is attached to a sts, it would've had its state removed by the controller
+
+	keys := cfg.StateKeys()
+	state, ok, legacy, err := keys.Read(pvc.Labels, pvc.Annotations)
+	if err != nil {
+		log.Printf("[ERROR] %s", err)
+		report.Errors++
+		return report
+	}
+	if !ok {
+		log.Printf("[INFO] Label %s not found. Skipping.", cfg.TimeLabel)
+		return report
+	}
+
+	ignore, ok := pvc.Labels[cfg.IgnoreLabel]
+	if ok && ignore == "true" {
+		log.Printf("[INFO][IGNORE] Label %s found. Skipping.", cfg.IgnoreLabel)
+		return report
+	}
 
-			err := kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(context.TODO(), pvc.Name, metav1.DeleteOptions{})
+	// labelled before the rule existed, or by a controller that does not have the rule yet
+	if rule, ok := cfg.Protection.Protects(pvc.ObjectMeta); ok {
+		log.Printf("[INFO][PROTECTED] Protected by rule %s. Skipping.", rule)
+		report.Protected = append(report.Protected, structInternal.ProtectedVolume{Namespace: pvc.Namespace, Name: pvc.Name, Rule: rule})
+		return report
+	}
+
+	since, err := gracePeriodStart(cfg, state, pvc.Annotations)
+	if err != nil {
+		log.Printf("[ERROR] Failed to parse timestamp: %s", err)
+		report.Errors++
+		return report
+	}
+
+	size, storageClass := utilsInternal.VolumeDetails(ctx, kube, pvc)
+
+	deleted := processVolume(ctx, cfg, client, businessDay, &report, staleVolume{
+		kind:         "PVC",
+		name:         pvc.Name,
+		namespace:    pvc.Namespace,
+		state:        state,
+		legacy:       legacy,
+		since:        since,
+		size:         size,
+		storageClass: storageClass,
+		remove: func(state structInternal.VolumeState) (err error) {
+			latest, err := recheckPvc(ctx, kube, cfg, pvc, state)
 			if err != nil {
-				log.Printf("[ERROR] Failed to delete PVC %s: %s", pvc.Name, err)
-				errCount++
-				continue
+				return err
 			}
+			reason := deletionReason(cfg, since, state)
 
-			log.Print("[INFO] PVC successfully deleted.")
-			deleteCount++
+			// the pvc is only deleted once its contents are stored, see archive.go
+			if cfg.Archive.Archives(storageClass) {
+				defer func() {
+					if err != nil {
+						endArchiving(ctx, kube, pvc)
+					}
+				}()
 
-		} else {
-			// not stale yet, handle email logic here
+				record, err := archivePvc(run, kube, cfg, *latest)
+				if err != nil {
+					recordEvent(ctx, kube, cfg.Clock, pvcReference(pvc), corev1.EventTypeWarning, "ArchiveFailed",
+						fmt.Sprintf("Not deleted by volume-cleaner, the archive failed: %s", err))
+					return fmt.Errorf("failed to archive: %w", err)
+				}
 
-			log.Print("[INFO] Grace period not passed.")
+				log.Printf("[INFO] Archived PVC %s to %s: %s, sha256 %s", pvc.Name, record.URL, utilsInternal.FormatBytes(record.Size), record.SHA256)
+				recordEvent(ctx, kube, cfg.Clock, pvcReference(pvc), corev1.EventTypeNormal, "Archived",
+					fmt.Sprintf("Archived to %s (%d bytes, sha256 %s)", record.URL, record.Size, record.SHA256))
+				report.Archived = append(report.Archived, record)
+				reason += fmt.Sprintf(", archived to %s (sha256 %s)", record.URL, record.SHA256)
 
-			notifCount, ok := pvc.Labels[cfg.NotifLabel]
-			if !ok {
-				log.Printf("[INFO] Label %s not found. Skipping.", cfg.NotifLabel)
-				errCount++
-				continue
+				// the archive may have taken a while
+				if latest, err = recheckPvc(ctx, kube, cfg, pvc, state); err != nil {
+					return err
+				}
 			}
 
-			currNotif, countErr := strconv.Atoi(notifCount)
-			if countErr != nil {
-				log.Printf("[ERROR] Failed to parse notification count: %v", countErr)
-				errCount++
-				continue
+			// a retained pv is handed over to the sweeper as already notified,
+			// so the owner is not warned a second time about the same data
+			if cfg.SweepVolumes {
+				handOverVolume(ctx, kube, cfg, *latest, state)
 			}
 
-			if len(cfg.NotifTimes) == 0 {
-				continue
+			// the pvc is only deleted as it was checked above
+			callCtx, cancel := utilsInternal.CallContext(ctx)
+			defer cancel()
+			err = kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(callCtx, pvc.Name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{UID: &latest.UID, ResourceVersion: &latest.ResourceVersion},
+			})
+			if apierrors.IsConflict(err) {
+				return fmt.Errorf("%w: %s", errVolumeChanged, err)
 			}
-
-			shouldSend, daysLeft, mailError := ShouldSendMail(timestamp, currNotif, cfg)
-			if mailError != nil {
-				log.Printf("[ERROR] Failed to parse timestamp: %s", mailError)
-				errCount++
-				continue
+			if err != nil {
+				return err
 			}
 
-			if shouldSend {
-				if cfg.DryRun {
-					log.Print("[DRY RUN] Email owner.")
-					emailCount++
-					continue
+			RecordAudit(ctx, kube, cfg.Audit, PvcAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditDelete, *latest, reason))
+			return nil
+		},
+		warn: func(reason string, message string) {
+			recordEvent(ctx, kube, cfg.Clock, pvcReference(pvc), corev1.EventTypeWarning, reason, message)
+		},
+		save: func(reason string, change func(state *structInternal.VolumeState)) {
+			saved := false
+			err := retryPvc(ctx, kube, pvc, func(latest corev1.PersistentVolumeClaim) error {
+				saved = false
+				next, ok := changedState(keys, state, false, latest.Labels, latest.Annotations, change)
+				if !ok {
+					log.Printf("[INFO] State of PVC %s changed since it was read, not recording this run.", latest.Name)
+					return nil
 				}
+				pvc = latest
+				saved = true
+				return SetPvcState(ctx, kube, keys, next, latest)
+			})
+			if saved && err == nil {
+				RecordAudit(ctx, kube, cfg.Audit, PvcAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditUpdate, pvc, reason))
+			}
+		},
+		details: func(detachedAt time.Time) (string, structInternal.Personalisation) {
+			return utilsInternal.EmailDetails(ctx, kube, pvc, detachedAt, cfg)
+		},
+	})
+	if deleted {
+		report.Deleted++
+	}
 
-				// personal consists of details passed into the email template as variables while email is
-				// the email address that is consistent regardless of the template
+	return report
+}
 
-				email, personal := utilsInternal.EmailDetails(kube, pvc, daysLeft)
+// returns what the state written at the end of processVolume records, as shown in the audit log
 
-				err := utilsInternal.SendNotif(client, cfg.EmailCfg, email, personal)
-				if err != nil {
-					log.Printf("[Error] Unable to send an email to %s at %s: %s", personal.Name, email, err)
-					errCount++
-					continue
-				}
+func stateUpdateReason(vol staleVolume, policy structInternal.Policy, sent int) string {
+	var changes []string
+	if sent > 0 {
+		changes = append(changes, fmt.Sprintf("notice %d of %d sent by email", len(vol.state.NotificationsSent)+sent, len(policy.NotifTimes)))
+	}
+	if vol.legacy {
+		changes = append(changes, "labels of an earlier version migrated")
+	}
+	if len(changes) == 0 {
+		changes = append(changes, fmt.Sprintf("grace period of %d days and notices %v days before the deletion applied", policy.GracePeriod, policy.NotifTimes))
+	}
+	return strings.Join(changes, ", ")
+}
 
-				// Update Email Count
-				emailCount++
+// returns why a volume unused since since is deleted, as recorded in the audit log
 
-				// Increment notification count by 1
-				newNotifCount := strconv.Itoa(currNotif + 1)
-				SetPvcLabel(kube, cfg.NotifLabel, newNotifCount, pvc.Namespace, pvc.Name)
+func deletionReason(cfg structInternal.SchedulerConfig, since time.Time, state structInternal.VolumeState) string {
+	reason := fmt.Sprintf("unused since %s, the grace period of %d days ended", since.UTC().Format(time.RFC3339), cfg.GracePeriod+state.ExtensionDays())
+	if days := state.ExtensionDays(); days != 0 {
+		reason += fmt.Sprintf(" (extended by %d days)", days)
+	}
+	return reason
+}
+
+// returns the reference of a pvc in its events
+
+func pvcReference(pvc corev1.PersistentVolumeClaim) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  pvc.Namespace,
+		Name:       pvc.Name,
+		UID:        pvc.UID,
+	}
+}
+
+// returns when the grace period of a volume started: when it was detached or,
+// with usage tracking, the last time it was mounted
 
+func gracePeriodStart(cfg structInternal.SchedulerConfig, state structInternal.VolumeState, annotations map[string]string) (time.Time, error) {
+	lastMounted, ok := annotations[cfg.UsageAnnotation]
+	if !ok || cfg.UsageAnnotation == "" {
+		return state.DetachedAt, nil
+	}
+
+	log.Printf("[INFO][USAGE] Last mounted at %s.", lastMounted)
+	since, err := time.Parse(cfg.TimeFormat, lastMounted)
+	if err != nil || state.DetachedAt.IsZero() {
+		return since, err
+	}
+
+	// the last mount can be long before the volume was marked, e.g. a stateful set scaled to zero for
+	// months and then deleted. the start is moved forward so that the first notice is not due before
+	// the volume was marked, every notice is sent before the deletion
+	firstNotice := 0
+	if len(cfg.NotifTimes) > 0 {
+		firstNotice = slices.Max(cfg.NotifTimes)
+	}
+	earliest := state.DetachedAt.AddDate(0, 0, firstNotice-cfg.GracePeriod)
+	if since.Before(earliest) {
+		log.Printf("[INFO][USAGE] Counting from %s so that every notice can be sent.", earliest.Format(cfg.TimeFormat))
+		return earliest, nil
+	}
+	return since, nil
+}
+
+// returned when a volume no longer matches what its deletion was decided on
+var errVolumeChanged = errors.New("volume changed since it was read")
+
+// reads a pvc again right before it is deleted and checks it is still the same unattached pvc in the
+// same grace period, and that nothing uses it (see safety.go). the latest version is returned, its uid
+// and resource version guard the deletion
+
+func recheckPvc(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, pvc corev1.PersistentVolumeClaim, state structInternal.VolumeState) (*corev1.PersistentVolumeClaim, error) {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	latest, err := kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(callCtx, pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if latest.UID != pvc.UID {
+		return nil, fmt.Errorf("%w: the PVC was created again", errVolumeChanged)
+	}
+	if latest.Labels[cfg.IgnoreLabel] == "true" {
+		return nil, fmt.Errorf("%w: label %s was added", errVolumeChanged, cfg.IgnoreLabel)
+	}
+	if rule, ok := cfg.Protection.Protects(latest.ObjectMeta); ok {
+		return nil, fmt.Errorf("%w: protected by rule %s", errVolumeChanged, rule)
+	}
+	if cfg.UsageAnnotation != "" && latest.Annotations[cfg.UsageAnnotation] != pvc.Annotations[cfg.UsageAnnotation] {
+		return nil, fmt.Errorf("%w: the PVC was mounted", errVolumeChanged)
+	}
+
+	current, ok, _, err := cfg.StateKeys().Read(latest.Labels, latest.Annotations)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: %s", errVolumeChanged, err)
+	case !ok:
+		return nil, fmt.Errorf("%w: the PVC was attached again", errVolumeChanged)
+	case !current.DetachedAt.Equal(state.DetachedAt):
+		return nil, fmt.Errorf("%w: the grace period started again", errVolumeChanged)
+	case current.ExtensionDays() != state.ExtensionDays():
+		return nil, fmt.Errorf("%w: the grace period was extended", errVolumeChanged)
+	}
+
+	// the workload is not created yet, the controller removes the state once it is (see admission.go)
+	if workload, ok := admittedWorkload(*latest, cfg.Clock.Now()); ok {
+		return nil, fmt.Errorf("%w: %s using it is being created", errVolumeChanged, workload)
+	}
+
+	if err := checkClaimUnused(ctx, kube, *latest); err != nil {
+		return nil, err
+	}
+
+	return latest, nil
+}
+
+// returns the state to store on a volume after change, applied on top of its latest labels and annotations
+// returns false when the volume was attached again or its grace period started over since it was read,
+// what was decided no longer applies then. created is true when the state read was built for a volume
+// that had none, it is stored as is
+
+func changedState(keys structInternal.StateKeys, read structInternal.VolumeState, created bool, labels map[string]string, annotations map[string]string, change func(state *structInternal.VolumeState)) (structInternal.VolumeState, bool) {
+	current, ok, _, err := keys.Read(labels, annotations)
+	if err == nil && !ok && created {
+		current, ok = read, true
+	}
+	if err != nil || !ok || !current.DetachedAt.Equal(read.DetachedAt) {
+		return structInternal.VolumeState{}, false
+	}
+
+	change(&current)
+	return current, true
+}
+
+// a pvc or pv going through the grace period
+
+type staleVolume struct {
+	// "PVC" or "PV", used in logs
+	kind string
+	name string
+
+	// namespace of the owner, the namespace of the former claim for pvs
+	namespace string
+
+	state structInternal.VolumeState
+
+	// the state was read from the labels of an earlier version and is not stored yet
+	legacy bool
+
+	// when the grace period started
+	since time.Time
+
+	size         resource.Quantity
+	storageClass string
+
+	// deletes the volume, given its final state
+	// fails with errVolumeChanged when the volume no longer matches what was read,
+	// or errVolumeInUse when something still uses it
+	remove func(state structInternal.VolumeState) error
+
+	// records a warning event on the volume
+	warn func(reason string, message string)
+
+	// applies change to the latest stored state of the volume and writes it, recording why in the audit log
+	save func(reason string, change func(state *structInternal.VolumeState))
+
+	// returns the owner email and the variables of the notice
+	details func(detachedAt time.Time) (string, structInternal.Personalisation)
+}
+
+// deletes a stale volume or sends its owner the next notification
+// returns true if the volume was deleted (or would have been, in a dry run)
+
+func processVolume(ctx context.Context, cfg structInternal.SchedulerConfig, client *http.Client, businessDay bool, report *structInternal.RunReport, vol staleVolume) (deleted bool) {
+	// the policy applied is recorded, along with every notification sent below
+	policy := cfg.Policy()
+	changed := vol.legacy || !vol.state.HasPolicy(policy)
+	vol.state.Policy = &policy
+
+	// the volume may change while this runs, so only what this run did is written on top of it
+	var sent []structInternal.Notification
+
+	defer func() {
+		if changed && !deleted && !cfg.DryRun {
+			vol.save(stateUpdateReason(vol, policy, len(sent)), func(state *structInternal.VolumeState) {
+				state.Policy = &policy
+				state.NotificationsSent = append(state.NotificationsSent, sent...)
+			})
+		}
+	}()
+
+	// extensions push back the deletion and every notification with it
+	for _, extension := range vol.state.Extensions {
+		if !extension.Valid() {
+			log.Printf("[ERROR] Ignoring extension of %d days, it must add between 1 and %d days.", extension.Days, structInternal.MaxExtensionDays)
+		}
+	}
+	if days := vol.state.ExtensionDays(); days != 0 {
+		log.Printf("[INFO] Grace period extended by %d days.", days)
+		cfg.GracePeriod += days
+	}
+
+	// check if volume should be deleted
+	stale := IsStale(vol.since, cfg)
+
+	capacityKey := structInternal.CapacityKey{Namespace: vol.namespace, StorageClass: vol.storageClass}
+	log.Printf("[INFO] Size: %s, storage class: %q", vol.size.String(), vol.storageClass)
+	if cost, ok := cfg.Prices.MonthlyCost(vol.storageClass, vol.size.Value()); ok {
+		log.Printf("[INFO] Monthly cost: %s", cfg.Prices.Format(cost))
+	}
+
+	// stale means grace period has passed, can be deleted
+	if stale {
+		if !businessDay {
+			log.Printf("[INFO] Deferring deletion of %s %s to the next business day.", vol.kind, vol.name)
+			report.AddPending(capacityKey, vol.size.Value())
+			return false
+		}
+
+		if cfg.DryRun {
+			log.Printf("[DRY RUN] Delete %s %s", vol.kind, vol.name)
+			report.AddReclaimed(capacityKey, vol.size.Value())
+			return true
+		}
+
+		err := vol.remove(vol.state)
+		if errors.Is(err, errVolumeChanged) || errors.Is(err, errVolumeInUse) {
+			// a volume in use while labelled as unattached means the labels are wrong
+			if errors.Is(err, errVolumeInUse) {
+				log.Printf("[ERROR] Not deleting %s %s: %s.", vol.kind, vol.name, err)
+				report.Errors++
+			} else {
+				log.Printf("[INFO] Not deleting %s %s: %s. It is checked again on the next run.", vol.kind, vol.name, err)
 			}
+			vol.warn("DeletionSkipped", fmt.Sprintf("Not deleted by volume-cleaner: %s", err))
+			report.AddPending(capacityKey, vol.size.Value())
+			return false
 		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to delete %s %s: %s", vol.kind, vol.name, err)
+			report.Errors++
+			report.AddPending(capacityKey, vol.size.Value())
+			return false
+		}
+
+		log.Printf("[INFO] %s successfully deleted.", vol.kind)
+		report.AddReclaimed(capacityKey, vol.size.Value())
+		return true
 	}
 
-	log.Printf("[INFO] Job errors: %d", errCount)
-	log.Printf("[INFO] Emails sent: %d", emailCount)
-	log.Printf("[INFO] Pvcs deleted: %d", deleteCount)
+	// the volume is still waiting for its grace period to end
+	report.AddPending(capacityKey, vol.size.Value())
+
+	// not stale yet, handle email logic here
 
-	return deleteCount, emailCount
+	log.Print("[INFO] Grace period not passed.")
+
+	if len(cfg.NotifTimes) == 0 {
+		return false
+	}
+
+	currNotif := len(vol.state.NotificationsSent)
+
+	shouldSend, _ := ShouldSendMail(vol.since, currNotif, cfg)
+
+	if shouldSend {
+		// the last configured notification is the final warning
+		if !businessDay && currNotif == len(cfg.NotifTimes)-1 {
+			log.Print("[INFO] Deferring final warning to the next business day.")
+			return false
+		}
 
+		if cfg.DryRun {
+			log.Print("[DRY RUN] Email owner.")
+			report.Emailed++
+			return false
+		}
+
+		// personal consists of details passed into the email template as variables while email is
+		// the email address that is consistent regardless of the template
+
+		email, personal := vol.details(vol.since)
+
+		err := utilsInternal.SendNotif(ctx, client, cfg.EmailCfg, email, personal)
+		if err != nil {
+			log.Printf("[Error] Unable to send an email to %s at %s: %s", personal.Name, email, err)
+			report.Errors++
+			return false
+		}
+
+		// Update Email Count
+		report.Emailed++
+
+		// Record the notification, saved with the state on return
+		sent = append(sent, structInternal.Notification{
+			SentAt:  cfg.Clock.Now().UTC(),
+			Channel: structInternal.EmailChannel,
+		})
+		changed = true
+	}
+
+	return false
 }
 
-// determines if the grace period is greater than a given timestamp
+// logs the reclaimed and pending capacity per namespace and storage class, then the totals
 
-func IsStale(timestamp string, format string, gracePeriod int) (bool, error) {
-	timeObj, err := time.Parse(format, timestamp)
-	if err != nil {
-		return false, err
+func logCapacity(report structInternal.RunReport) {
+	keys := make([]structInternal.CapacityKey, 0, len(report.Capacity))
+	for key := range report.Capacity {
+		keys = append(keys, key)
 	}
 
-	// difference in days
-	diff := time.Since(timeObj).Hours() / 24
+	// sorted so the summary reads the same between runs
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+		return keys[i].StorageClass < keys[j].StorageClass
+	})
+
+	for _, key := range keys {
+		totals := report.Capacity[key]
+		log.Printf("[INFO] Capacity for NS %s, storage class %q: reclaimed %s, pending %s",
+			key.Namespace, key.StorageClass,
+			utilsInternal.FormatBytes(totals.Reclaimed), utilsInternal.FormatBytes(totals.Pending))
+	}
+
+	total := report.Total()
+	log.Printf("[INFO] Capacity reclaimed: %s", utilsInternal.FormatBytes(total.Reclaimed))
+	log.Printf("[INFO] Capacity pending reclamation: %s", utilsInternal.FormatBytes(total.Pending))
+}
 
-	log.Printf("[INFO] Time passed since detachment: %f days.", diff)
+// determines if the grace period is over for a volume whose grace period started at since
+// the grace period is counted in business days when the calendar is configured to
 
-	stale := diff > float64(gracePeriod)
+func IsStale(since time.Time, cfg structInternal.SchedulerConfig) bool {
+	log.Printf("[INFO] Time passed since detachment: %f days.", cfg.Clock.Since(since).Hours()/24)
+
+	daysLeft := cfg.Calendar.DaysLeft(cfg.Clock.Now(), since, cfg.GracePeriod)
+
+	stale := daysLeft < 0
 	if !stale {
-		log.Printf("[INFO] Time until deletion: %f days", float64(gracePeriod)-diff)
+		log.Printf("[INFO] Time until deletion: %f days", daysLeft)
 	}
 
-	return stale, nil
+	return stale
 }
 
 // checks email times and determines if this pvc's owner should be emailed
 
-func ShouldSendMail(timestamp string, currNotif int, cfg structInternal.SchedulerConfig) (bool, float64, error) {
-	timeObj, err := time.Parse(cfg.TimeFormat, timestamp)
-	if err != nil {
-		return false, 0.0, err
-	}
-	daysLeft := float64(cfg.GracePeriod) - time.Since(timeObj).Hours()/24
+func ShouldSendMail(since time.Time, currNotif int, cfg structInternal.SchedulerConfig) (bool, float64) {
+	daysLeft := cfg.Calendar.DaysLeft(cfg.Clock.Now(), since, cfg.GracePeriod)
 
 	// this logic ensures that emails are eventually sent even if the
 	// scheduler is down and misses a few days
@@ -185,10 +647,10 @@ func ShouldSendMail(timestamp string, currNotif int, cfg structInternal.Schedule
 	if currNotif < len(cfg.NotifTimes) {
 		if float64(cfg.NotifTimes[currNotif]) >= daysLeft {
 			log.Printf("[INFO] Closest email interval: %v days", cfg.NotifTimes[currNotif])
-			return true, daysLeft, nil
+			return true, daysLeft
 		}
 		log.Printf("[INFO] Time until next email: %v days", daysLeft-float64(cfg.NotifTimes[currNotif]))
 	}
 
-	return false, daysLeft, nil
+	return false, daysLeft
 }
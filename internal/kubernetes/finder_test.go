@@ -3,12 +3,21 @@ package kubernetes
 import (
 	// standard packages
 	"context"
-	"math"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	// external packages
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	// internal packages
 	structInternal "volume-cleaner/internal/structure"
@@ -20,6 +29,9 @@ func TestFindStale(t *testing.T) {
 		// create fake client
 		kube := testInternal.NewFakeClient()
 
+		// freeze time so the scenario does not depend on the wall clock
+		clock := testInternal.NewFakeClock(time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC))
+
 		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
 		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
 			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
@@ -35,57 +47,476 @@ func TestFindStale(t *testing.T) {
 		}
 
 		schedulerCfg := structInternal.SchedulerConfig{
-			Namespace:   "test",
-			TimeLabel:   "volume-cleaner/unattached-time",
-			NotifLabel:  "volume-cleaner/notification-count",
-			IgnoreLabel: "volume-cleaner/ignore",
-			GracePeriod: 0,
-			TimeFormat:  "2006-01-02_15-04-05Z",
-			DryRun:      true,
-			NotifTimes:  []int{10},
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     0,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			DryRun:          true,
+			NotifTimes:      []int{10},
+			Clock:           clock,
 		}
 
-		deleted, emailed := FindStale(kube, schedulerCfg)
+		report := FindStale(context.TODO(), kube, schedulerCfg)
 
 		// nothing was labelled, so nothing should be deleted
-		assert.Equal(t, deleted, 0)
-		assert.Equal(t, emailed, 0)
+		assert.Equal(t, report.Deleted, 0)
+		assert.Equal(t, report.Emailed, 0)
 
 		controllerCfg := structInternal.ControllerConfig{
-			Namespace:  "test",
-			TimeLabel:  "volume-cleaner/unattached-time",
-			NotifLabel: "volume-cleaner/notification-count",
-			TimeFormat: "2006-01-02_15-04-05Z",
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           clock,
 		}
 
-		InitialScan(kube, controllerCfg)
+		InitialScan(context.TODO(), kube, controllerCfg)
+
+		// labels were just added, so nothing is past a grace period of 0 days yet
+		// but both owners are due their final notice
+		report = FindStale(context.TODO(), kube, schedulerCfg)
 
-		time.Sleep(5 * time.Second)
+		assert.Equal(t, report.Deleted, 0)
+		assert.Equal(t, report.Emailed, 2)
 
-		deleted, emailed = FindStale(kube, schedulerCfg)
+		clock.Advance(time.Hour)
 
-		assert.Equal(t, deleted, 2)
-		assert.Equal(t, emailed, 0)
+		report = FindStale(context.TODO(), kube, schedulerCfg)
 
-		SetPvcLabel(kube, "volume-cleaner/ignore", "true", "test", "pvc1")
+		assert.Equal(t, report.Deleted, 2)
+		assert.Equal(t, report.Emailed, 0)
 
-		deleted, emailed = FindStale(kube, schedulerCfg)
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/ignore", "true", "test", "pvc1")
+
+		report = FindStale(context.TODO(), kube, schedulerCfg)
 
 		// now pvc1 should be skipped
-		assert.Equal(t, deleted, 1)
-		assert.Equal(t, emailed, 0)
+		assert.Equal(t, report.Deleted, 1)
+		assert.Equal(t, report.Emailed, 0)
 
-		RemovePvcLabel(kube, "volume-cleaner/ignore", "test", "pvc1")
+		RemovePvcLabel(context.TODO(), kube, "volume-cleaner/ignore", "test", "pvc1")
 
 		schedulerCfg.GracePeriod = 5
 
-		deleted, emailed = FindStale(kube, schedulerCfg)
+		report = FindStale(context.TODO(), kube, schedulerCfg)
+
+		assert.Equal(t, report.Deleted, 0)
+		assert.Equal(t, report.Emailed, 2)
+
+	})
+
+}
+
+func TestFindStaleBusinessDays(t *testing.T) {
+	t.Run("deletions are deferred on weekends and holidays", func(t *testing.T) {
+		// create fake client
+		kube := testInternal.NewFakeClient()
+
+		// friday before a long weekend
+		clock := testInternal.NewFakeClock(time.Date(2025, time.June, 27, 12, 0, 0, 0, time.UTC))
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		controllerCfg := structInternal.ControllerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           clock,
+		}
+
+		InitialScan(context.TODO(), kube, controllerCfg)
+
+		schedulerCfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     1,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			DryRun:          true,
+			NotifTimes:      []int{1},
+			Clock:           clock,
+			Calendar: structInternal.Calendar{
+				BusinessDays: true,
+				Holidays:     map[string]struct{}{"2025-06-30": {}, "2025-07-01": {}},
+			},
+		}
+
+		// saturday: the final warning is due but held back
+		clock.Advance(24 * time.Hour)
+		report := FindStale(context.TODO(), kube, schedulerCfg)
+		assert.Equal(t, report.Deleted, 0)
+		assert.Equal(t, report.Emailed, 0)
+
+		// tuesday (holiday): one business day is still left, so the warning is due, but held back
+		clock.Advance(3 * 24 * time.Hour)
+		report = FindStale(context.TODO(), kube, schedulerCfg)
+		assert.Equal(t, report.Deleted, 0)
+		assert.Equal(t, report.Emailed, 0)
+
+		// wednesday morning: back to work, the final warning goes out
+		clock.Set(time.Date(2025, time.July, 2, 8, 0, 0, 0, time.UTC))
+		report = FindStale(context.TODO(), kube, schedulerCfg)
+		assert.Equal(t, report.Deleted, 0)
+		assert.Equal(t, report.Emailed, 1)
+
+		// wednesday afternoon: one business day has passed, can be deleted
+		clock.Set(time.Date(2025, time.July, 2, 13, 0, 0, 0, time.UTC))
+		report = FindStale(context.TODO(), kube, schedulerCfg)
+		assert.Equal(t, report.Deleted, 1)
+
+		// a stale pvc is still not deleted on a weekend
+		clock.Set(time.Date(2025, time.July, 5, 13, 0, 0, 0, time.UTC))
+		report = FindStale(context.TODO(), kube, schedulerCfg)
+		assert.Equal(t, report.Deleted, 0)
+	})
+}
+
+func TestFindStaleUsage(t *testing.T) {
+	t.Run("grace period counts from the last mount", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		for _, name := range []string{"pvc1", "pvc2"} {
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "test"); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+
+			// both lost their stateful set recently
+			SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "2025-07-01_00-00-00Z", "test", name)
+			SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "0", "test", name)
+		}
+
+		// but pvc1 has not been mounted for a month
+		SetPvcAnnotation(context.TODO(), kube, "volume-cleaner/last-mounted", "2025-06-01_00-00-00Z", "test", "pvc1")
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			DryRun:          true,
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 5, 0, 0, 0, 0, time.UTC)),
+		}
+
+		// without usage tracking the annotation is ignored
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 0, report.Deleted)
+
+		cfg.UsageAnnotation = "volume-cleaner/last-mounted"
+		report = FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Deleted)
+
+		// every notice still fits after the volume was marked, the first one a week before the deletion
+		cfg.NotifTimes = []int{7, 1}
+		report = FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 2, report.Emailed)
+
+		// the deadline is July 8th, a week after the volume was marked
+		cfg.Clock = testInternal.NewFakeClock(time.Date(2025, time.July, 9, 0, 0, 0, 0, time.UTC))
+		report = FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Deleted)
+	})
+}
+
+func TestFindStaleState(t *testing.T) {
+	t.Run("legacy labels are migrated and the policy is recorded", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		// labelled by an earlier version, one notification already sent
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "2025-07-01_00-00-00Z", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "1", "test", "pvc1")
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			NotifTimes:      []int{5, 1},
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 3, 0, 0, 0, 0, time.UTC)),
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 0, report.Errors)
+
+		state := pvcState(t, kube, "test", "pvc1")
+		assert.Equal(t, time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC), state.DetachedAt)
+		assert.Len(t, state.NotificationsSent, 1)
+		assert.Equal(t, &structInternal.Policy{GracePeriod: 10, NotifTimes: []int{5, 1}}, state.Policy)
+		assert.Equal(t, "2025-07-01_00-00-00Z", pvcLabel(kube, "test", "pvc1", cfg.TimeLabel))
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc1", cfg.NotifLabel))
+	})
+
+	t.Run("extensions postpone the deletion", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC))
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           clock,
+		}
+
+		SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+			DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+			Extensions: []structInternal.Extension{{Days: 7, Reason: "thesis data"}},
+		}, getPvc(t, kube, "test", "pvc1"))
+
+		// 14 days detached, inside the extended grace period of 17 days
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 0, report.Deleted)
+		assert.Len(t, listPvcs(t, kube, "test"), 1)
+
+		clock.Advance(4 * 24 * time.Hour)
+
+		report = FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Deleted)
+		assert.Empty(t, listPvcs(t, kube, "test"))
+	})
+}
+
+func TestFindStaleRecheck(t *testing.T) {
+	setup := func(t *testing.T) (*testInternal.FakeClient, structInternal.SchedulerConfig) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "test", UID: "uid-1", ResourceVersion: "7"},
+		}
+		if _, pvcErr := kube.CoreV1().PersistentVolumeClaims("test").Create(context.TODO(), pvc, metav1.CreateOptions{}); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     10,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Protection: structInternal.Protection{Rules: []structInternal.ProtectionRule{
+				{Name: "pinned", Annotation: "example.com/keep"},
+			}},
+			Clock: testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC)),
+		}
+
+		// stale for days
+		SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+			DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		}, getPvc(t, kube, "test", "pvc1"))
+
+		return kube, cfg
+	}
+
+	t.Run("the deletion is guarded by the version that was checked", func(t *testing.T) {
+		kube, cfg := setup(t)
+
+		var options metav1.DeleteOptions
+		kube.Interface.(*fake.Clientset).PrependReactor("delete", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			options = action.(k8stesting.DeleteActionImpl).DeleteOptions
+			return false, nil, nil
+		})
+
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Deleted)
+
+		if assert.NotNil(t, options.Preconditions) {
+			assert.Equal(t, "uid-1", string(*options.Preconditions.UID))
+			assert.NotEmpty(t, *options.Preconditions.ResourceVersion)
+		}
+	})
+
+	t.Run("a pvc that changed after it was listed is kept", func(t *testing.T) {
+		// each case changes the pvc as it is read right before the deletion
+		cases := map[string]func(pvc *corev1.PersistentVolumeClaim){
+			"attached again": func(pvc *corev1.PersistentVolumeClaim) {
+				pvc.Labels = nil
+				pvc.Annotations = nil
+			},
+			"ignored": func(pvc *corev1.PersistentVolumeClaim) {
+				pvc.Labels["volume-cleaner/ignore"] = "true"
+			},
+			"protected": func(pvc *corev1.PersistentVolumeClaim) {
+				pvc.Annotations["example.com/keep"] = "true"
+			},
+			"created again": func(pvc *corev1.PersistentVolumeClaim) {
+				pvc.UID = "uid-2"
+			},
+			"extended": func(pvc *corev1.PersistentVolumeClaim) {
+				pvc.Annotations["volume-cleaner/state"] = structInternal.VolumeState{
+					DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+					Extensions: []structInternal.Extension{{Days: 30}},
+				}.Encode()
+			},
+		}
 
-		assert.Equal(t, deleted, 0)
-		assert.Equal(t, emailed, 2)
+		for name, change := range cases {
+			t.Run(name, func(t *testing.T) {
+				kube, cfg := setup(t)
 
+				latest := getPvc(t, kube, "test", "pvc1")
+				change(&latest)
+				kube.Interface.(*fake.Clientset).PrependReactor("get", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, latest.DeepCopy(), nil
+				})
+
+				report := FindStale(context.TODO(), kube, cfg)
+				assert.Equal(t, 0, report.Deleted)
+				assert.Equal(t, 0, report.Errors)
+				assert.Len(t, listPvcs(t, kube, "test"), 1)
+			})
+		}
 	})
+}
+
+func TestFindStaleProtected(t *testing.T) {
+	kube, cfg, _ := archiveSetup(t)
+	cfg.Archive = structInternal.ArchiveConfig{}
+
+	// labelled before the rule was added
+	cfg.Protection = structInternal.Protection{Rules: []structInternal.ProtectionRule{
+		{Name: "databases", Annotation: "example.com/database"},
+		{Name: "standard", OwnerKinds: []string{"Cluster"}},
+	}}
+	SetPvcAnnotation(context.TODO(), kube, "example.com/database", "postgres", "test", "pvc1")
+
+	report := FindStale(context.TODO(), kube, cfg)
+	assert.Equal(t, 0, report.Deleted)
+	assert.Equal(t, 0, report.Emailed)
+	assert.Equal(t, []structInternal.ProtectedVolume{{Namespace: "test", Name: "pvc1", Rule: "databases"}}, report.Protected)
+	assert.Len(t, listPvcs(t, kube, "test"), 1)
+
+	// nothing is recorded on a protected pvc
+	state, _, _, _ := cfg.StateKeys().Read(getPvc(t, kube, "test", "pvc1").Labels, getPvc(t, kube, "test", "pvc1").Annotations)
+	assert.Nil(t, state.Policy)
+}
+
+func TestFindStaleCapacity(t *testing.T) {
+	t.Run("capacity is totalled per namespace and storage class", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
 
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		if pvErr := kube.CreatePersistentVolume(context.TODO(), "pv3", "test", "pvc3"); pvErr != nil {
+			t.Fatalf("Error injecting pv add: %v", pvErr)
+		}
+		pv, _ := kube.CoreV1().PersistentVolumes().Get(context.TODO(), "pv3", metav1.GetOptions{})
+		pv.Spec.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")}
+		pv.Spec.StorageClassName = "premium"
+		if _, pvErr := kube.CoreV1().PersistentVolumes().Update(context.TODO(), pv, metav1.UpdateOptions{}); pvErr != nil {
+			t.Fatalf("Error injecting pv update: %v", pvErr)
+		}
+
+		standard := "standard"
+		pvcs := []struct {
+			name     string
+			size     string
+			detached string
+		}{
+			// stale
+			{"pvc1", "10Gi", "2025-06-01_00-00-00Z"},
+			// in its grace period
+			{"pvc2", "5Gi", "2025-06-28_00-00-00Z"},
+			// in its grace period, size and class only known from the bound pv
+			{"pvc3", "", "2025-06-28_00-00-00Z"},
+		}
+
+		for _, p := range pvcs {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      p.name,
+					Namespace: "test",
+					Labels: map[string]string{
+						"volume-cleaner/unattached-time":    p.detached,
+						"volume-cleaner/notification-count": "0",
+					},
+				},
+			}
+			if p.size != "" {
+				pvc.Spec.StorageClassName = &standard
+				pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(p.size)}
+			} else {
+				pvc.Spec.VolumeName = "pv3"
+			}
+			if _, pvcErr := kube.CoreV1().PersistentVolumeClaims("test").Create(context.TODO(), pvc, metav1.CreateOptions{}); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			DryRun:          true,
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)),
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		gi := int64(1024 * 1024 * 1024)
+
+		assert.Equal(t, 1, report.Deleted)
+		assert.Equal(t, map[structInternal.CapacityKey]structInternal.CapacityTotals{
+			{Namespace: "test", StorageClass: "standard"}: {Reclaimed: 10 * gi, Pending: 5 * gi},
+			{Namespace: "test", StorageClass: "premium"}:  {Pending: 2 * gi},
+		}, report.Capacity)
+		assert.Equal(t, structInternal.CapacityTotals{Reclaimed: 10 * gi, Pending: 7 * gi}, report.Total())
+	})
 }
 
 func TestIsStale(t *testing.T) {
@@ -93,6 +524,9 @@ func TestIsStale(t *testing.T) {
 	t.Run("test successful determination of stale pvcs", func(t *testing.T) {
 		format := "2006-01-02_15-04-05Z"
 
+		now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+		clock := testInternal.NewFakeClock(now)
+
 		type testCase struct {
 			timestamp     string
 			format        string
@@ -103,42 +537,56 @@ func TestIsStale(t *testing.T) {
 		testCases := []testCase{
 			{
 				// test one day longer than grace period
-				timestamp:     time.Now().Add(-time.Hour * 24 * 181).Format(format),
+				timestamp:     now.Add(-time.Hour * 24 * 181).Format(format),
 				format:        format,
 				gracePeriod:   180,
 				expectedValue: true,
 			},
 			{
 				// test one hour shorter than grace period
-				timestamp:     time.Now().Add(-time.Hour*24*180 + time.Hour*23).Format(format),
+				timestamp:     now.Add(-time.Hour*24*180 + time.Hour*23).Format(format),
 				format:        format,
 				gracePeriod:   180,
 				expectedValue: false,
 			},
 			{
 				// test one second shorter than grace period
-				timestamp:     time.Now().Add(-time.Hour*24*180 + time.Hour*23 + time.Minute*59 + time.Second*59).Format(format),
+				timestamp:     now.Add(-time.Hour*24*180 + time.Hour*23 + time.Minute*59 + time.Second*59).Format(format),
+				format:        format,
+				gracePeriod:   180,
+				expectedValue: false,
+			},
+			{
+				// test exactly the grace period
+				timestamp:     now.Add(-time.Hour * 24 * 180).Format(format),
 				format:        format,
 				gracePeriod:   180,
 				expectedValue: false,
 			},
+			{
+				// test one second longer than grace period
+				timestamp:     now.Add(-time.Hour*24*180 - time.Second).Format(format),
+				format:        format,
+				gracePeriod:   180,
+				expectedValue: true,
+			},
 			{
 				// test now
-				timestamp:     time.Now().Format(format),
+				timestamp:     now.Format(format),
 				format:        format,
 				gracePeriod:   180,
 				expectedValue: false,
 			},
 			{
-				// test now with 0 grace period
-				timestamp:     time.Now().Format(format),
+				// test one second after detachment with 0 grace period
+				timestamp:     now.Add(-time.Second).Format(format),
 				format:        format,
 				gracePeriod:   0,
 				expectedValue: true,
 			},
 			{
 				// test one hour until grace period
-				timestamp:     time.Now().Add(time.Hour).Format(format),
+				timestamp:     now.Add(time.Hour).Format(format),
 				format:        format,
 				gracePeriod:   0,
 				expectedValue: false,
@@ -146,10 +594,16 @@ func TestIsStale(t *testing.T) {
 		}
 
 		for _, test := range testCases {
-			v, err := IsStale(test.timestamp, test.format, test.gracePeriod)
+			cfg := structInternal.SchedulerConfig{
+				TimeFormat:  test.format,
+				GracePeriod: test.gracePeriod,
+				Clock:       clock,
+			}
+			since, err := time.Parse(test.format, test.timestamp)
 			if err != nil {
-				t.Fatal("IsStale failed.")
+				t.Fatal("Parsing timestamp failed.")
 			}
+			v := IsStale(since, cfg)
 			assert.Equal(t, v, test.expectedValue)
 		}
 
@@ -167,21 +621,26 @@ func TestShouldSendMail(t *testing.T) {
 			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
 		}
 
+		now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+		clock := testInternal.NewFakeClock(now)
+
 		cfg := structInternal.SchedulerConfig{
-			Namespace:   "test",
-			TimeLabel:   "volume-cleaner/unattached-time",
-			NotifLabel:  "volume-cleaner/notification-count",
-			IgnoreLabel: "volume-cleaner/ignore",
-			GracePeriod: 180,
-			TimeFormat:  "2006-01-02_15-04-05Z",
-			DryRun:      true,
-			NotifTimes:  []int{30, 3, 2, 1},
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     180,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			DryRun:          true,
+			NotifTimes:      []int{30, 3, 2, 1},
 			EmailCfg: structInternal.EmailConfig{
 				BaseURL:         "https://api.notification.canada.ca",
 				Endpoint:        "/v2/notifications/email",
 				EmailTemplateID: "Random Template",
 				APIKey:          "Random APIKEY",
 			},
+			Clock: clock,
 		}
 
 		type testCase struct {
@@ -190,8 +649,6 @@ func TestShouldSendMail(t *testing.T) {
 			currNotif     int
 		}
 
-		now := time.Now()
-
 		testCases := []testCase{
 			{
 				timestamp:     now,
@@ -396,14 +853,11 @@ func TestShouldSendMail(t *testing.T) {
 		}
 
 		for _, test := range testCases {
-			v, daysLeft, err := ShouldSendMail(test.timestamp.Format(cfg.TimeFormat), test.currNotif, cfg)
-			if err != nil {
-				t.Fatal("ShouldSendMail failed.")
-			}
+			v, daysLeft := ShouldSendMail(test.timestamp, test.currNotif, cfg)
 
 			// test that days left until volume deletion is properly calculated
-			diff := float64(cfg.GracePeriod) - time.Since(test.timestamp).Hours()/24
-			assert.True(t, math.Abs(daysLeft-diff) < 0.5) // account for differences in computations
+			diff := float64(cfg.GracePeriod) - clock.Since(test.timestamp).Hours()/24
+			assert.InDelta(t, diff, daysLeft, 1e-9)
 
 			assert.Equal(t, v, test.expectedValue)
 		}
@@ -411,3 +865,249 @@ func TestShouldSendMail(t *testing.T) {
 	})
 
 }
+
+func TestFindStaleScope(t *testing.T) {
+	t.Run("labelled pvcs outside the namespace scope are left alone", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		for _, ns := range []string{"team-a", "team-b", "kubeflow", "platform"} {
+			nsLabels := labels
+			if ns == "platform" {
+				// not a kubeflow profile, never labelled by the controller
+				nsLabels = nil
+			}
+			if namespaceErr := kube.CreateNamespace(context.TODO(), ns, nsLabels); namespaceErr != nil {
+				t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+			}
+			if ns == "team-b" {
+				continue
+			}
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", ns); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+
+			// labels left behind, e.g. before the namespace was excluded
+			SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "2025-01-01_12-00-00Z", ns, "pvc1")
+			SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "1", ns, "pvc1")
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			Scope: structInternal.NamespaceScope{
+				Selector: structInternal.DefaultNamespaceSelector,
+				Exclude:  []string{"kubeflow"},
+			},
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     30,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			NotifTimes:      []int{1},
+			Clock:           clock,
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, 1, report.Deleted)
+		assert.Equal(t, 0, report.Errors)
+
+		assert.Empty(t, listPvcs(t, kube, "team-a"))
+		assert.Len(t, listPvcs(t, kube, "kubeflow"), 1)
+		assert.Len(t, listPvcs(t, kube, "platform"), 1)
+
+		// every managed namespace is summarised, even without stale pvcs
+		assert.Equal(t, map[string]structInternal.NamespaceSummary{
+			"team-a": {Scanned: 1, Deleted: 1},
+			"team-b": {},
+		}, report.Namespaces)
+
+		// a single namespace narrows the scope down
+		cfg.Namespace = "team-b"
+		report = FindStale(context.TODO(), kube, cfg)
+		assert.Equal(t, map[string]structInternal.NamespaceSummary{"team-b": {}}, report.Namespaces)
+	})
+}
+
+func TestFindStaleConcurrent(t *testing.T) {
+	t.Run("workers share the run report without races", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+
+		var sent atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sent.Add(1)
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		namespaces := []string{"ns1", "ns2", "ns3"}
+		for _, ns := range namespaces {
+			if namespaceErr := kube.CreateNamespace(context.TODO(), ns, labels); namespaceErr != nil {
+				t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+			}
+			nsObj, err := kube.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Error getting namespace: %v", err)
+			}
+			nsObj.Annotations = map[string]string{"owner": ns + "@example.com"}
+			if _, err := kube.CoreV1().Namespaces().Update(context.TODO(), nsObj, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("Error annotating namespace: %v", err)
+			}
+
+			// even pvcs are past the grace period, odd ones are due a notification
+			for i := range 10 {
+				name := fmt.Sprintf("pvc%d", i)
+				if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, ns); pvcErr != nil {
+					t.Fatalf("Error injecting pvc add: %v", pvcErr)
+				}
+				timestamp := "2025-07-01_12-00-00Z"
+				if i%2 == 0 {
+					timestamp = "2025-01-01_12-00-00Z"
+				}
+				SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", timestamp, ns, name)
+				SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "0", ns, name)
+			}
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     5,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			NotifTimes:      []int{10},
+			Concurrency:     4,
+			Clock:           clock,
+			EmailCfg: structInternal.EmailConfig{
+				BaseURL:         server.URL,
+				Endpoint:        "/v2/notifications/email",
+				EmailTemplateID: "template",
+				APIKey:          "key",
+			},
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.Errors)
+		assert.Equal(t, 15, report.Deleted)
+		assert.Equal(t, 15, report.Emailed)
+		assert.Equal(t, int32(15), sent.Load())
+
+		for _, ns := range namespaces {
+			assert.Equal(t, structInternal.NamespaceSummary{Scanned: 10, Deleted: 5, Emailed: 5}, report.Namespaces[ns])
+
+			pvcs := listPvcs(t, kube, ns)
+			assert.Len(t, pvcs, 5)
+			for _, pvc := range pvcs {
+				assert.Len(t, pvcState(t, kube, ns, pvc.Name).NotificationsSent, 1)
+			}
+		}
+	})
+}
+
+func TestFindStaleStopped(t *testing.T) {
+	t.Run("pvcs in progress finish, the rest wait for the next run", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the run is stopped while the first notification is being sent
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "ns1", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		nsObj, err := kube.CoreV1().Namespaces().Get(context.TODO(), "ns1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Error getting namespace: %v", err)
+		}
+		nsObj.Annotations = map[string]string{"owner": "ns1@example.com"}
+		if _, err := kube.CoreV1().Namespaces().Update(context.TODO(), nsObj, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Error annotating namespace: %v", err)
+		}
+
+		for i := range 4 {
+			name := fmt.Sprintf("pvc%d", i)
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "ns1"); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+			SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "2025-07-01_12-00-00Z", "ns1", name)
+			SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "0", "ns1", name)
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			IgnoreLabel:     "volume-cleaner/ignore",
+			GracePeriod:     5,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			NotifTimes:      []int{10},
+			Concurrency:     1,
+			Clock:           clock,
+			EmailCfg: structInternal.EmailConfig{
+				BaseURL:         server.URL,
+				Endpoint:        "/v2/notifications/email",
+				EmailTemplateID: "template",
+				APIKey:          "key",
+			},
+		}
+
+		report := FindStale(ctx, kube, cfg)
+
+		assert.Equal(t, 0, report.Errors)
+		assert.Equal(t, 1, report.Emailed)
+		assert.Equal(t, 3, report.Skipped)
+
+		// the notification that was sent is also recorded on the pvc
+		notified := 0
+		for _, pvc := range listPvcs(t, kube, "ns1") {
+			state, _, _, _ := cfg.StateKeys().Read(pvc.Labels, pvc.Annotations)
+			if len(state.NotificationsSent) == 1 {
+				notified++
+			}
+		}
+		assert.Equal(t, 1, notified)
+	})
+
+	t.Run("an expired deadline scans nothing", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "ns1", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Minute))
+		defer cancel()
+
+		cfg := structInternal.SchedulerConfig{
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     5,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Concurrency:     1,
+			DryRun:          true,
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)),
+		}
+
+		report := FindStale(ctx, kube, cfg)
+
+		assert.Equal(t, 0, report.Errors)
+		assert.Empty(t, report.Namespaces)
+	})
+}
@@ -0,0 +1,157 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	// external packages
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+/*
+The controller serves three endpoints for kubernetes and operators:
+
+  - /healthz answers as long as the process is running
+  - /livez answers as long as every watch loop was seen within livenessStaleness times WATCH_STALENESS,
+    a loop that stopped running is only fixed by restarting the controller (liveness probe)
+  - /readyz answers once the initial scan is complete and every watch is open and was seen within
+    WATCH_STALENESS (readiness probe)
+  - /debug/state shows the watches, their last event times and the state of every pvc labelled as unattached
+*/
+
+// a watch loop is restarted once it has not been seen for this many times WATCH_STALENESS,
+// well after the controller is no longer ready
+const livenessStaleness = 5
+
+// a labelled pvc as shown by /debug/state
+
+type trackedPvc struct {
+	Namespace string                     `json:"namespace"`
+	Name      string                     `json:"name"`
+	State     structInternal.VolumeState `json:"state"`
+
+	// set when the state cannot be read
+	Error string `json:"error,omitempty"`
+}
+
+// body of /debug/state
+
+type debugState struct {
+	Synced  bool                                 `json:"synced"`
+	Watches map[string]structInternal.WatchState `json:"watches"`
+	Pvcs    []trackedPvc                         `json:"pvcs"`
+}
+
+// serves the health endpoints on addr until ctx is cancelled
+
+func ServeHealth(ctx context.Context, addr string, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig], health *structInternal.Health) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           healthHandler(kube, live, health),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[ERROR] Failed to stop health server: %s", err)
+		}
+	}()
+
+	log.Printf("[INFO] Serving health endpoints on %s", addr)
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// routes the health endpoints, split from the server so it can be tested without a port
+
+func healthHandler(kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig], health *structInternal.Health) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("GET /livez", func(w http.ResponseWriter, r *http.Request) {
+		if err := health.Live(livenessStaleness * live.Get().WatchStaleness); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := health.Ready(live.Get().WatchStaleness); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("GET /debug/state", func(w http.ResponseWriter, r *http.Request) {
+		pvcs, err := trackedPvcs(r.Context(), kube, live.Get())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(debugState{
+			Synced:  health.Synced(),
+			Watches: health.Watches(),
+			Pvcs:    pvcs,
+		})
+	})
+
+	return mux
+}
+
+// returns every pvc labelled as unattached in the managed namespaces
+
+func trackedPvcs(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig) ([]trackedPvc, error) {
+	managed, err := managedNamespaces(ctx, kube, cfg.Scope, cfg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make([]trackedPvc, 0)
+	for _, namespace := range slices.Sorted(maps.Keys(managed)) {
+		pvcs, err := PvcList(ctx, kube, namespace, Selector{Label: cfg.TimeLabel})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pvc := range pvcs {
+			entry := trackedPvc{Namespace: pvc.Namespace, Name: pvc.Name}
+
+			// labels of earlier versions are shown as they will be migrated
+			state, _, _, err := cfg.StateKeys().Read(pvc.Labels, pvc.Annotations)
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			entry.State = state
+
+			tracked = append(tracked, entry)
+		}
+	}
+
+	return tracked, nil
+}
@@ -0,0 +1,229 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+func TestHealthReady(t *testing.T) {
+	clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+	health := structInternal.NewHealth(clock)
+
+	assert.ErrorContains(t, health.Ready(time.Minute), "initial scan")
+
+	health.SetSynced()
+	assert.ErrorContains(t, health.Ready(time.Minute), "no watch")
+
+	health.WatchStarted("statefulsets")
+	health.WatchStarted("persistentvolumeclaims")
+	assert.NoError(t, health.Ready(time.Minute))
+
+	// an idle watch stays ready as long as its loop reports in
+	clock.Advance(2 * time.Minute)
+	health.WatchAlive("statefulsets")
+	err := health.Ready(time.Minute)
+	assert.ErrorContains(t, err, "watch persistentvolumeclaims was last seen 2m0s ago")
+	assert.NotContains(t, err.Error(), "statefulsets")
+
+	health.WatchEvent("persistentvolumeclaims")
+	assert.NoError(t, health.Ready(time.Minute))
+	assert.Equal(t, 1, health.Watches()["persistentvolumeclaims"].Events)
+
+	health.WatchClosed("statefulsets")
+	assert.ErrorContains(t, health.Ready(time.Minute), "watch statefulsets is closed")
+}
+
+func TestHealthLive(t *testing.T) {
+	clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+	health := structInternal.NewHealth(clock)
+
+	// alive during the initial scan, before any watch started
+	assert.NoError(t, health.Live(5*time.Minute))
+
+	health.WatchStarted("statefulsets")
+	health.WatchStarted("persistentvolumeclaims")
+
+	// a closed watch that is retried is not ready, but still alive
+	clock.Advance(2 * time.Minute)
+	health.WatchClosed("statefulsets")
+	health.WatchAlive("statefulsets")
+	assert.Error(t, health.Ready(time.Minute))
+	assert.NoError(t, health.Live(5*time.Minute))
+
+	// a loop that stopped reporting in is restarted
+	clock.Advance(4 * time.Minute)
+	health.WatchAlive("statefulsets")
+	err := health.Live(5 * time.Minute)
+	assert.ErrorContains(t, err, "watch persistentvolumeclaims was last seen 6m0s ago")
+	assert.NotContains(t, err.Error(), "statefulsets")
+}
+
+func TestHealthHandler(t *testing.T) {
+	kube := testInternal.NewFakeClient()
+
+	labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+	if namespaceErr := kube.CreateNamespace(context.TODO(), "ns1", labels); namespaceErr != nil {
+		t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+	}
+	for _, name := range []string{"pvc1", "pvc2"} {
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "ns1"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+	}
+	SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "2025-07-01_12-00-00Z", "ns1", "pvc1")
+	SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "1", "ns1", "pvc1")
+
+	cfg := structInternal.ControllerConfig{
+		Scope:           structInternal.NamespaceScope{Selector: structInternal.DefaultNamespaceSelector},
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		WatchStaleness:  time.Minute,
+	}
+
+	health := structInternal.NewHealth(structInternal.RealClock{})
+	handler := healthHandler(kube, structInternal.NewLive(cfg), health)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	t.Run("alive before the initial scan", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/healthz").Code)
+		assert.Equal(t, http.StatusOK, get("/livez").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+	})
+
+	t.Run("ready once synced and watching", func(t *testing.T) {
+		health.SetSynced()
+		health.WatchStarted("statefulsets")
+		assert.Equal(t, http.StatusOK, get("/readyz").Code)
+	})
+
+	t.Run("debug state lists the labelled pvcs", func(t *testing.T) {
+		recorder := get("/debug/state")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var state debugState
+		if err := json.Unmarshal(recorder.Body.Bytes(), &state); err != nil {
+			t.Fatalf("Error decoding debug state: %v", err)
+		}
+		assert.True(t, state.Synced)
+		assert.Contains(t, state.Watches, "statefulsets")
+		assert.Equal(t, []trackedPvc{{
+			Namespace: "ns1",
+			Name:      "pvc1",
+			State: structInternal.VolumeState{
+				DetachedAt:        time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC),
+				NotificationsSent: []structInternal.Notification{{Channel: structInternal.EmailChannel}},
+			},
+		}}, state.Pvcs)
+	})
+}
+
+func TestWatchEventsReopen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	health := structInternal.NewHealth(structInternal.RealClock{})
+
+	first := watch.NewFake()
+	second := watch.NewFake()
+
+	reopened := make(chan string, 1)
+	open := func(resourceVersion string) (watch.Interface, error) {
+		reopened <- resourceVersion
+		return second, nil
+	}
+
+	handled := make(chan string, 2)
+	go watchEvents(ctx, "persistentvolumeclaims", health, first, open, func(event watch.Event) {
+		handled <- event.Object.(*corev1.PersistentVolumeClaim).Name
+	})
+
+	pvc := func(name string, resourceVersion string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: resourceVersion}}
+	}
+
+	first.Add(pvc("pvc1", "10"))
+	assert.Equal(t, "pvc1", <-handled)
+
+	// the api server ends the watch, it continues from the last event seen
+	first.Stop()
+	assert.Equal(t, "10", <-reopened)
+
+	second.Add(pvc("pvc2", "11"))
+	assert.Equal(t, "pvc2", <-handled)
+
+	state := health.Watches()["persistentvolumeclaims"]
+	assert.Equal(t, 2, state.Events)
+	assert.Equal(t, 1, state.Restarts)
+	assert.False(t, state.Closed)
+}
+
+func TestWatchEventsReopenRetried(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	health := structInternal.NewHealth(structInternal.RealClock{})
+
+	first := watch.NewFake()
+	second := watch.NewFake()
+
+	// the resource version expired, then the api server is briefly unavailable
+	failures := []error{
+		apierrors.NewResourceExpired("too old resource version: 10"),
+		apierrors.NewServiceUnavailable("etcd is restarting"),
+	}
+	reopened := make(chan string, 3)
+	open := func(resourceVersion string) (watch.Interface, error) {
+		reopened <- resourceVersion
+		if len(failures) > 0 {
+			err := failures[0]
+			failures = failures[1:]
+			return nil, err
+		}
+		return second, nil
+	}
+
+	handled := make(chan string, 2)
+	go watchEvents(ctx, "persistentvolumeclaims", health, first, open, func(event watch.Event) {
+		handled <- event.Object.(*corev1.PersistentVolumeClaim).Name
+	})
+
+	first.Add(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc1", ResourceVersion: "10"}})
+	assert.Equal(t, "pvc1", <-handled)
+
+	first.Stop()
+	assert.Equal(t, "10", <-reopened)
+	// watched from the current state once the resource version expired
+	assert.Equal(t, "", <-reopened)
+	assert.True(t, health.Watches()["persistentvolumeclaims"].Closed)
+	assert.Equal(t, "", <-reopened)
+
+	second.Add(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc1", ResourceVersion: "12"}})
+	assert.Equal(t, "pvc1", <-handled)
+
+	state := health.Watches()["persistentvolumeclaims"]
+	assert.Equal(t, 1, state.Restarts)
+	assert.False(t, state.Closed)
+}
@@ -3,6 +3,7 @@ package kubernetes
 import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 // go client used to interact with k8s clusters
@@ -17,3 +18,33 @@ func InitKubeClient() (*kubernetes.Clientset, error) {
 	}
 	return nil, err
 }
+
+// in-cluster client whose requests are limited to qps per second by client-go itself
+// a qps of 0 keeps the client-go defaults
+
+func InitRateLimitedKubeClient(qps float64, burst int) (*kubernetes.Clientset, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if qps > 0 {
+		cfg.QPS = float32(qps)
+		cfg.Burst = burst
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// go client used by the cli, which runs outside the cluster
+// an empty path uses $KUBECONFIG or ~/.kube/config like kubectl
+
+func InitKubeClientFromKubeconfig(path string) (*kubernetes.Clientset, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
@@ -3,40 +3,286 @@ package kubernetes
 import (
 	// standard packages
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 
 	// external packages
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
 )
 
-// modifies pvc labels
+// modifies pvc labels or annotations (field is either "labels" or "annotations")
 // requires sufficient rbac permissions
-func patchPvcLabel(kube kubernetes.Interface, label string, value string, ns string, pvc string) {
-	patch := []byte(fmt.Sprintf(`{"metadata":{"labels":{"%s":%s}}}`, label, value))
+func patchPvcMetadata(ctx context.Context, kube kubernetes.Interface, field string, key string, value string, ns string, pvc string) {
+	patchPvc(ctx, kube, metadataPatch(field, key, value), ns, pvc)
+}
+
+// applies a json merge patch to a pvc
+func patchPvc(ctx context.Context, kube kubernetes.Interface, patch []byte, ns string, pvc string) error {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
 	_, err := kube.CoreV1().PersistentVolumeClaims(ns).Patch(
-		context.TODO(),
+		callCtx,
 		pvc,
 		types.MergePatchType,
 		patch,
 		metav1.PatchOptions{},
 	)
+	if apierrors.IsConflict(err) {
+		log.Printf("[INFO] PVC %s from NS %s changed since it was read.", pvc, ns)
+		return err
+	}
 	if err != nil {
 		log.Printf("[ERROR] Failed to patch PVC %s from NS %s: %s", pvc, ns, err)
-		return
+		return err
 	}
 
 	log.Printf("[INFO] Patch successfully applied to PVC %s from NS %s", pvc, ns)
+	return nil
+}
+
+// modifies pv labels, pvs are not namespaced
+func patchPvMetadata(ctx context.Context, kube kubernetes.Interface, field string, key string, value string, pv string) {
+	patchPv(ctx, kube, metadataPatch(field, key, value), pv)
+}
+
+// applies a json merge patch to a pv
+func patchPv(ctx context.Context, kube kubernetes.Interface, patch []byte, pv string) error {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	_, err := kube.CoreV1().PersistentVolumes().Patch(
+		callCtx,
+		pv,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{},
+	)
+	if apierrors.IsConflict(err) {
+		log.Printf("[INFO] PV %s changed since it was read.", pv)
+		return err
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to patch PV %s: %s", pv, err)
+		return err
+	}
+
+	log.Printf("[INFO] Patch successfully applied to PV %s", pv)
+	return nil
+}
+
+// value must already be json encoded
+func metadataPatch(field string, key string, value string) []byte {
+	return []byte(fmt.Sprintf(`{"metadata":{"%s":{"%s":%s}}}`, field, key, value))
+}
+
+// writes the state annotation and the time label in a single patch, the notification count label
+// of earlier versions is removed at the same time. a nil state removes all three, along with the
+// admission annotation that only matters while the volume is marked (see admission.go)
+//
+// the state is always written based on what was read before, so the patch carries the resource
+// version of that read. the api server rejects it with a conflict when the volume changed in between,
+// e.g. the controller removing the state of a pvc that was attached again while the scheduler records
+// a notification. an empty resource version writes unconditionally
+func statePatch(keys structInternal.StateKeys, state *structInternal.VolumeState, resourceVersion string) []byte {
+	labels, annotations := stateMetadata(keys, state)
+	return guardedPatch(labels, annotations, resourceVersion)
+}
+
+// same as statePatch, the usage annotation is written along with it. the state is only written
+// when reset is set, so that usage is recorded on pvcs that are not marked as well
+func usagePatch(keys structInternal.StateKeys, annotation string, value string, state *structInternal.VolumeState, reset bool, resourceVersion string) []byte {
+	labels, annotations := map[string]any{}, map[string]any{}
+	if reset {
+		labels, annotations = stateMetadata(keys, state)
+	}
+	annotations[annotation] = value
+	return guardedPatch(labels, annotations, resourceVersion)
+}
+
+// returns the labels and annotations holding a state, a nil state removes them
+func stateMetadata(keys structInternal.StateKeys, state *structInternal.VolumeState) (map[string]any, map[string]any) {
+	labels := map[string]any{keys.TimeLabel: nil, keys.NotifLabel: nil}
+	annotations := map[string]any{keys.StateAnnotation: nil}
+	if state != nil {
+		labels[keys.TimeLabel] = keys.TimeValue(*state)
+		annotations[keys.StateAnnotation] = state.Encode()
+	} else {
+		annotations[admissionAnnotation] = nil
+	}
+	return labels, annotations
+}
+
+// builds a json merge patch of labels and annotations, guarded by the resource version when it is set
+func guardedPatch(labels map[string]any, annotations map[string]any, resourceVersion string) []byte {
+	metadata := map[string]any{"annotations": annotations}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if resourceVersion != "" {
+		metadata["resourceVersion"] = resourceVersion
+	}
+
+	// only strings and nulls, marshalling cannot fail
+	patch, _ := json.Marshal(map[string]any{"metadata": metadata})
+	return patch
+}
+
+// setting label will add it if doesn't exist
+func SetPvcLabel(ctx context.Context, kube kubernetes.Interface, label string, value string, ns string, pvc string) {
+	patchPvcMetadata(ctx, kube, "labels", label, fmt.Sprintf(`"%s"`, value), ns, pvc)
+}
+
+// setting label to null (not "null") will remove it
+func RemovePvcLabel(ctx context.Context, kube kubernetes.Interface, label string, ns string, pvc string) {
+	patchPvcMetadata(ctx, kube, "labels", label, "null", ns, pvc)
+}
+
+// setting annotation will add it if doesn't exist
+func SetPvcAnnotation(ctx context.Context, kube kubernetes.Interface, annotation string, value string, ns string, pvc string) {
+	patchPvcMetadata(ctx, kube, "annotations", annotation, fmt.Sprintf(`"%s"`, value), ns, pvc)
 }
 
 // setting label will add it if doesn't exist
-func SetPvcLabel(kube kubernetes.Interface, label string, value string, ns string, pvc string) {
-	patchPvcLabel(kube, label, fmt.Sprintf(`"%s"`, value), ns, pvc)
+func SetPvLabel(ctx context.Context, kube kubernetes.Interface, label string, value string, pv string) {
+	patchPvMetadata(ctx, kube, "labels", label, fmt.Sprintf(`"%s"`, value), pv)
 }
 
 // setting label to null (not "null") will remove it
-func RemovePvcLabel(kube kubernetes.Interface, label string, ns string, pvc string) {
-	patchPvcLabel(kube, label, "null", ns, pvc)
+func RemovePvLabel(ctx context.Context, kube kubernetes.Interface, label string, pv string) {
+	patchPvMetadata(ctx, kube, "labels", label, "null", pv)
+}
+
+// marks a pvc as unattached with the given state
+// fails with a conflict if the pvc changed since it was read
+func SetPvcState(ctx context.Context, kube kubernetes.Interface, keys structInternal.StateKeys, state structInternal.VolumeState, pvc corev1.PersistentVolumeClaim) error {
+	return patchPvc(ctx, kube, statePatch(keys, &state, pvc.ResourceVersion), pvc.Namespace, pvc.Name)
+}
+
+// records when a pvc was last mounted. when reset is set, state is written in the same patch
+// fails with a conflict if the pvc changed since it was read
+func SetPvcUsage(ctx context.Context, kube kubernetes.Interface, keys structInternal.StateKeys, annotation string, value string, state structInternal.VolumeState, reset bool, pvc corev1.PersistentVolumeClaim) error {
+	return patchPvc(ctx, kube, usagePatch(keys, annotation, value, &state, reset, pvc.ResourceVersion), pvc.Namespace, pvc.Name)
+}
+
+// removes the state of a pvc that is attached again
+// fails with a conflict if the pvc changed since it was read
+func RemovePvcState(ctx context.Context, kube kubernetes.Interface, keys structInternal.StateKeys, pvc corev1.PersistentVolumeClaim) error {
+	return patchPvc(ctx, kube, statePatch(keys, nil, pvc.ResourceVersion), pvc.Namespace, pvc.Name)
+}
+
+// marks a pv as orphaned with the given state
+// fails with a conflict if the pv changed since it was read
+func SetPvState(ctx context.Context, kube kubernetes.Interface, keys structInternal.StateKeys, state structInternal.VolumeState, pv corev1.PersistentVolume) error {
+	return patchPv(ctx, kube, statePatch(keys, &state, pv.ResourceVersion), pv.Name)
+}
+
+// removes the state of a pv that is bound again
+// fails with a conflict if the pv changed since it was read
+func RemovePvState(ctx context.Context, kube kubernetes.Interface, keys structInternal.StateKeys, pv corev1.PersistentVolume) error {
+	return patchPv(ctx, kube, statePatch(keys, nil, pv.ResourceVersion), pv.Name)
+}
+
+// runs update on pvc. when update fails because the pvc changed since it was read, the pvc is
+// read again and update runs on the latest version, so every decision is made on what is stored
+func retryPvc(ctx context.Context, kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim, update func(pvc corev1.PersistentVolumeClaim) error) error {
+	stale := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if stale {
+			callCtx, cancel := utilsInternal.CallContext(ctx)
+			defer cancel()
+			latest, err := kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(callCtx, pvc.Name, metav1.GetOptions{})
+			if err != nil {
+				log.Printf("[ERROR] Failed to read PVC %s from NS %s again: %s", pvc.Name, pvc.Namespace, err)
+				return err
+			}
+			pvc = *latest
+		}
+		stale = true
+		return update(pvc)
+	})
+}
+
+// runs update on pv, reading the pv again when it changed since it was read (see retryPvc)
+func retryPv(ctx context.Context, kube kubernetes.Interface, pv corev1.PersistentVolume, update func(pv corev1.PersistentVolume) error) error {
+	stale := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if stale {
+			callCtx, cancel := utilsInternal.CallContext(ctx)
+			defer cancel()
+			latest, err := kube.CoreV1().PersistentVolumes().Get(callCtx, pv.Name, metav1.GetOptions{})
+			if err != nil {
+				log.Printf("[ERROR] Failed to read PV %s again: %s", pv.Name, err)
+				return err
+			}
+			pv = *latest
+		}
+		stale = true
+		return update(pv)
+	})
+}
+
+// removes the state of a pvc if it still has one and records why. returns true if the state was removed
+func clearPvcState(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, pvc corev1.PersistentVolumeClaim, reason string) bool {
+	keys := cfg.StateKeys()
+
+	removed := false
+	retryPvc(ctx, kube, pvc, func(latest corev1.PersistentVolumeClaim) error {
+		removed = false
+		if !hasState(keys, latest.Labels, latest.Annotations) {
+			return nil
+		}
+		err := RemovePvcState(ctx, kube, keys, latest)
+		removed = err == nil
+		pvc = latest
+		return err
+	})
+
+	if removed {
+		RecordAudit(ctx, kube, cfg.Audit, PvcAuditEntry(cfg.Clock.Now(), structInternal.ActorController, structInternal.AuditUnmark, pvc, reason))
+	}
+	return removed
+}
+
+// returns true if any of the state keys is set, including the labels of earlier versions
+func hasState(keys structInternal.StateKeys, labels map[string]string, annotations map[string]string) bool {
+	_, hasTime := labels[keys.TimeLabel]
+	_, hasNotif := labels[keys.NotifLabel]
+	_, hasState := annotations[keys.StateAnnotation]
+	return hasTime || hasNotif || hasState
+}
+
+// adds days to the grace period of an unattached pvc and returns the pvc as it was extended
+// fails if the pvc is not marked as unattached, there is no grace period to extend
+
+func ExtendPvc(ctx context.Context, kube kubernetes.Interface, keys structInternal.StateKeys, ns string, name string, extension structInternal.Extension) (corev1.PersistentVolumeClaim, error) {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	pvcObj, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(callCtx, name, metav1.GetOptions{})
+	if err != nil {
+		return corev1.PersistentVolumeClaim{}, err
+	}
+
+	pvc := *pvcObj
+	err = retryPvc(ctx, kube, pvc, func(latest corev1.PersistentVolumeClaim) error {
+		pvc = latest
+		state, ok, _, err := keys.Read(latest.Labels, latest.Annotations)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("PVC %s in NS %s is not marked as unattached", name, ns)
+		}
+		state.Extensions = append(state.Extensions, extension)
+		return SetPvcState(ctx, kube, keys, state, latest)
+	})
+	return pvc, err
 }
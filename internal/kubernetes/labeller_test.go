@@ -4,11 +4,19 @@ import (
 	// standard packages
 	"context"
 	"testing"
+	"time"
 
 	// external packages
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	// internal packages
+	structInternal "volume-cleaner/internal/structure"
 	testInternal "volume-cleaner/internal/utils"
 )
 
@@ -28,27 +36,116 @@ func TestLabelFunctions(t *testing.T) {
 		}
 
 		// test adding new label
-		SetPvcLabel(kube, "volume-cleaner/unattached-time", "foo", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "foo", "test", "pvc1")
 
-		assert.Equal(t, PvcList(kube, "test")[0].Labels["volume-cleaner/unattached-time"], "foo")
+		assert.Equal(t, listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"], "foo")
 
 		// test changing existing label
-		SetPvcLabel(kube, "volume-cleaner/unattached-time", "bar", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "bar", "test", "pvc1")
 
-		assert.Equal(t, PvcList(kube, "test")[0].Labels["volume-cleaner/unattached-time"], "bar")
+		assert.Equal(t, listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"], "bar")
 
 		// test removing label
-		RemovePvcLabel(kube, "volume-cleaner/unattached-time", "test", "pvc1")
+		RemovePvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "test", "pvc1")
 
-		_, ok := PvcList(kube, "test")[0].Labels["volume-cleaner/unattached-time"]
+		_, ok := listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"]
 
 		assert.Equal(t, ok, false)
 
 		// test 2 new label
-		SetPvcLabel(kube, "volume-cleaner/unattached-time", "foo", "test", "pvc1")
-		SetPvcLabel(kube, "volume-cleaner/notification-count", "0", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "foo", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/notification-count", "0", "test", "pvc1")
 
-		assert.Equal(t, PvcList(kube, "test")[0].Labels["volume-cleaner/unattached-time"], "foo")
-		assert.Equal(t, PvcList(kube, "test")[0].Labels["volume-cleaner/notification-count"], "0")
+		assert.Equal(t, listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"], "foo")
+		assert.Equal(t, listPvcs(t, kube, "test")[0].Labels["volume-cleaner/notification-count"], "0")
+
+		// test adding annotation, labels are untouched
+		SetPvcAnnotation(context.TODO(), kube, "volume-cleaner/last-mounted", "2025-07-01_12-00-00Z", "test", "pvc1")
+
+		assert.Equal(t, listPvcs(t, kube, "test")[0].Annotations["volume-cleaner/last-mounted"], "2025-07-01_12-00-00Z")
+		assert.Equal(t, listPvcs(t, kube, "test")[0].Labels["volume-cleaner/notification-count"], "0")
+	})
+}
+
+func TestPvLabelFunctions(t *testing.T) {
+
+	t.Run("test add, remove pv labels", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		if pvErr := kube.CreatePersistentVolume(context.TODO(), "pv1", "test", "pvc1"); pvErr != nil {
+			t.Fatalf("Error injecting pv add: %v", pvErr)
+		}
+
+		SetPvLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "foo", "pv1")
+		assert.Equal(t, listPvs(t, kube)[0].Labels["volume-cleaner/unattached-time"], "foo")
+
+		RemovePvLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "pv1")
+		_, ok := listPvs(t, kube)[0].Labels["volume-cleaner/unattached-time"]
+		assert.Equal(t, ok, false)
+	})
+}
+
+func TestStateConflicts(t *testing.T) {
+	keys := structInternal.StateKeys{
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+	}
+	state := structInternal.VolumeState{DetachedAt: time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)}
+
+	t.Run("state patches carry the resource version they were read at", func(t *testing.T) {
+		assert.Contains(t, string(statePatch(keys, &state, "42")), `"resourceVersion":"42"`)
+		assert.NotContains(t, string(statePatch(keys, nil, "")), "resourceVersion")
+	})
+
+	t.Run("a conflicting write is decided again on the latest pvc", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		listed := getPvc(t, kube, "test", "pvc1")
+
+		// a sts picks the pvc up and its state is removed just before the write lands
+		clientset := kube.Interface.(*fake.Clientset)
+		conflicts := 0
+		clientset.PrependReactor("patch", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if conflicts > 0 {
+				return false, nil, nil
+			}
+			conflicts++
+
+			gvr := corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims")
+			obj, err := clientset.Tracker().Get(gvr, "test", "pvc1")
+			if err != nil {
+				return true, nil, err
+			}
+			pvc := obj.(*corev1.PersistentVolumeClaim)
+			pvc.Labels = map[string]string{"volume-cleaner/unattached-time": "2025-06-01_00-00-00Z"}
+			if err := clientset.Tracker().Update(gvr, pvc, "test"); err != nil {
+				return true, nil, err
+			}
+
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "persistentvolumeclaims"}, "pvc1", nil)
+		})
+
+		cfg := structInternal.ControllerConfig{
+			TimeLabel:       keys.TimeLabel,
+			NotifLabel:      keys.NotifLabel,
+			StateAnnotation: keys.StateAnnotation,
+			TimeFormat:      keys.TimeFormat,
+			Clock:           testInternal.NewFakeClock(state.DetachedAt),
+		}
+
+		// the latest pvc already has a state of its own, it is migrated instead of starting over
+		assert.False(t, ensureState(context.TODO(), kube, cfg, listed, ""))
+		assert.Equal(t, 1, conflicts)
+		assert.Equal(t, time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC), pvcState(t, kube, "test", "pvc1").DetachedAt)
 	})
 }
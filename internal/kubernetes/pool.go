@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"sync"
+)
+
+// runs process on every item using at most workers goroutines
+// results are handed to collect one at a time on the calling goroutine, so collect can update
+// shared state (e.g. the run report) without locking. a single worker keeps the order of the items
+// once ctx is done no new item is started, items already running finish. returns the number of items
+// that were never started
+
+func processConcurrently[T any, R any](ctx context.Context, workers int, items []T, process func(T) R, collect func(T, R)) int {
+	if workers < 1 {
+		workers = 1
+	}
+
+	type result struct {
+		item  T
+		value R
+	}
+
+	jobs := make(chan T)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for range min(workers, len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				results <- result{item: item, value: process(item)}
+			}
+		}()
+	}
+
+	// feed the workers, results is closed once every started item has been processed
+	started := 0
+	go func() {
+		defer func() {
+			close(jobs)
+			wg.Wait()
+			close(results)
+		}()
+		for _, item := range items {
+			// checked first, select picks randomly when a worker is also free
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- item:
+				started++
+			}
+		}
+	}()
+
+	for r := range results {
+		collect(r.item, r.value)
+	}
+
+	// safe to read, the feeder finished before results was closed
+	return len(items) - started
+}
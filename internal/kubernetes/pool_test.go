@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessConcurrently(t *testing.T) {
+
+	t.Run("every item is collected once with bounded workers", func(t *testing.T) {
+		items := make([]int, 50)
+		for i := range items {
+			items[i] = i
+		}
+
+		var running, peak atomic.Int32
+		collected := make(map[int]int)
+
+		processConcurrently(context.Background(), 4, items, func(item int) int {
+			current := running.Add(1)
+			for {
+				previous := peak.Load()
+				if current <= previous || peak.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return item * 2
+		}, func(item int, value int) {
+			// collect runs on the calling goroutine, no locking needed
+			collected[item] = value
+		})
+
+		assert.Len(t, collected, 50)
+		assert.Equal(t, 98, collected[49])
+		assert.LessOrEqual(t, peak.Load(), int32(4))
+		assert.Greater(t, peak.Load(), int32(1))
+	})
+
+	t.Run("a single worker keeps the order", func(t *testing.T) {
+		order := make([]string, 0)
+		processConcurrently(context.Background(), 0, []string{"a", "b", "c"}, func(item string) string {
+			return item
+		}, func(_ string, value string) {
+			order = append(order, value)
+		})
+		assert.Equal(t, []string{"a", "b", "c"}, order)
+	})
+
+	t.Run("a cancelled context starts no new items", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		collected := make([]int, 0)
+		skipped := processConcurrently(ctx, 1, []int{1, 2, 3, 4}, func(item int) int {
+			// the item in progress still finishes after cancellation
+			if item == 2 {
+				cancel()
+			}
+			return item
+		}, func(_ int, value int) {
+			collected = append(collected, value)
+		})
+
+		assert.Equal(t, []int{1, 2}, collected)
+		assert.Equal(t, 2, skipped)
+	})
+
+	t.Run("no items", func(t *testing.T) {
+		processConcurrently(context.Background(), 4, []int{}, func(item int) int {
+			return item
+		}, func(int, int) {
+			t.Fatal("nothing should be collected")
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"log"
+	"time"
+
+	// external packages
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// periodically recomputes the attached and unattached pvcs so that label state heals itself
+// when the watchers miss events. the interval is read from the live config after every run so it can be
+// reloaded, a zero interval pauses reconciliation until it is set again
+
+func ReconcileLoop(ctx context.Context, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig]) {
+	// how long to wait before checking again while reconciliation is disabled
+	const pausedCheck = time.Minute
+
+	for {
+		interval := live.Get().ReconcileInterval
+		wait := interval
+		if interval <= 0 {
+			wait = pausedCheck
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if interval <= 0 || live.Get().ReconcileInterval <= 0 {
+			continue
+		}
+
+		Reconcile(ctx, kube, live.Get())
+	}
+}
+
+// adds missing labels to unattached pvcs and removes labels from attached pvcs in every managed namespace
+
+func Reconcile(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig) structInternal.DriftReport {
+	log.Print("[INFO] Starting reconciliation...")
+
+	report := structInternal.DriftReport{}
+
+	namespaces, err := NsList(ctx, kube, cfg.Scope)
+	if err != nil {
+		log.Printf("[ERROR] Reconciliation skipped: %s", err)
+		report.Errors++
+		return report
+	}
+
+	// a namespace that was started is labelled to the end, each call keeps its own timeout
+	work := context.WithoutCancel(ctx)
+
+	for _, namespace := range namespaces {
+		// on shutdown the namespace in progress is finished, the rest waits for the next start
+		if ctx.Err() != nil {
+			log.Print("[INFO] Reconciliation stopped.")
+			break
+		}
+
+		// skip if not in configured namespace
+		if namespace.Name != cfg.Namespace && cfg.Namespace != "" {
+			continue
+		}
+
+		report.Namespaces++
+
+		scan, err := scanNamespace(work, kube, cfg, namespace.Name)
+		if err != nil {
+			log.Printf("[ERROR] Skipping namespace %s: %s", namespace.Name, err)
+			report.Errors++
+			continue
+		}
+		report.Scanned += len(scan.pvcs)
+
+		for name := range scan.unattached.GetSet() {
+			pvc := scan.pvcs[name]
+
+			if rule, ok := cfg.Protection.Protects(pvc.ObjectMeta); ok {
+				report.Protected = append(report.Protected, structInternal.ProtectedVolume{Namespace: pvc.Namespace, Name: pvc.Name, Rule: rule})
+			}
+
+			if ensureState(work, kube, cfg, pvc, "") {
+				log.Printf("[INFO][DRIFT] Labelled unattached PVC %s", pvc.Name)
+				report.Labelled++
+			}
+		}
+
+		for name := range scan.attached.GetSet() {
+			pvc := scan.pvcs[name]
+
+			if clearPvcState(work, kube, cfg, pvc, "mounted by a workload, found by the reconciliation") {
+				log.Printf("[INFO][DRIFT] Removed stale label %s and state from attached PVC %s", cfg.TimeLabel, pvc.Name)
+				report.Unlabelled++
+			}
+		}
+
+		// refresh usage of mounted pvcs in case the pod and volume attachment watchers missed an event
+		if cfg.UsageAnnotation != "" {
+			report.Mounted += RecordMountedUsage(work, kube, cfg, namespace.Name)
+		}
+	}
+
+	// the watchers record entries between passes, they are counted by the next pass
+	report.AuditErrors = takeAuditFailures()
+
+	log.Printf("[INFO] Reconciliation complete. Namespaces: %d, PVCs scanned: %d, labels added: %d, labels removed: %d, mounted PVCs: %d, protected PVCs: %d, errors: %d, audit entries not recorded: %d",
+		report.Namespaces, report.Scanned, report.Labelled, report.Unlabelled, report.Mounted, len(report.Protected), report.Errors, report.AuditErrors)
+
+	return report
+}
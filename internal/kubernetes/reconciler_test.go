@@ -0,0 +1,107 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+func TestReconcile(t *testing.T) {
+
+	t.Run("drifted labels are corrected", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		for _, name := range []string{"pvc1", "pvc2", "pvc3"} {
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "test"); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+		}
+
+		cfg := structInternal.ControllerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           testInternal.NewFakeClock(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)),
+		}
+
+		// pvc1 is attached but still labelled, pvc2 is unattached without labels
+		// pvc3 is unattached and already labelled
+		SetPvcLabel(context.TODO(), kube, cfg.TimeLabel, "2025-06-01_00-00-00Z", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, cfg.NotifLabel, "1", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, cfg.TimeLabel, "2025-06-01_00-00-00Z", "test", "pvc3")
+		SetPvcLabel(context.TODO(), kube, cfg.NotifLabel, "2", "test", "pvc3")
+
+		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc1"); stsErr != nil {
+			t.Fatalf("Error injecting sts add: %v", stsErr)
+		}
+
+		report := Reconcile(context.TODO(), kube, cfg)
+
+		assert.Equal(t, structInternal.DriftReport{Namespaces: 1, Scanned: 3, Labelled: 1, Unlabelled: 1}, report)
+		assert.Equal(t, 2, report.Drift())
+
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc1", cfg.TimeLabel))
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc1", cfg.NotifLabel))
+		assert.Equal(t, "", pvcAnnotation(kube, "test", "pvc1", cfg.StateAnnotation))
+		assert.Equal(t, "2025-07-01_12-00-00Z", pvcLabel(kube, "test", "pvc2", cfg.TimeLabel))
+		assert.Empty(t, pvcState(t, kube, "test", "pvc2").NotificationsSent)
+
+		// existing labels are migrated, not counted as drift
+		assert.Equal(t, "2025-06-01_00-00-00Z", pvcLabel(kube, "test", "pvc3", cfg.TimeLabel))
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc3", cfg.NotifLabel))
+		assert.Len(t, pvcState(t, kube, "test", "pvc3").NotificationsSent, 2)
+
+		// a second run finds nothing to correct
+		assert.Equal(t, 0, Reconcile(context.TODO(), kube, cfg).Drift())
+	})
+}
+
+func TestReconcileLoop(t *testing.T) {
+
+	t.Run("reconciliation runs on the configured interval", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		cfg := structInternal.ControllerConfig{
+			Namespace:         "test",
+			TimeLabel:         "volume-cleaner/unattached-time",
+			NotifLabel:        "volume-cleaner/notification-count",
+			StateAnnotation:   "volume-cleaner/state",
+			TimeFormat:        "2006-01-02_15-04-05Z",
+			ReconcileInterval: 50 * time.Millisecond,
+			Clock:             structInternal.RealClock{},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go ReconcileLoop(ctx, kube, structInternal.NewLive(cfg))
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.NotEqual(t, "", pvcAnnotation(kube, "test", "pvc1", cfg.StateAnnotation))
+	})
+}
@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	// external packages
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+// holds the url of the archive a pvc was restored from
+const restoredFromAnnotation = "volume-cleaner/restored-from"
+
+// recreates an archived pvc with its original name, storage class and size, and extracts the archive
+// into it with a job like the one that uploaded it
+// a pvc that fails to restore is deleted again, so the restore can simply be run once more
+
+func RestorePvc(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ArchiveConfig, clock structInternal.Clock, record structInternal.ArchiveRecord) (*corev1.PersistentVolumeClaim, error) {
+	key, err := utilsInternal.ArchiveKeyFromReference(cfg, record.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err := restoredPvc(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// valid for as long as the job may run
+	download, err := utilsInternal.PresignGet(cfg, key, clock.Now(), cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	created, err := kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(callCtx, pvc, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("PVC %s already exists in NS %s, delete or rename it to restore the archive", pvc.Name, pvc.Namespace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PVC %s in NS %s: %w", pvc.Name, pvc.Namespace, err)
+	}
+
+	log.Printf("[INFO] Restoring PVC %s in NS %s from %s", pvc.Name, pvc.Namespace, record.URL)
+
+	if err := fillPvc(ctx, kube, cfg, *created, download, record.SHA256); err != nil {
+		// kubernetes keeps the pvc until the pod of the job is gone
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		deleteErr := kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(callCtx, pvc.Name, metav1.DeleteOptions{})
+		return nil, errors.Join(err, deleteErr)
+	}
+
+	return created, nil
+}
+
+// returns the pvc described by an archive record
+
+func restoredPvc(record structInternal.ArchiveRecord) (*corev1.PersistentVolumeClaim, error) {
+	if record.Namespace == "" || record.Name == "" || record.SHA256 == "" {
+		return nil, fmt.Errorf("archive record of %s is incomplete", record.URL)
+	}
+
+	capacity, err := resource.ParseQuantity(record.Capacity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capacity %q in archive record of %s: %w", record.Capacity, record.URL, err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        record.Name,
+			Namespace:   record.Namespace,
+			Annotations: map[string]string{restoredFromAnnotation: record.URL},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: capacity},
+			},
+		},
+	}
+
+	// an empty storage class would turn off dynamic provisioning, so the default class is used instead
+	if record.StorageClass != "" {
+		pvc.Spec.StorageClassName = &record.StorageClass
+	}
+
+	for _, mode := range record.AccessModes {
+		pvc.Spec.AccessModes = append(pvc.Spec.AccessModes, corev1.PersistentVolumeAccessMode(mode))
+	}
+	if len(pvc.Spec.AccessModes) == 0 {
+		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+
+	return pvc, nil
+}
+
+// runs the job extracting the archive into the pvc and checks it read the archive that was uploaded
+
+func fillPvc(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ArchiveConfig, pvc corev1.PersistentVolumeClaim, download string, checksum string) error {
+	restore := archiverJob(cfg, pvc, false, []corev1.EnvVar{
+		{Name: "ARCHIVE_TARGET", Value: archiveSource},
+		{Name: "ARCHIVE_URL", Value: download},
+		{Name: "ARCHIVE_SHA256", Value: checksum},
+	})
+	restore.GenerateName = "volume-cleaner-restore-"
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	job, err := kube.BatchV1().Jobs(pvc.Namespace).Create(callCtx, restore, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create restore job: %w", err)
+	}
+	defer deleteArchiveJob(ctx, kube, job)
+
+	result, err := waitArchiveJob(ctx, kube, cfg.Timeout, job)
+	if err != nil {
+		return err
+	}
+
+	// the archiver already compares them, a job reporting another checksum is not trusted either
+	if result.SHA256 != checksum {
+		return fmt.Errorf("job %s restored sha256 %s, expected %s", job.Name, result.SHA256, checksum)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+func TestRestorePvc(t *testing.T) {
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	cfg := structInternal.ArchiveConfig{
+		Endpoint:  "http://minio.das:9000",
+		Bucket:    "volume-archives",
+		Region:    "us-east-1",
+		AccessKey: "Random ACCESSKEY",
+		SecretKey: "Random SECRETKEY",
+		Image:     "volume-cleaner-scheduler:latest",
+		Timeout:   time.Hour,
+	}
+	clock := testInternal.NewFakeClock(time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC))
+	url := "http://minio.das:9000/volume-archives/test/pvc1/20250715T000000Z.tar.gz"
+	record := structInternal.ArchiveRecord{
+		Namespace:    "test",
+		Name:         "pvc1",
+		URL:          url,
+		SHA256:       checksum,
+		Size:         2048,
+		StorageClass: "standard",
+		Capacity:     "10Gi",
+		AccessModes:  []string{"ReadWriteMany"},
+	}
+
+	interval := archivePollInterval
+	archivePollInterval = time.Millisecond
+	t.Cleanup(func() { archivePollInterval = interval })
+
+	t.Run("pvc is recreated and filled from the archive", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		created := finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "`+checksum+`"}`)
+
+		pvc, err := RestorePvc(context.TODO(), kube, cfg, clock, record)
+		assert.NoError(t, err)
+
+		restored := getPvc(t, kube, "test", "pvc1")
+		assert.Equal(t, pvc.Name, restored.Name)
+		assert.Equal(t, "standard", *restored.Spec.StorageClassName)
+		assert.Equal(t, resource.MustParse("10Gi"), restored.Spec.Resources.Requests[corev1.ResourceStorage])
+		assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}, restored.Spec.AccessModes)
+		assert.Equal(t, url, restored.Annotations[restoredFromAnnotation])
+
+		// the job mounts the pvc read-write and only gets a presigned url
+		if assert.Len(t, *created, 1) {
+			spec := (*created)[0].Spec.Template.Spec
+			assert.False(t, spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+			assert.False(t, spec.Containers[0].VolumeMounts[0].ReadOnly)
+
+			env := spec.Containers[0].Env
+			assert.Equal(t, "ARCHIVE_TARGET", env[0].Name)
+			assert.Contains(t, env[1].Value, url+"?")
+			assert.Contains(t, env[1].Value, "X-Amz-Signature=")
+			assert.NotContains(t, env[1].Value, "SECRETKEY")
+			assert.Equal(t, corev1.EnvVar{Name: "ARCHIVE_SHA256", Value: checksum}, env[2])
+		}
+
+		jobs, _ := JobList(context.TODO(), kube, "test")
+		assert.Empty(t, jobs)
+	})
+
+	t.Run("pvc is removed when the job fails", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		finishArchiveJobs(kube, batchv1.JobFailed, "failed to download archive: 404 Not Found")
+
+		_, err := RestorePvc(context.TODO(), kube, cfg, clock, record)
+		assert.ErrorContains(t, err, "404 Not Found")
+		assert.Empty(t, listPvcs(t, kube, "test"))
+	})
+
+	t.Run("pvc is removed when the job restores another archive", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "0000"}`)
+
+		_, err := RestorePvc(context.TODO(), kube, cfg, clock, record)
+		assert.ErrorContains(t, err, "expected "+checksum)
+		assert.Empty(t, listPvcs(t, kube, "test"))
+	})
+
+	t.Run("existing pvcs are not replaced", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		created := finishArchiveJobs(kube, batchv1.JobComplete, `{"size": 2048, "sha256": "`+checksum+`"}`)
+		if _, err := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); err != nil {
+			t.Fatalf("Error injecting pvc add: %v", err)
+		}
+
+		_, err := RestorePvc(context.TODO(), kube, cfg, clock, record)
+		assert.ErrorContains(t, err, "already exists")
+		assert.Len(t, listPvcs(t, kube, "test"), 1)
+		assert.Empty(t, *created)
+	})
+
+	t.Run("archives of another bucket are refused", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		other := record
+		other.URL = "http://minio.das:9000/other-bucket/test/pvc1/20250715T000000Z.tar.gz"
+
+		_, err := RestorePvc(context.TODO(), kube, cfg, clock, other)
+		assert.ErrorContains(t, err, "not in bucket volume-archives")
+		assert.Empty(t, listPvcs(t, kube, "test"))
+	})
+}
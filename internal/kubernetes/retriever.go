@@ -3,147 +3,381 @@ package kubernetes
 import (
 	// standard packages
 	"context"
+	"fmt"
 	"log"
+	"strings"
 
 	// external packages
 	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
 	// internal packages
 	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
 )
 
-// returns a slice of corev1.Namespace structs
+// number of objects requested per page, keeps each response small on large clusters
+const listPageSize = 500
 
-func NsList(kube kubernetes.Interface) []corev1.Namespace {
-	ns, err := kube.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/part-of=kubeflow-profile",
-	})
-	if err != nil {
-		// nothing can be done without namespaces so crash the program
-		log.Fatalf("[ERROR] Failed to list namespaces: %s", err)
+// optional selectors narrowing a list call, evaluated by the api server
+// e.g. Selector{Label: "volume-cleaner/unattached-time"} only returns labelled objects
+
+type Selector struct {
+	Label string
+	Field string
+}
+
+// returned by the list functions when the api server could not be listed
+// callers can tell a failed list apart from an empty one
+
+type ListError struct {
+	Resource  string
+	Namespace string
+	Err       error
+}
+
+func (e *ListError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("failed to list %s: %s", e.Resource, e.Err)
+	}
+	return fmt.Sprintf("failed to list %s in namespace %s: %s", e.Resource, e.Namespace, e.Err)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// merges the selectors into list options, multiple selectors must all match
+
+func listOptions(selectors []Selector) metav1.ListOptions {
+	labelSelectors := make([]string, 0)
+	fieldSelectors := make([]string, 0)
+	for _, selector := range selectors {
+		if selector.Label != "" {
+			labelSelectors = append(labelSelectors, selector.Label)
+		}
+		if selector.Field != "" {
+			fieldSelectors = append(fieldSelectors, selector.Field)
+		}
 	}
-	if ns == nil {
-		return make([]corev1.Namespace, 0)
+
+	return metav1.ListOptions{
+		LabelSelector: strings.Join(labelSelectors, ","),
+		FieldSelector: strings.Join(fieldSelectors, ","),
+		Limit:         listPageSize,
 	}
+}
+
+// follows the continue tokens until every page has been listed
+// page lists a single page and returns its items along with the token of the next page
 
-	return ns.Items
+func listPages[T any](resource string, namespace string, opts metav1.ListOptions, page func(metav1.ListOptions) ([]T, string, error)) ([]T, error) {
+	items := make([]T, 0)
+
+	for {
+		pageItems, next, err := page(opts)
+		if err != nil {
+			return nil, &ListError{Resource: resource, Namespace: namespace, Err: err}
+		}
+		items = append(items, pageItems...)
+
+		if next == "" {
+			return items, nil
+		}
+		opts.Continue = next
+	}
 }
 
-// returns a slice of corev1.PersistentVolumeClaim structs in a given namespace
+// returns a slice of corev1.Namespace structs for the namespaces within the scope
 
-func PvcList(kube kubernetes.Interface, name string) []corev1.PersistentVolumeClaim {
-	pvcs, err := kube.CoreV1().PersistentVolumeClaims(name).List(context.TODO(), metav1.ListOptions{})
+func NsList(ctx context.Context, kube kubernetes.Interface, scope structInternal.NamespaceScope) ([]corev1.Namespace, error) {
+	opts := listOptions([]Selector{{Label: scope.Selector}})
+	namespaces, err := listPages("namespaces", "", opts, func(opts metav1.ListOptions) ([]corev1.Namespace, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.CoreV1().Namespaces().List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
 	if err != nil {
-		log.Printf("[ERROR] Failed to list volume claims: %s", err)
+		return nil, err
 	}
-	if pvcs == nil {
-		return make([]corev1.PersistentVolumeClaim, 0)
+
+	// include and exclude patterns cannot be expressed as selectors
+	managed := make([]corev1.Namespace, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		if scope.Matches(namespace.Name, namespace.Labels) {
+			managed = append(managed, namespace)
+		}
 	}
-	return pvcs.Items
+	return managed, nil
 }
 
-// returns a slice of appv1.StatefulSet structs in a given namespace
+// returns the names of the managed namespaces, only is the configured NAMESPACE and
+// narrows the result down to that single namespace when set
 
-func StsList(kube kubernetes.Interface, name string) []appv1.StatefulSet {
-	sts, err := kube.AppsV1().StatefulSets(name).List(context.TODO(), metav1.ListOptions{})
+func managedNamespaces(ctx context.Context, kube kubernetes.Interface, scope structInternal.NamespaceScope, only string) (map[string]bool, error) {
+	namespaces, err := NsList(ctx, kube, scope)
 	if err != nil {
-		log.Printf("[ERROR] Failed to list stateful sets: %s", err)
+		return nil, err
 	}
-	if sts == nil {
-		return make([]appv1.StatefulSet, 0)
+
+	managed := make(map[string]bool)
+	for _, namespace := range namespaces {
+		// skip if not in configured namespace
+		if namespace.Name != only && only != "" {
+			continue
+		}
+		managed[namespace.Name] = true
 	}
-	return sts.Items
+	return managed, nil
+}
+
+// returns true if the namespace is one returned by NsList
+
+func namespaceManaged(ctx context.Context, kube kubernetes.Interface, scope structInternal.NamespaceScope, name string) bool {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	ns, err := kube.CoreV1().Namespaces().Get(callCtx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[ERROR] Failed to get namespace %s: %s", name, err)
+		return false
+	}
+
+	return scope.Matches(ns.Name, ns.Labels)
+}
+
+// returns a slice of corev1.PersistentVolumeClaim structs in a given namespace
+// an empty namespace lists the claims of all namespaces
+
+func PvcList(ctx context.Context, kube kubernetes.Interface, name string, selectors ...Selector) ([]corev1.PersistentVolumeClaim, error) {
+	return listPages("persistent volume claims", name, listOptions(selectors), func(opts metav1.ListOptions) ([]corev1.PersistentVolumeClaim, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.CoreV1().PersistentVolumeClaims(name).List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
+}
+
+// returns a slice of corev1.PersistentVolume structs, pvs are not namespaced
+
+func PvList(ctx context.Context, kube kubernetes.Interface, selectors ...Selector) ([]corev1.PersistentVolume, error) {
+	return listPages("persistent volumes", "", listOptions(selectors), func(opts metav1.ListOptions) ([]corev1.PersistentVolume, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.CoreV1().PersistentVolumes().List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
+}
+
+// returns a slice of corev1.Pod structs in a given namespace
+
+func PodList(ctx context.Context, kube kubernetes.Interface, name string, selectors ...Selector) ([]corev1.Pod, error) {
+	return listPages("pods", name, listOptions(selectors), func(opts metav1.ListOptions) ([]corev1.Pod, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.CoreV1().Pods(name).List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
+}
+
+// returns a slice of appv1.StatefulSet structs in a given namespace
+
+func StsList(ctx context.Context, kube kubernetes.Interface, name string, selectors ...Selector) ([]appv1.StatefulSet, error) {
+	return listPages("stateful sets", name, listOptions(selectors), func(opts metav1.ListOptions) ([]appv1.StatefulSet, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.AppsV1().StatefulSets(name).List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
+}
+
+// returns a slice of appv1.Deployment structs in a given namespace
+
+func DeploymentList(ctx context.Context, kube kubernetes.Interface, name string, selectors ...Selector) ([]appv1.Deployment, error) {
+	return listPages("deployments", name, listOptions(selectors), func(opts metav1.ListOptions) ([]appv1.Deployment, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.AppsV1().Deployments(name).List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
+}
+
+// returns a slice of batchv1.Job structs in a given namespace
+
+func JobList(ctx context.Context, kube kubernetes.Interface, name string, selectors ...Selector) ([]batchv1.Job, error) {
+	return listPages("jobs", name, listOptions(selectors), func(opts metav1.ListOptions) ([]batchv1.Job, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.BatchV1().Jobs(name).List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
+}
+
+// returns a slice of storagev1.VolumeAttachment structs, attachments are not namespaced
+
+func VolumeAttachmentList(ctx context.Context, kube kubernetes.Interface, selectors ...Selector) ([]storagev1.VolumeAttachment, error) {
+	return listPages("volume attachments", "", listOptions(selectors), func(opts metav1.ListOptions) ([]storagev1.VolumeAttachment, string, error) {
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		list, err := kube.StorageV1().VolumeAttachments().List(callCtx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	})
 }
 
 // returns a slice of corev1.PersistentVolumeClaims that are all unattached (not associated with any statefulset)
 // from all namespaces
 // this function will probe and provide stats for each namespace at a time
+// fails rather than return a partial list, so no claim is mistaken for an unattached one
 
-func FindUnattachedPVCs(kube kubernetes.Interface, cfg structInternal.ControllerConfig) []corev1.PersistentVolumeClaim {
-	/* map each pvc name to its pvc object
-	names are used to calculate set differences, but the actual returned
-	slice has the objects, so need to keep both
-	*/
-	pvcObjects := make(map[string]corev1.PersistentVolumeClaim)
-
+func FindUnattachedPVCs(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig) ([]corev1.PersistentVolumeClaim, error) {
 	// list of pvc objects to be concated with the pvcs of each namespace
 	fullList := make([]corev1.PersistentVolumeClaim, 0)
 
 	log.Print("[INFO] Scanning namespaces...")
 
-	for _, namespace := range NsList(kube) {
+	namespaces, err := NsList(ctx, kube, cfg.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespace := range namespaces {
 		// skip if not in configured namespace
 		if namespace.Name != cfg.Namespace && cfg.Namespace != "" {
 			continue
 		}
 
-		log.Printf("[INFO] Found namespace: %s", namespace.Name)
-		log.Print("[INFO] Scanning persistent volume claims...")
+		scan, err := scanNamespace(ctx, kube, cfg, namespace.Name)
+		if err != nil {
+			return nil, err
+		}
 
-		allPVCs := structInternal.NewSet()
-		attachedPVCs := structInternal.NewSet()
+		for pvc := range scan.unattached.GetSet() {
+			// add unattached pvcs to full list before loop resets
+
+			fullList = append(fullList, scan.pvcs[pvc])
+		}
+	}
 
-		// on first pass, add all pvcs to a set
+	// return final list of all unattached pvc objects
 
-		for _, claim := range PvcList(kube, namespace.Name) {
-			// claim.Spec.VolumeName will be an empty string if not bound
-			log.Printf("[INFO] Found PVC: %s, PV: %s", claim.Name, claim.Spec.VolumeName)
+	return fullList, nil
+}
 
-			// ignore if storage class not in config
-			if IgnoreStorageClass(claim.Spec.StorageClassName, cfg.StorageClasses) {
-				continue
-			}
+// result of scanning a single namespace
 
-			// azure disk will have the same name as the volume
-			// e.g pvc-11cabba3-59ba-4671-8561-b871e2657fa6
+type namespaceScan struct {
+	/* map each pvc name to its pvc object
+	names are used to calculate set differences, but callers need
+	the actual objects, so need to keep both
+	*/
+	pvcs map[string]corev1.PersistentVolumeClaim
 
-			allPVCs.Add(claim.Name)
-			pvcObjects[claim.Name] = claim
-		}
+	// pvcs (with a configured storage class) that are / are not used by a sts
+	attached   *structInternal.Set
+	unattached *structInternal.Set
+}
 
-		log.Print("[INFO] Scanning stateful sets...")
+// splits the pvcs of a namespace into attached and unattached ones
+// a failed list returns an error, treating it as empty would mark every claim as unattached
 
-		// on second pass, add all pvcs attached to sts to a set
+func scanNamespace(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, namespace string) (namespaceScan, error) {
+	log.Printf("[INFO] Found namespace: %s", namespace)
+	log.Print("[INFO] Scanning persistent volume claims...")
 
-		for _, statefulset := range StsList(kube, namespace.Name) {
-			log.Printf("[INFO] Found stateful set: %s", statefulset.Name)
+	pvcObjects := make(map[string]corev1.PersistentVolumeClaim)
+	allPVCs := structInternal.NewSet()
+	attachedPVCs := structInternal.NewSet()
 
-			// Spec.Volumes will find all the attached PVCs, not PVs
+	// on first pass, add all pvcs to a set
 
-			for _, volumes := range statefulset.Spec.Template.Spec.Volumes {
-				if volumes.PersistentVolumeClaim == nil {
-					continue
-				}
-				claim := volumes.PersistentVolumeClaim.ClaimName
+	claims, err := PvcList(ctx, kube, namespace)
+	if err != nil {
+		return namespaceScan{}, err
+	}
 
-				log.Printf("[INFO] Found attached PVC: %s", claim)
+	for _, claim := range claims {
+		// claim.Spec.VolumeName will be an empty string if not bound
+		log.Printf("[INFO] Found PVC: %s, PV: %s", claim.Name, claim.Spec.VolumeName)
 
-				attachedPVCs.Add(claim)
-			}
+		// ignore if storage class not in config
+		if IgnoreStorageClass(claim.Spec.StorageClassName, cfg.StorageClasses) {
+			continue
 		}
 
-		/*
-			Use set difference to find all unattached pvcs
-			Because this method operates off allPVCs, it means that any non existent
-			pvcs from sts will be automatically filterd out
-		*/
-		unattachedPVCs := allPVCs.Difference(attachedPVCs)
+		// azure disk will have the same name as the volume
+		// e.g pvc-11cabba3-59ba-4671-8561-b871e2657fa6
 
-		for pvc := range unattachedPVCs.GetSet() {
-			// add unattached pvcs to full list before loop resets
+		allPVCs.Add(claim.Name)
+		pvcObjects[claim.Name] = claim
+	}
 
-			fullList = append(fullList, pvcObjects[pvc])
-		}
+	log.Print("[INFO] Scanning stateful sets...")
 
-		log.Printf("[INFO] Found %d total PVCs.", allPVCs.Length())
-		log.Printf("[INFO] Found %d unattached PVCs.", unattachedPVCs.Length())
+	// on second pass, add all pvcs attached to sts to a set
 
+	statefulsets, err := StsList(ctx, kube, namespace)
+	if err != nil {
+		return namespaceScan{}, err
 	}
 
-	// return final list of all unattached pvc objects
+	for _, statefulset := range statefulsets {
+		log.Printf("[INFO] Found stateful set: %s", statefulset.Name)
+
+		// Spec.Volumes will find all the attached PVCs, not PVs
+
+		for _, volumes := range statefulset.Spec.Template.Spec.Volumes {
+			if volumes.PersistentVolumeClaim == nil {
+				continue
+			}
+			claim := volumes.PersistentVolumeClaim.ClaimName
+
+			log.Printf("[INFO] Found attached PVC: %s", claim)
+
+			attachedPVCs.Add(claim)
+		}
+	}
+
+	/*
+		Use set difference to find all unattached pvcs
+		Because this method operates off allPVCs, it means that any non existent
+		pvcs from sts will be automatically filterd out
+	*/
+	unattachedPVCs := allPVCs.Difference(attachedPVCs)
+
+	log.Printf("[INFO] Found %d total PVCs.", allPVCs.Length())
+	log.Printf("[INFO] Found %d unattached PVCs.", unattachedPVCs.Length())
 
-	return fullList
+	return namespaceScan{
+		pvcs:       pvcObjects,
+		attached:   allPVCs.Intersection(attachedPVCs),
+		unattached: unattachedPVCs,
+	}, nil
 }
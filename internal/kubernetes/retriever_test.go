@@ -3,16 +3,64 @@ package kubernetes
 import (
 	// standard packages
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"testing"
 
 	// external packages
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	// internal packages
 	"volume-cleaner/internal/structure"
 	testInternal "volume-cleaner/internal/utils"
 )
 
+// returns the pvcs of a namespace, failing the test if they cannot be listed
+func listPvcs(t *testing.T, kube *testInternal.FakeClient, ns string) []corev1.PersistentVolumeClaim {
+	t.Helper()
+	list, err := PvcList(context.TODO(), kube, ns)
+	if err != nil {
+		t.Fatalf("Error listing pvcs: %v", err)
+	}
+	return list
+}
+
+// returns a single pvc, failing the test if it cannot be read
+func getPvc(t *testing.T, kube *testInternal.FakeClient, ns string, name string) corev1.PersistentVolumeClaim {
+	t.Helper()
+	pvc, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pvc %s: %v", name, err)
+	}
+	return *pvc
+}
+
+// returns all pvs, failing the test if they cannot be listed
+func listPvs(t *testing.T, kube *testInternal.FakeClient) []corev1.PersistentVolume {
+	t.Helper()
+	list, err := PvList(context.TODO(), kube)
+	if err != nil {
+		t.Fatalf("Error listing pvs: %v", err)
+	}
+	return list
+}
+
+// returns the number of unattached pvcs, failing the test if they cannot be found
+func countUnattached(t *testing.T, kube *testInternal.FakeClient, cfg structure.ControllerConfig) int {
+	t.Helper()
+	list, err := FindUnattachedPVCs(context.TODO(), kube, cfg)
+	if err != nil {
+		t.Fatalf("Error finding unattached pvcs: %v", err)
+	}
+	return len(list)
+}
+
 func TestNsList(t *testing.T) {
 
 	t.Run("successful ns listing", func(t *testing.T) {
@@ -31,7 +79,8 @@ func TestNsList(t *testing.T) {
 			}
 		}
 
-		list := NsList(kube)
+		list, err := NsList(context.TODO(), kube, structure.NamespaceScope{Selector: structure.DefaultNamespaceSelector})
+		assert.NoError(t, err)
 
 		// check right length
 		assert.Equal(t, len(list), len(names))
@@ -64,7 +113,8 @@ func TestStsList(t *testing.T) {
 			}
 		}
 
-		list := StsList(kube, "test")
+		list, err := StsList(context.TODO(), kube, "test")
+		assert.NoError(t, err)
 
 		// check right length
 		assert.Equal(t, len(list), len(names))
@@ -97,7 +147,8 @@ func TestPvcList(t *testing.T) {
 			}
 		}
 
-		list := PvcList(kube, "test")
+		list, err := PvcList(context.TODO(), kube, "test")
+		assert.NoError(t, err)
 
 		// check right length
 		assert.Equal(t, len(list), len(names))
@@ -130,14 +181,14 @@ func TestFindUnattachedPVCs(t *testing.T) {
 			}
 		}
 
-		assert.Equal(t, len(FindUnattachedPVCs(kube, structure.ControllerConfig{})), 2)
+		assert.Equal(t, countUnattached(t, kube, structure.ControllerConfig{}), 2)
 
 		// mock a stateful set attached to a pvc1
 		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc1"); stsErr != nil {
 			t.Fatalf("Error injecting sts add: %v", stsErr)
 		}
 
-		assert.Equal(t, len(FindUnattachedPVCs(kube, structure.ControllerConfig{})), 1)
+		assert.Equal(t, countUnattached(t, kube, structure.ControllerConfig{}), 1)
 
 		// mock a sts with no vols
 		if err := kube.CreateStatefulSet(context.TODO(), "sts-no-volumes", "test"); err != nil {
@@ -145,7 +196,7 @@ func TestFindUnattachedPVCs(t *testing.T) {
 		}
 
 		// no new attachements, expected unattached should still be 1
-		assert.Equal(t, len(FindUnattachedPVCs(kube, structure.ControllerConfig{})), 1)
+		assert.Equal(t, countUnattached(t, kube, structure.ControllerConfig{}), 1)
 
 		// create new namespace to see if controller will mark PVCs in namespaces outside its configured namespace
 
@@ -158,7 +209,7 @@ func TestFindUnattachedPVCs(t *testing.T) {
 			t.Fatalf("Error injecting pvc add: %v", pvcErr)
 		}
 
-		assert.Equal(t, len(FindUnattachedPVCs(kube, structure.ControllerConfig{Namespace: "test"})), 1)
+		assert.Equal(t, countUnattached(t, kube, structure.ControllerConfig{Namespace: "test"}), 1)
 	})
 }
 
@@ -182,6 +233,186 @@ func TestStorageClassFilter(t *testing.T) {
 			}
 		}
 
-		assert.Equal(t, len(FindUnattachedPVCs(kube, structure.ControllerConfig{StorageClasses: []string{"non-existent-storage-class"}})), 0)
+		assert.Equal(t, countUnattached(t, kube, structure.ControllerConfig{StorageClasses: []string{"non-existent-storage-class"}}), 0)
+	})
+}
+
+func TestPvcListPages(t *testing.T) {
+
+	t.Run("follows continue tokens", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		// serve 5 pvcs two at a time, the token is the index of the next page
+		requests := make([]metav1.ListOptions, 0)
+		kube.Interface.(*fake.Clientset).PrependReactor("list", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			opts := action.(k8stesting.ListActionImpl).ListOptions
+			requests = append(requests, opts)
+
+			start := 0
+			if opts.Continue != "" {
+				start, _ = strconv.Atoi(opts.Continue)
+			}
+
+			list := &corev1.PersistentVolumeClaimList{}
+			for i := start; i < 5 && i < start+2; i++ {
+				list.Items = append(list.Items, corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pvc%d", i), Namespace: "test"},
+				})
+			}
+			if start+2 < 5 {
+				list.Continue = strconv.Itoa(start + 2)
+			}
+			return true, list, nil
+		})
+
+		list, err := PvcList(context.TODO(), kube, "test")
+		assert.NoError(t, err)
+
+		assert.Len(t, list, 5)
+		assert.Equal(t, "pvc0", list[0].Name)
+		assert.Equal(t, "pvc4", list[4].Name)
+
+		// three pages, each bounded by the page size
+		assert.Len(t, requests, 3)
+		assert.Equal(t, "", requests[0].Continue)
+		assert.Equal(t, "4", requests[2].Continue)
+		for _, opts := range requests {
+			assert.Equal(t, int64(listPageSize), opts.Limit)
+		}
+	})
+}
+
+func TestPvcListSelector(t *testing.T) {
+
+	t.Run("only matching pvcs are listed", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		for _, ns := range []string{"test", "test2"} {
+			if err := kube.CreateNamespace(context.TODO(), ns, labels); err != nil {
+				t.Fatalf("Error injecting namespace add: %v", err)
+			}
+			for _, name := range []string{"pvc1", "pvc2"} {
+				if _, err := kube.CreatePersistentVolumeClaim(context.TODO(), name, ns); err != nil {
+					t.Fatalf("Error injecting pvc add: %v", err)
+				}
+			}
+		}
+
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "foo", "test", "pvc1")
+		SetPvcLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "foo", "test2", "pvc2")
+
+		// a single query across all namespaces
+		list, err := PvcList(context.TODO(), kube, "", Selector{Label: "volume-cleaner/unattached-time"})
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+
+		list, err = PvcList(context.TODO(), kube, "test", Selector{Label: "volume-cleaner/unattached-time"})
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, "pvc1", list[0].Name)
+	})
+
+	t.Run("selectors are merged", func(t *testing.T) {
+		opts := listOptions([]Selector{
+			{Label: "a=b"},
+			{Label: "c", Field: "status.phase=Running"},
+		})
+
+		assert.Equal(t, "a=b,c", opts.LabelSelector)
+		assert.Equal(t, "status.phase=Running", opts.FieldSelector)
+		assert.Equal(t, int64(listPageSize), opts.Limit)
+	})
+}
+
+func TestListErrors(t *testing.T) {
+
+	t.Run("failed lists are returned to the caller", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if err := kube.CreateNamespace(context.TODO(), "test", labels); err != nil {
+			t.Fatalf("Error injecting namespace add: %v", err)
+		}
+		if _, err := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); err != nil {
+			t.Fatalf("Error injecting pvc add: %v", err)
+		}
+
+		apiErr := errors.New("connection refused")
+		kube.Interface.(*fake.Clientset).PrependReactor("list", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apiErr
+		})
+
+		_, err := StsList(context.TODO(), kube, "test")
+
+		var listErr *ListError
+		assert.ErrorAs(t, err, &listErr)
+		assert.ErrorIs(t, err, apiErr)
+		assert.Equal(t, "stateful sets", listErr.Resource)
+		assert.Equal(t, "test", listErr.Namespace)
+		assert.Equal(t, "failed to list stateful sets in namespace test: connection refused", err.Error())
+
+		// the pvc must not be reported as unattached when its statefulsets are unknown
+		unattached, err := FindUnattachedPVCs(context.TODO(), kube, structure.ControllerConfig{})
+		assert.ErrorIs(t, err, apiErr)
+		assert.Empty(t, unattached)
+
+		// nor labelled by the reconciliation
+		report := Reconcile(context.TODO(), kube, structure.ControllerConfig{
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+		})
+		assert.Equal(t, 1, report.Errors)
+		assert.Equal(t, 0, report.Labelled)
+		_, ok := listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"]
+		assert.False(t, ok)
+	})
+}
+
+func TestNsListScope(t *testing.T) {
+
+	t.Run("include and exclude patterns are applied", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		for _, name := range []string{"team-a", "team-b", "team-admin", "shared"} {
+			if err := kube.CreateNamespace(context.TODO(), name, labels); err != nil {
+				t.Fatalf("Error injecting namespace add: %v", err)
+			}
+		}
+		if err := kube.CreateNamespace(context.TODO(), "team-platform", nil); err != nil {
+			t.Fatalf("Error injecting namespace add: %v", err)
+		}
+
+		names := func(scope structure.NamespaceScope) []string {
+			list, err := NsList(context.TODO(), kube, scope)
+			assert.NoError(t, err)
+			found := make([]string, 0)
+			for _, ns := range list {
+				found = append(found, ns.Name)
+			}
+			return found
+		}
+
+		assert.Equal(t, []string{"shared", "team-a", "team-admin", "team-b", "team-platform"}, names(structure.NamespaceScope{}))
+		assert.Equal(t, []string{"shared", "team-a", "team-admin", "team-b"}, names(structure.NamespaceScope{Selector: structure.DefaultNamespaceSelector}))
+		assert.Equal(t, []string{"team-a", "team-b"}, names(structure.NamespaceScope{
+			Selector: structure.DefaultNamespaceSelector,
+			Include:  []string{"team-*"},
+			Exclude:  []string{"team-admin"},
+		}))
+
+		// unattached pvcs are only found within the scope
+		for _, ns := range []string{"team-a", "team-admin", "team-platform"} {
+			if _, err := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", ns); err != nil {
+				t.Fatalf("Error injecting pvc add: %v", err)
+			}
+		}
+		assert.Equal(t, 3, countUnattached(t, kube, structure.ControllerConfig{}))
+		assert.Equal(t, 1, countUnattached(t, kube, structure.ControllerConfig{
+			Scope: structure.NamespaceScope{Include: []string{"team-*"}, Exclude: []string{"team-admin", "*-platform"}},
+		}))
 	})
 }
@@ -0,0 +1,213 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	// external packages
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+/*
+The decision to delete a volume is made on the state read at the start of a run. Right before the
+deletion, the cluster is checked again for anything still using the volume:
+
+  - a pod mounting the claim that has not terminated
+  - a stateful set, deployment or unfinished job whose pod template mounts the claim, or a stateful set
+    whose volume claim templates created it
+  - a volume attachment of the bound pv
+  - a claim already being deleted, held back by the kubernetes.io/pvc-protection finalizer
+
+Every bound claim carries that finalizer, so it does not tell whether the claim is in use, the pods
+above do. It only matters once the claim is being deleted: a pod still used it when the deletion was
+requested and deleting it again would change nothing.
+
+Any of these means the labels are wrong, the volume is skipped and a warning event is recorded on it.
+*/
+
+// returned when something still uses a volume that is about to be deleted
+var errVolumeInUse = errors.New("volume is still in use")
+
+// finalizer kubernetes adds to every claim, it holds back the deletion of a claim while a pod uses it
+const pvcProtectionFinalizer = "kubernetes.io/pvc-protection"
+
+// returns errVolumeInUse with every user of the claim, nil when nothing uses it
+// failing to list any of the workloads is returned as is, the claim is then not deleted either
+
+func checkClaimUnused(ctx context.Context, kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim) error {
+	var users []string
+
+	if pvc.DeletionTimestamp != nil && slices.Contains(pvc.Finalizers, pvcProtectionFinalizer) {
+		users = append(users, "deletion held by "+pvcProtectionFinalizer)
+	}
+
+	pods, err := PodList(ctx, kube, pvc.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed && mountsClaim(pod.Spec, pvc.Name) {
+			users = append(users, "pod/"+pod.Name)
+		}
+	}
+
+	statefulsets, err := StsList(ctx, kube, pvc.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, sts := range statefulsets {
+		if mountsClaim(sts.Spec.Template.Spec, pvc.Name) || templateClaim(sts.Name, sts.Spec.VolumeClaimTemplates, pvc.Name) {
+			users = append(users, "statefulset/"+sts.Name)
+		}
+	}
+
+	deployments, err := DeploymentList(ctx, kube, pvc.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments {
+		if mountsClaim(deployment.Spec.Template.Spec, pvc.Name) {
+			users = append(users, "deployment/"+deployment.Name)
+		}
+	}
+
+	jobs, err := JobList(ctx, kube, pvc.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if !jobFinished(job) && mountsClaim(job.Spec.Template.Spec, pvc.Name) {
+			users = append(users, "job/"+job.Name)
+		}
+	}
+
+	attached, err := volumeAttached(ctx, kube, pvc.Spec.VolumeName)
+	if err != nil {
+		return err
+	}
+	if attached != "" {
+		users = append(users, "volumeattachment/"+attached)
+	}
+
+	if len(users) > 0 {
+		return fmt.Errorf("%w: %s", errVolumeInUse, strings.Join(users, ", "))
+	}
+	return nil
+}
+
+// returns errVolumeInUse when the pv is still attached to a node
+
+func checkVolumeUnused(ctx context.Context, kube kubernetes.Interface, pv corev1.PersistentVolume) error {
+	attached, err := volumeAttached(ctx, kube, pv.Name)
+	if err != nil {
+		return err
+	}
+	if attached != "" {
+		return fmt.Errorf("%w: volumeattachment/%s", errVolumeInUse, attached)
+	}
+	return nil
+}
+
+// returns the name of an attachment of the pv to a node, empty if there is none
+
+func volumeAttached(ctx context.Context, kube kubernetes.Interface, pv string) (string, error) {
+	if pv == "" {
+		return "", nil
+	}
+
+	attachments, err := VolumeAttachmentList(ctx, kube)
+	if err != nil {
+		return "", err
+	}
+	for _, attachment := range attachments {
+		source := attachment.Spec.Source.PersistentVolumeName
+		if source != nil && *source == pv && attachment.Status.Attached {
+			return attachment.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// returns true if a pod spec mounts the claim
+
+func mountsClaim(spec corev1.PodSpec, claim string) bool {
+	for _, vol := range spec.Volumes {
+		if vol.PersistentVolumeClaim != nil && vol.PersistentVolumeClaim.ClaimName == claim {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if the claim was created from one of the volume claim templates of a sts,
+// those claims are named <template>-<sts>-<ordinal>
+
+func templateClaim(sts string, templates []corev1.PersistentVolumeClaim, claim string) bool {
+	for _, template := range templates {
+		ordinal, found := strings.CutPrefix(claim, template.Name+"-"+sts+"-")
+		if !found {
+			continue
+		}
+		if _, err := strconv.Atoi(ordinal); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true once a job completed or failed for good
+
+func jobFinished(job batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// records an event on a volume, shown by kubectl describe
+// events of pvs, which are not namespaced, go to the default namespace like those of kubernetes
+
+func recordEvent(ctx context.Context, kube kubernetes.Interface, clock structInternal.Clock, object corev1.ObjectReference, eventType string, reason string, message string) {
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	now := metav1.NewTime(clock.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: object.Name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject:      object,
+		Type:                eventType,
+		Reason:              reason,
+		Message:             message,
+		Source:              corev1.EventSource{Component: "volume-cleaner"},
+		ReportingController: "volume-cleaner",
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	if _, err := kube.CoreV1().Events(namespace).Create(callCtx, event, metav1.CreateOptions{}); err != nil {
+		log.Printf("[ERROR] Failed to record event on %s %s: %s", object.Kind, object.Name, err)
+	}
+}
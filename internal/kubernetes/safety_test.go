@@ -0,0 +1,196 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+// returns a pod template mounting the claim
+func claimTemplate(claim string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+		Name:         "data",
+		VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+	}}}}
+}
+
+func TestCheckClaimUnused(t *testing.T) {
+	setup := func(t *testing.T) (*testInternal.FakeClient, corev1.PersistentVolumeClaim) {
+		kube := testInternal.NewFakeClient()
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", nil); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "data-web-0", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+		return kube, getPvc(t, kube, "test", "data-web-0")
+	}
+
+	t.Run("nothing uses the claim", func(t *testing.T) {
+		kube, pvc := setup(t)
+
+		// finished pods and jobs no longer hold the claim
+		if _, podErr := kube.CreatePodWithPvc(context.TODO(), "done", "test", corev1.PodSucceeded, pvc.Name); podErr != nil {
+			t.Fatalf("Error injecting pod add: %v", podErr)
+		}
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "test"},
+			Spec:       batchv1.JobSpec{Template: claimTemplate(pvc.Name)},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			}},
+		}
+		if _, jobErr := kube.BatchV1().Jobs("test").Create(context.TODO(), job, metav1.CreateOptions{}); jobErr != nil {
+			t.Fatalf("Error injecting job add: %v", jobErr)
+		}
+
+		assert.NoError(t, checkClaimUnused(context.TODO(), kube, pvc))
+	})
+
+	cases := map[string]struct {
+		inject func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim)
+		user   string
+	}{
+		"pod": {
+			inject: func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim) {
+				if _, podErr := kube.CreatePodWithPvc(context.TODO(), "notebook", "test", corev1.PodPending, pvc.Name); podErr != nil {
+					t.Fatalf("Error injecting pod add: %v", podErr)
+				}
+			},
+			user: "pod/notebook",
+		},
+		"stateful set template": {
+			inject: func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim) {
+				sts := &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "test"},
+					Spec: appsv1.StatefulSetSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+						{ObjectMeta: metav1.ObjectMeta{Name: "data"}},
+					}},
+				}
+				if _, stsErr := kube.AppsV1().StatefulSets("test").Create(context.TODO(), sts, metav1.CreateOptions{}); stsErr != nil {
+					t.Fatalf("Error injecting sts add: %v", stsErr)
+				}
+			},
+			user: "statefulset/web",
+		},
+		"deployment": {
+			inject: func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim) {
+				deployment := &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "test"},
+					Spec:       appsv1.DeploymentSpec{Template: claimTemplate(pvc.Name)},
+				}
+				if _, deploymentErr := kube.AppsV1().Deployments("test").Create(context.TODO(), deployment, metav1.CreateOptions{}); deploymentErr != nil {
+					t.Fatalf("Error injecting deployment add: %v", deploymentErr)
+				}
+			},
+			user: "deployment/api",
+		},
+		"running job": {
+			inject: func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim) {
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "test"},
+					Spec:       batchv1.JobSpec{Template: claimTemplate(pvc.Name)},
+				}
+				if _, jobErr := kube.BatchV1().Jobs("test").Create(context.TODO(), job, metav1.CreateOptions{}); jobErr != nil {
+					t.Fatalf("Error injecting job add: %v", jobErr)
+				}
+			},
+			user: "job/train",
+		},
+		"volume attachment": {
+			inject: func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim) {
+				pvc.Spec.VolumeName = "pv1"
+				if attachErr := kube.SetVolumeAttachment(context.TODO(), "csi-123", "pv1", true); attachErr != nil {
+					t.Fatalf("Error injecting volume attachment: %v", attachErr)
+				}
+			},
+			user: "volumeattachment/csi-123",
+		},
+		"pvc protection": {
+			inject: func(t *testing.T, kube *testInternal.FakeClient, pvc *corev1.PersistentVolumeClaim) {
+				now := metav1.Now()
+				pvc.DeletionTimestamp = &now
+				pvc.Finalizers = []string{pvcProtectionFinalizer}
+			},
+			user: pvcProtectionFinalizer,
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube, pvc := setup(t)
+			test.inject(t, kube, &pvc)
+
+			err := checkClaimUnused(context.TODO(), kube, pvc)
+			assert.ErrorIs(t, err, errVolumeInUse)
+			assert.ErrorContains(t, err, test.user)
+		})
+	}
+}
+
+func TestFindStaleInUse(t *testing.T) {
+	t.Run("a labelled pvc still in use is skipped with a warning event", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC)),
+		}
+
+		SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+			DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		}, getPvc(t, kube, "test", "pvc1"))
+
+		// the controller missed the deployment that mounts it
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "test"},
+			Spec:       appsv1.DeploymentSpec{Template: claimTemplate("pvc1")},
+		}
+		if _, deploymentErr := kube.AppsV1().Deployments("test").Create(context.TODO(), deployment, metav1.CreateOptions{}); deploymentErr != nil {
+			t.Fatalf("Error injecting deployment add: %v", deploymentErr)
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.Deleted)
+		assert.Equal(t, 1, report.Errors)
+		assert.Len(t, listPvcs(t, kube, "test"), 1)
+
+		events, err := kube.CoreV1().Events("test").List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Error listing events: %v", err)
+		}
+		if assert.Len(t, events.Items, 1) {
+			event := events.Items[0]
+			assert.Equal(t, corev1.EventTypeWarning, event.Type)
+			assert.Equal(t, "DeletionSkipped", event.Reason)
+			assert.Equal(t, "pvc1", event.InvolvedObject.Name)
+			assert.Contains(t, event.Message, "deployment/api")
+		}
+	})
+}
@@ -0,0 +1,304 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	// external packages
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+/*
+The sweeper is enabled by setting SWEEP_VOLUMES. A pv with the Retain reclaim policy is left
+Released once its claim is deleted and keeps costing money. The sweeper finds the Released
+and Available pvs that were claimed from a managed namespace, labels them like the controller
+labels unattached pvcs, and runs them through the same grace period and notifications.
+
+With DELETE_DISKS, the reclaim policy of a stale pv is switched to Delete before the pv is
+deleted so that the csi driver removes the underlying disk as well.
+*/
+
+// runs every orphaned pv through the grace period, the results are added to the report
+
+func sweepVolumes(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, client *http.Client, businessDay bool, report *structInternal.RunReport) {
+	log.Print("[INFO] Scanning for orphaned PVs...")
+
+	// pvs are not namespaced, so they are matched to the namespace of their former claim
+	managed, err := managedNamespaces(ctx, kube, cfg.Scope, cfg.Namespace)
+	if err != nil {
+		log.Printf("[ERROR] Skipping orphaned PVs: %s", err)
+		report.Errors++
+		return
+	}
+
+	pvs, err := PvList(ctx, kube)
+	if err != nil {
+		log.Printf("[ERROR] Skipping orphaned PVs: %s", err)
+		report.Errors++
+		return
+	}
+
+	// a pv that was started is carried through to the end even if the run is stopped
+	work := context.WithoutCancel(ctx)
+
+	for _, pv := range pvs {
+		claim := pv.Spec.ClaimRef
+		if claim == nil || !managed[claim.Namespace] {
+			continue
+		}
+
+		// the run was stopped, remaining pvs are counted and left for the next run
+		if ctx.Err() != nil {
+			report.Skipped++
+			continue
+		}
+
+		log.Printf("[INFO] Found PV %s claimed from NS %s (%s)", pv.Name, claim.Namespace, pv.Status.Phase)
+
+		keys := cfg.StateKeys()
+
+		if !volumeOrphaned(&pv) {
+			// bound again, the lifecycle starts over if it is ever released
+			// a pv whose claim was just deleted still shows as bound for a moment, its state is kept
+			if hasState(keys, pv.Labels, pv.Annotations) && claimExists(work, kube, claim) {
+				log.Printf("[INFO] PV %s is bound again, removing labels.", pv.Name)
+				removed := false
+				retryPv(work, kube, pv, func(latest corev1.PersistentVolume) error {
+					removed = false
+					if volumeOrphaned(&latest) || !hasState(keys, latest.Labels, latest.Annotations) {
+						return nil
+					}
+					err := RemovePvState(work, kube, keys, latest)
+					removed, pv = err == nil, latest
+					return err
+				})
+				if removed {
+					RecordAudit(work, kube, cfg.Audit, pvAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditUnmark, pv,
+						fmt.Sprintf("bound again to PVC %s in NS %s", claim.Name, claim.Namespace)))
+				}
+			}
+			continue
+		}
+
+		ignore, ok := pv.Labels[cfg.IgnoreLabel]
+		if ok && ignore == "true" {
+			log.Printf("[INFO][IGNORE] Label %s found. Skipping.", cfg.IgnoreLabel)
+			continue
+		}
+
+		state, ok, legacy, err := keys.Read(pv.Labels, pv.Annotations)
+		if err != nil {
+			log.Printf("[ERROR] PV %s: %s", pv.Name, err)
+			report.Errors++
+			continue
+		}
+
+		// start the grace period the first time the pv is seen orphaned
+		created := !ok
+		if created {
+			state = structInternal.VolumeState{DetachedAt: cfg.Clock.Now().UTC().Truncate(time.Second)}
+			legacy = true
+
+			if cfg.DryRun {
+				log.Printf("[DRY RUN] Add labels to PV %s", pv.Name)
+			} else {
+				log.Printf("[INFO] Adding missing labels to PV %s", pv.Name)
+			}
+		}
+
+		deleted := processVolume(work, cfg, client, businessDay, report, staleVolume{
+			kind:         "PV",
+			name:         pv.Name,
+			namespace:    claim.Namespace,
+			state:        state,
+			legacy:       legacy,
+			since:        state.DetachedAt,
+			size:         pv.Spec.Capacity[corev1.ResourceStorage],
+			storageClass: pv.Spec.StorageClassName,
+			remove: func(state structInternal.VolumeState) error {
+				latest, err := recheckPv(work, kube, cfg, pv, state, created)
+				if err != nil {
+					return err
+				}
+				if err := deleteVolume(work, kube, cfg, *latest); err != nil {
+					return err
+				}
+
+				reason := deletionReason(cfg, state.DetachedAt, state)
+				if cfg.DeleteDisks {
+					reason += ", along with its disk"
+				}
+				RecordAudit(work, kube, cfg.Audit, pvAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditDelete, *latest, reason))
+				return nil
+			},
+			warn: func(reason string, message string) {
+				recordEvent(work, kube, cfg.Clock, corev1.ObjectReference{
+					Kind:       "PersistentVolume",
+					APIVersion: "v1",
+					Name:       pv.Name,
+					UID:        pv.UID,
+				}, corev1.EventTypeWarning, reason, message)
+			},
+			save: func(reason string, change func(state *structInternal.VolumeState)) {
+				saved, marked := false, false
+				retryPv(work, kube, pv, func(latest corev1.PersistentVolume) error {
+					saved, marked = false, false
+					next, ok := changedState(keys, state, created, latest.Labels, latest.Annotations, change)
+					if !ok || !volumeOrphaned(&latest) {
+						log.Printf("[INFO] State of PV %s changed since it was read, not recording this run.", latest.Name)
+						return nil
+					}
+					err := SetPvState(work, kube, keys, next, latest)
+					saved = err == nil
+					// only the first state written starts the grace period, later ones record notifications
+					marked = saved && !hasState(keys, latest.Labels, latest.Annotations)
+					return err
+				})
+				if marked {
+					RecordAudit(work, kube, cfg.Audit, pvAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditMark, pv,
+						fmt.Sprintf("released by PVC %s in NS %s", claim.Name, claim.Namespace)))
+				} else if saved {
+					RecordAudit(work, kube, cfg.Audit, pvAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditUpdate, pv, reason))
+				}
+			},
+			details: func(detachedAt time.Time) (string, structInternal.Personalisation) {
+				return utilsInternal.PvEmailDetails(work, kube, pv, detachedAt, cfg)
+			},
+		})
+		if deleted {
+			report.VolumesDeleted++
+		}
+	}
+}
+
+// returns true if the pv is no longer bound to a claim
+
+func volumeOrphaned(pv *corev1.PersistentVolume) bool {
+	return pv.Status.Phase == corev1.VolumeReleased || pv.Status.Phase == corev1.VolumeAvailable
+}
+
+// returns true if the claim a pv refers to still exists
+
+func claimExists(ctx context.Context, kube kubernetes.Interface, claim *corev1.ObjectReference) bool {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	_, err := kube.CoreV1().PersistentVolumeClaims(claim.Namespace).Get(callCtx, claim.Name, metav1.GetOptions{})
+	return err == nil
+}
+
+// reads a pv again right before it is deleted and checks it is still the same orphaned pv in the same
+// grace period and not attached to a node (see recheckPvc). created is true when the pv had no state
+// when it was read
+
+func recheckPv(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, pv corev1.PersistentVolume, state structInternal.VolumeState, created bool) (*corev1.PersistentVolume, error) {
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	latest, err := kube.CoreV1().PersistentVolumes().Get(callCtx, pv.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if latest.UID != pv.UID {
+		return nil, fmt.Errorf("%w: the PV was created again", errVolumeChanged)
+	}
+	if !volumeOrphaned(latest) {
+		return nil, fmt.Errorf("%w: the PV is %s", errVolumeChanged, latest.Status.Phase)
+	}
+	if latest.Labels[cfg.IgnoreLabel] == "true" {
+		return nil, fmt.Errorf("%w: label %s was added", errVolumeChanged, cfg.IgnoreLabel)
+	}
+
+	current, ok, _, err := cfg.StateKeys().Read(latest.Labels, latest.Annotations)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: %s", errVolumeChanged, err)
+	case !ok && created:
+		// the grace period started in this run, nothing was stored yet
+	case !ok:
+		return nil, fmt.Errorf("%w: the state was removed", errVolumeChanged)
+	case !current.DetachedAt.Equal(state.DetachedAt):
+		return nil, fmt.Errorf("%w: the grace period started again", errVolumeChanged)
+	case current.ExtensionDays() != state.ExtensionDays():
+		return nil, fmt.Errorf("%w: the grace period was extended", errVolumeChanged)
+	}
+
+	if err := checkVolumeUnused(ctx, kube, *latest); err != nil {
+		return nil, err
+	}
+
+	return latest, nil
+}
+
+// deletes a pv, and the underlying disk when configured to
+// both the patch and the deletion are guarded by the version of the pv that was checked
+
+func deleteVolume(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, pv corev1.PersistentVolume) error {
+	if cfg.DeleteDisks && pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+		log.Printf("[INFO] Setting reclaim policy of PV %s to Delete so its disk is removed.", pv.Name)
+
+		patch := []byte(`{"spec":{"persistentVolumeReclaimPolicy":"Delete"}}`)
+		if pv.ResourceVersion != "" {
+			patch = []byte(fmt.Sprintf(`{"metadata":{"resourceVersion":%q},"spec":{"persistentVolumeReclaimPolicy":"Delete"}}`, pv.ResourceVersion))
+		}
+
+		callCtx, cancel := utilsInternal.CallContext(ctx)
+		defer cancel()
+		patched, err := kube.CoreV1().PersistentVolumes().Patch(callCtx, pv.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if apierrors.IsConflict(err) {
+			return fmt.Errorf("%w: %s", errVolumeChanged, err)
+		}
+		if err != nil {
+			return err
+		}
+		pv = *patched
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	err := kube.CoreV1().PersistentVolumes().Delete(callCtx, pv.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &pv.UID, ResourceVersion: &pv.ResourceVersion},
+	})
+	if apierrors.IsConflict(err) {
+		return fmt.Errorf("%w: %s", errVolumeChanged, err)
+	}
+	return err
+}
+
+// labels the pv bound to a pvc about to be deleted with the state of the pvc
+// the grace period of the claim is already over, so a retained pv is deleted by the sweeper as soon as
+// it is released, without warning the owner a second time about the same data
+
+func handOverVolume(ctx context.Context, kube kubernetes.Interface, cfg structInternal.SchedulerConfig, pvc corev1.PersistentVolumeClaim, state structInternal.VolumeState) {
+	if pvc.Spec.VolumeName == "" {
+		return
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	pv, err := kube.CoreV1().PersistentVolumes().Get(callCtx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[ERROR] Failed to find PV %s bound to PVC %s: %s", pvc.Spec.VolumeName, pvc.Name, err)
+		return
+	}
+
+	err = retryPv(ctx, kube, *pv, func(latest corev1.PersistentVolume) error {
+		pv = &latest
+		return SetPvState(ctx, kube, cfg.StateKeys(), state, latest)
+	})
+	if err == nil {
+		RecordAudit(ctx, kube, cfg.Audit, pvAuditEntry(cfg.Clock.Now(), structInternal.ActorScheduler, structInternal.AuditMark, *pv,
+			fmt.Sprintf("bound to PVC %s in NS %s, which is being deleted", pvc.Name, pvc.Namespace)))
+	}
+}
@@ -0,0 +1,188 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+// injects a retained pv of 10Gi claimed from the namespace
+func injectPv(t *testing.T, kube *testInternal.FakeClient, name string, ns string, phase corev1.PersistentVolumePhase) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			StorageClassName:              "standard",
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			ClaimRef:                      &corev1.ObjectReference{Namespace: ns, Name: "claim-" + name},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: phase},
+	}
+	if _, pvErr := kube.CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{}); pvErr != nil {
+		t.Fatalf("Error injecting pv add: %v", pvErr)
+	}
+}
+
+// returns a label of a pv, or an empty string
+func pvLabel(kube *testInternal.FakeClient, name string, label string) string {
+	pv, err := kube.CoreV1().PersistentVolumes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return pv.Labels[label]
+}
+
+// returns the decoded state annotation of a pv, failing the test if it is missing or invalid
+func pvState(t *testing.T, kube *testInternal.FakeClient, name string) structInternal.VolumeState {
+	t.Helper()
+	pv, err := kube.CoreV1().PersistentVolumes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pv %s: %v", name, err)
+	}
+	state, err := structInternal.DecodeVolumeState(pv.Annotations["volume-cleaner/state"])
+	if err != nil {
+		t.Fatalf("Error decoding state of %s: %v", name, err)
+	}
+	return state
+}
+
+func TestSweepVolumes(t *testing.T) {
+	t.Run("orphaned pvs go through the grace period", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "unmanaged", nil); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		injectPv(t, kube, "released", "test", corev1.VolumeReleased)
+		injectPv(t, kube, "bound", "test", corev1.VolumeBound)
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "claim-bound", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+		injectPv(t, kube, "other", "unmanaged", corev1.VolumeReleased)
+
+		// was released once, but is bound again
+		SetPvLabel(context.TODO(), kube, "volume-cleaner/unattached-time", "2025-06-01_00-00-00Z", "bound")
+		SetPvLabel(context.TODO(), kube, "volume-cleaner/notification-count", "0", "bound")
+
+		cfg := structInternal.SchedulerConfig{
+			Scope:           structInternal.NamespaceScope{Selector: structInternal.DefaultNamespaceSelector},
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			SweepVolumes:    true,
+			DeleteDisks:     true,
+			Clock:           clock,
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 0, report.VolumesDeleted)
+		assert.Equal(t, "2025-07-01_12-00-00Z", pvLabel(kube, "released", cfg.TimeLabel))
+		assert.Empty(t, pvState(t, kube, "released").NotificationsSent)
+		assert.Equal(t, "", pvLabel(kube, "bound", cfg.TimeLabel))
+		assert.Equal(t, "", pvLabel(kube, "bound", cfg.NotifLabel))
+		assert.Equal(t, "", pvLabel(kube, "other", cfg.TimeLabel))
+
+		gi := int64(1024 * 1024 * 1024)
+		assert.Equal(t, structInternal.CapacityTotals{Pending: 10 * gi}, report.Total())
+
+		// the grace period has passed
+		clock.Advance(11 * 24 * time.Hour)
+
+		report = FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 1, report.VolumesDeleted)
+		assert.Equal(t, structInternal.CapacityTotals{Reclaimed: 10 * gi}, report.Total())
+
+		_, err := kube.CoreV1().PersistentVolumes().Get(context.TODO(), "released", metav1.GetOptions{})
+		assert.Error(t, err)
+
+		// the reclaim policy was switched so the disk is deleted too
+		patched := false
+		for _, action := range kube.Interface.(*fake.Clientset).Actions() {
+			patch, ok := action.(k8stesting.PatchAction)
+			if ok && patch.GetName() == "released" && string(patch.GetPatch()) == `{"spec":{"persistentVolumeReclaimPolicy":"Delete"}}` {
+				patched = true
+			}
+		}
+		assert.True(t, patched)
+	})
+
+	t.Run("a deleted pvc hands its pv over to the sweeper", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+		clock := testInternal.NewFakeClock(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC))
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		injectPv(t, kube, "pv1", "test", corev1.VolumeBound)
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "claim-pv1",
+				Namespace: "test",
+				Labels: map[string]string{
+					"volume-cleaner/unattached-time":    "2025-06-01_00-00-00Z",
+					"volume-cleaner/notification-count": "2",
+				},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv1"},
+		}
+		if _, pvcErr := kube.CoreV1().PersistentVolumeClaims("test").Create(context.TODO(), pvc, metav1.CreateOptions{}); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		cfg := structInternal.SchedulerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			GracePeriod:     10,
+			NotifTimes:      []int{1, 5},
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			SweepVolumes:    true,
+			Clock:           clock,
+		}
+
+		report := FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 1, report.Deleted)
+		assert.Equal(t, "2025-06-01_00-00-00Z", pvLabel(kube, "pv1", cfg.TimeLabel))
+		assert.Len(t, pvState(t, kube, "pv1").NotificationsSent, 2)
+
+		// once the pv is released, it is deleted without further notices
+		pv, _ := kube.CoreV1().PersistentVolumes().Get(context.TODO(), "pv1", metav1.GetOptions{})
+		pv.Status.Phase = corev1.VolumeReleased
+		if _, pvErr := kube.CoreV1().PersistentVolumes().UpdateStatus(context.TODO(), pv, metav1.UpdateOptions{}); pvErr != nil {
+			t.Fatalf("Error injecting pv update: %v", pvErr)
+		}
+
+		report = FindStale(context.TODO(), kube, cfg)
+
+		assert.Equal(t, 1, report.VolumesDeleted)
+		assert.Equal(t, 0, report.Emailed)
+	})
+}
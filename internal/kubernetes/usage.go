@@ -0,0 +1,246 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"log"
+
+	// external packages
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
+)
+
+/*
+Usage tracking is enabled by setting USAGE_ANNOTATION. The controller then records the last
+time each pvc was mounted in that annotation, using the same format as the time label, and the
+scheduler counts the grace period from it instead of from when the pvc lost its stateful set.
+
+A pvc is mounted while a pod scheduled on a node uses it, or while a volume attachment of its
+persistent volume is attached. The time is recorded when the mount starts and again when it ends.
+*/
+
+// Watches for pods being scheduled with, or releasing, a pvc
+
+func WatchPods(ctx context.Context, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig], health *structInternal.Health) {
+	open := func(resourceVersion string) (watch.Interface, error) {
+		return kube.CoreV1().Pods(live.Get().Namespace).Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+	}
+
+	watcher, err := open("")
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to create watcher for pods: %s", err)
+	}
+
+	log.Print("[INFO] Watching for pod events...")
+
+	// an event already received is handled to the end during shutdown, each call keeps its own timeout
+	work := context.WithoutCancel(ctx)
+
+	// pods whose mount was already recorded, status updates of running pods are frequent
+	// so the annotation is only written when a mount starts or ends
+	mounted := make(map[types.UID]bool)
+
+	watchEvents(ctx, "pods", health, watcher, open, func(event watch.Event) {
+		pod, ok := event.Object.(*corev1.Pod)
+
+		// Skip this event if it can't be parsed into a pod
+		if !ok {
+			return
+		}
+
+		switch event.Type {
+
+		case watch.Added, watch.Modified:
+			if podMounting(pod) && !mounted[pod.UID] {
+				mounted[pod.UID] = true
+				recordPodUsage(work, kube, live.Get(), pod)
+			} else if !podMounting(pod) && mounted[pod.UID] {
+				// pod finished
+				delete(mounted, pod.UID)
+				recordPodUsage(work, kube, live.Get(), pod)
+			}
+		case watch.Deleted:
+			delete(mounted, pod.UID)
+			if pod.Spec.NodeName != "" {
+				recordPodUsage(work, kube, live.Get(), pod)
+			}
+		}
+	})
+}
+
+// Watches for persistent volumes being attached to, or detached from, a node
+
+func WatchVolumeAttachments(ctx context.Context, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig], health *structInternal.Health) {
+	open := func(resourceVersion string) (watch.Interface, error) {
+		return kube.StorageV1().VolumeAttachments().Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+	}
+
+	watcher, err := open("")
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to create watcher for volume attachments: %s", err)
+	}
+
+	log.Print("[INFO] Watching for volume attachment events...")
+
+	// an event already received is handled to the end during shutdown, each call keeps its own timeout
+	work := context.WithoutCancel(ctx)
+
+	// attachments whose start was already recorded
+	attached := make(map[string]bool)
+
+	watchEvents(ctx, "volumeattachments", health, watcher, open, func(event watch.Event) {
+		attachment, ok := event.Object.(*storagev1.VolumeAttachment)
+
+		// Skip this event if it can't be parsed into a volume attachment
+		if !ok {
+			return
+		}
+
+		switch event.Type {
+
+		case watch.Added, watch.Modified:
+			if attachment.Status.Attached && !attached[attachment.Name] {
+				attached[attachment.Name] = true
+				recordAttachmentUsage(work, kube, live.Get(), attachment)
+			} else if !attachment.Status.Attached && attached[attachment.Name] {
+				// volume detached
+				delete(attached, attachment.Name)
+				recordAttachmentUsage(work, kube, live.Get(), attachment)
+			}
+		case watch.Deleted:
+			delete(attached, attachment.Name)
+			recordAttachmentUsage(work, kube, live.Get(), attachment)
+		}
+	})
+}
+
+// records usage for every pvc mounted by a running pod in the namespace
+// returns the number of pvcs recorded
+// used by the reconciliation so long running pods keep their pvcs fresh even if an event is missed
+
+func RecordMountedUsage(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, ns string) int {
+	recorded := 0
+
+	pods, err := PodList(ctx, kube, ns)
+	if err != nil {
+		log.Printf("[ERROR] %s", err)
+		return recorded
+	}
+
+	for _, pod := range pods {
+		if !podMounting(&pod) {
+			continue
+		}
+		recorded += recordPodUsage(ctx, kube, cfg, &pod)
+	}
+
+	return recorded
+}
+
+// returns true while the pod holds its volumes mounted: scheduled on a node and not finished
+
+func podMounting(pod *corev1.Pod) bool {
+	if pod.Spec.NodeName == "" {
+		return false
+	}
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// records usage for every pvc the pod mounts, returns the number of pvcs recorded
+
+func recordPodUsage(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, pod *corev1.Pod) int {
+	recorded := 0
+
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil {
+			continue
+		}
+		if recordUsage(ctx, kube, cfg, pod.Namespace, vol.PersistentVolumeClaim.ClaimName) {
+			recorded++
+		}
+	}
+
+	return recorded
+}
+
+// records usage for the pvc bound to the attached persistent volume
+
+func recordAttachmentUsage(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, attachment *storagev1.VolumeAttachment) {
+	// inline volumes have no persistent volume and so no claim
+	if attachment.Spec.Source.PersistentVolumeName == nil {
+		return
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	pv, err := kube.CoreV1().PersistentVolumes().Get(callCtx, *attachment.Spec.Source.PersistentVolumeName, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[ERROR] Failed to find PV object %s: %s", *attachment.Spec.Source.PersistentVolumeName, err)
+		return
+	}
+
+	claim := pv.Spec.ClaimRef
+	if claim == nil {
+		return
+	}
+
+	recordUsage(ctx, kube, cfg, claim.Namespace, claim.Name)
+}
+
+// writes the current time in the usage annotation of a pvc
+// a pvc that is used again gets a new grace period, so earlier warnings are reset as well
+// returns true if the annotation was written
+
+func recordUsage(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, ns string, claim string) bool {
+	// skip if not in configured namespace
+	if ns != cfg.Namespace && cfg.Namespace != "" {
+		return false
+	}
+
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	pvcObj, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(callCtx, claim, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[ERROR] Failed to find PVC object %s: %s", claim, err)
+		return false
+	}
+
+	// ignore if storage class not in config
+	if IgnoreStorageClass(pvcObj.Spec.StorageClassName, cfg.StorageClasses) {
+		return false
+	}
+
+	// the mount of the job archiving a pvc before its deletion is not usage
+	if _, archiving := pvcObj.Annotations[archivingAnnotation]; archiving {
+		log.Printf("[INFO][USAGE] PVC %s from NS %s is being archived, not recording usage.", claim, ns)
+		return false
+	}
+
+	// only track pvcs in the namespaces the controller manages
+	if !namespaceManaged(ctx, kube, cfg.Scope, ns) {
+		return false
+	}
+
+	log.Printf("[INFO][USAGE] PVC %s from NS %s is mounted, recording usage.", claim, ns)
+	mountedAt := cfg.Clock.Now().UTC().Format(cfg.TimeFormat)
+
+	// the usage and the reset warnings are written together, guarded like every other state change
+	// a state that cannot be read is left to the reconciliation
+	keys := cfg.StateKeys()
+	err = retryPvc(ctx, kube, *pvcObj, func(pvc corev1.PersistentVolumeClaim) error {
+		state, ok, _, err := keys.Read(pvc.Labels, pvc.Annotations)
+		reset := err == nil && ok && len(state.NotificationsSent) > 0
+		state.NotificationsSent = nil
+		return SetPvcUsage(ctx, kube, keys, cfg.UsageAnnotation, mountedAt, state, reset, pvc)
+	})
+
+	return err == nil
+}
@@ -0,0 +1,208 @@
+package kubernetes
+
+import (
+	// standard packages
+	"context"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+	testInternal "volume-cleaner/internal/utils"
+)
+
+// returns a fake client with a managed namespace holding the given pvcs, and a controller config tracking usage
+func usageSetup(t *testing.T, pvcs ...string) (*testInternal.FakeClient, *testInternal.FakeClock, structInternal.ControllerConfig) {
+	kube := testInternal.NewFakeClient()
+
+	labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+	if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+		t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+	}
+
+	for _, name := range pvcs {
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+	}
+
+	clock := testInternal.NewFakeClock(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+
+	cfg := structInternal.ControllerConfig{
+		Namespace:       "test",
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		UsageAnnotation: "volume-cleaner/last-mounted",
+		Clock:           clock,
+	}
+
+	return kube, clock, cfg
+}
+
+// returns the usage annotation of a pvc, or an empty string
+func pvcUsage(kube *testInternal.FakeClient, name string) string {
+	pvcs, _ := PvcList(context.TODO(), kube, "test")
+	for _, pvc := range pvcs {
+		if pvc.Name == name {
+			return pvc.Annotations["volume-cleaner/last-mounted"]
+		}
+	}
+	return ""
+}
+
+func TestWatchPods(t *testing.T) {
+
+	t.Run("mount start and end are recorded", func(t *testing.T) {
+		kube, clock, cfg := usageSetup(t, "pvc1", "pvc2")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go WatchPods(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
+
+		time.Sleep(500 * time.Millisecond)
+
+		if _, podErr := kube.CreatePodWithPvc(context.TODO(), "pod1", "test", corev1.PodRunning, "pvc1"); podErr != nil {
+			t.Fatalf("Error injecting pod add: %v", podErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "2025-07-01_12-00-00Z", pvcUsage(kube, "pvc1"))
+		assert.Equal(t, "", pvcUsage(kube, "pvc2"))
+
+		// the pvc was warned about before the pod came back
+		warned := structInternal.Notification{SentAt: clock.Now(), Channel: structInternal.EmailChannel}
+		SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+			DetachedAt:        clock.Now().Add(-30 * 24 * time.Hour),
+			NotificationsSent: []structInternal.Notification{warned, warned},
+		}, getPvc(t, kube, "test", "pvc1"))
+
+		clock.Advance(48 * time.Hour)
+
+		if podErr := kube.DeletePod(context.TODO(), "pod1", "test"); podErr != nil {
+			t.Fatalf("Error injecting pod delete: %v", podErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "2025-07-03_12-00-00Z", pvcUsage(kube, "pvc1"))
+		assert.Empty(t, pvcState(t, kube, "test", "pvc1").NotificationsSent)
+	})
+}
+
+func TestWatchVolumeAttachments(t *testing.T) {
+
+	t.Run("attach and detach are recorded", func(t *testing.T) {
+		kube, clock, cfg := usageSetup(t, "pvc1")
+
+		if pvErr := kube.CreatePersistentVolume(context.TODO(), "pv1", "test", "pvc1"); pvErr != nil {
+			t.Fatalf("Error injecting pv add: %v", pvErr)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go WatchVolumeAttachments(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
+
+		time.Sleep(500 * time.Millisecond)
+
+		// not attached yet
+		if vaErr := kube.SetVolumeAttachment(context.TODO(), "va1", "pv1", false); vaErr != nil {
+			t.Fatalf("Error injecting volume attachment add: %v", vaErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "", pvcUsage(kube, "pvc1"))
+
+		if vaErr := kube.SetVolumeAttachment(context.TODO(), "va1", "pv1", true); vaErr != nil {
+			t.Fatalf("Error injecting volume attachment update: %v", vaErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "2025-07-01_12-00-00Z", pvcUsage(kube, "pvc1"))
+
+		clock.Advance(time.Hour)
+
+		if vaErr := kube.SetVolumeAttachment(context.TODO(), "va1", "pv1", false); vaErr != nil {
+			t.Fatalf("Error injecting volume attachment update: %v", vaErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "2025-07-01_13-00-00Z", pvcUsage(kube, "pvc1"))
+	})
+}
+
+func TestRecordMountedUsage(t *testing.T) {
+
+	t.Run("only pvcs of running pods are recorded", func(t *testing.T) {
+		kube, _, cfg := usageSetup(t, "pvc1", "pvc2")
+
+		if _, podErr := kube.CreatePodWithPvc(context.TODO(), "pod1", "test", corev1.PodRunning, "pvc1"); podErr != nil {
+			t.Fatalf("Error injecting pod add: %v", podErr)
+		}
+		if _, podErr := kube.CreatePodWithPvc(context.TODO(), "pod2", "test", corev1.PodSucceeded, "pvc2"); podErr != nil {
+			t.Fatalf("Error injecting pod add: %v", podErr)
+		}
+
+		assert.Equal(t, 1, RecordMountedUsage(context.TODO(), kube, cfg, "test"))
+		assert.Equal(t, "2025-07-01_12-00-00Z", pvcUsage(kube, "pvc1"))
+		assert.Equal(t, "", pvcUsage(kube, "pvc2"))
+	})
+	t.Run("the mount of an archive job is not usage", func(t *testing.T) {
+		kube, _, cfg := usageSetup(t, "pvc1")
+
+		SetPvcAnnotation(context.TODO(), kube, archivingAnnotation, "2025-07-01T11:00:00Z", "test", "pvc1")
+		if _, podErr := kube.CreatePodWithPvc(context.TODO(), "archive", "test", corev1.PodRunning, "pvc1"); podErr != nil {
+			t.Fatalf("Error injecting pod add: %v", podErr)
+		}
+
+		assert.Equal(t, 0, RecordMountedUsage(context.TODO(), kube, cfg, "test"))
+		assert.Equal(t, "", pvcUsage(kube, "pvc1"))
+	})
+	t.Run("warnings are reset in the same write as the usage", func(t *testing.T) {
+		kube, clock, cfg := usageSetup(t, "pvc1")
+		keys := cfg.StateKeys()
+
+		pvc, _ := kube.CoreV1().PersistentVolumeClaims("test").Get(context.TODO(), "pvc1", metav1.GetOptions{})
+		state := structInternal.VolumeState{
+			DetachedAt:        clock.Now().Add(-48 * time.Hour),
+			NotificationsSent: []structInternal.Notification{{SentAt: clock.Now(), Channel: structInternal.EmailChannel}},
+		}
+		assert.NoError(t, SetPvcState(context.TODO(), kube, keys, state, *pvc))
+
+		if _, podErr := kube.CreatePodWithPvc(context.TODO(), "pod1", "test", corev1.PodRunning, "pvc1"); podErr != nil {
+			t.Fatalf("Error injecting pod add: %v", podErr)
+		}
+		kube.Interface.(*fake.Clientset).ClearActions()
+
+		assert.Equal(t, 1, RecordMountedUsage(context.TODO(), kube, cfg, "test"))
+		assert.Equal(t, "2025-07-01_12-00-00Z", pvcUsage(kube, "pvc1"))
+
+		pvc, _ = kube.CoreV1().PersistentVolumeClaims("test").Get(context.TODO(), "pvc1", metav1.GetOptions{})
+		recorded, ok, _, err := keys.Read(pvc.Labels, pvc.Annotations)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, recorded.NotificationsSent)
+
+		patches := 0
+		for _, action := range kube.Interface.(*fake.Clientset).Actions() {
+			if _, ok := action.(k8stesting.PatchAction); ok {
+				patches++
+			}
+		}
+		assert.Equal(t, 1, patches)
+	})
+}
@@ -9,26 +9,137 @@ import (
 
 	// external packages
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 
 	// internal packages
 	structInternal "volume-cleaner/internal/structure"
+	utilsInternal "volume-cleaner/internal/utils"
 )
 
-// Watches for when statefulsets are created or deleted
+// Watches for when statefulsets are created, modified or deleted
+// the config is read on every event so reloaded values apply without a restart
 
-func WatchSts(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig) {
-	watcher, err := kube.AppsV1().StatefulSets(cfg.Namespace).Watch(ctx, metav1.ListOptions{})
+func WatchSts(ctx context.Context, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig], health *structInternal.Health) {
+	open := func(resourceVersion string) (watch.Interface, error) {
+		return kube.AppsV1().StatefulSets(live.Get().Namespace).Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+	}
+
+	watcher, err := open("")
 	if err != nil {
 		log.Fatalf("[ERROR] Failed to create watcher for statefulsets: %s", err)
 	}
 
 	log.Print("[INFO] Watching for statefulset events...")
 
-	// create a channel to capture sts events in the cluster
-	events := watcher.ResultChan()
+	// an event already received is handled to the end during shutdown, each call keeps its own timeout
+	work := context.WithoutCancel(ctx)
+
+	// claims referenced by each sts the last time it was seen
+	// modified events only carry the new object, so this is used to find removed volumes
+	claims := make(map[string][]string)
+	statefulsets, err := StsList(ctx, kube, live.Get().Namespace)
+	if err != nil {
+		// modified events of unseen statefulsets are skipped until they are seen again
+		log.Printf("[ERROR] %s", err)
+	}
+	for _, sts := range statefulsets {
+		claims[sts.Namespace+"/"+sts.Name] = stsClaims(&sts)
+	}
+
+	// sts was added, modified or deleted
+	watchEvents(ctx, "statefulsets", health, watcher, open, func(event watch.Event) {
+		sts, ok := event.Object.(*appsv1.StatefulSet)
+
+		// Skip this event if it can't be parsed into a sts
+		if !ok {
+			return
+		}
+
+		cfg := live.Get()
+		key := sts.Namespace + "/" + sts.Name
+
+		// statefulsets outside the namespace scope never touch labels
+		if !namespaceManaged(work, kube, cfg.Scope, sts.Namespace) {
+			delete(claims, key)
+			return
+		}
+
+		switch event.Type {
+
+		case watch.Added:
+			// sts added
+			claims[key] = stsClaims(sts)
+			handleAdded(work, kube, cfg, sts)
+		case watch.Modified:
+			// sts volumes may have been edited
+			previous, seen := claims[key]
+			claims[key] = stsClaims(sts)
+			if seen {
+				handleModified(work, kube, cfg, sts, previous)
+			}
+		case watch.Deleted:
+			// sts deleted
+			delete(claims, key)
+			handleDeleted(work, kube, cfg, sts)
+		}
+	})
+}
+
+// Watches for when pvcs are created
+// a standalone pvc (or one recreated with the same name) is labelled right away instead of
+// waiting for the next controller restart
+
+func WatchPvc(ctx context.Context, kube kubernetes.Interface, live *structInternal.Live[structInternal.ControllerConfig], health *structInternal.Health) {
+	open := func(resourceVersion string) (watch.Interface, error) {
+		return kube.CoreV1().PersistentVolumeClaims(live.Get().Namespace).Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+	}
+
+	watcher, err := open("")
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to create watcher for persistent volume claims: %s", err)
+	}
+
+	log.Print("[INFO] Watching for persistent volume claim events...")
+
+	// an event already received is handled to the end during shutdown, each call keeps its own timeout
+	work := context.WithoutCancel(ctx)
+
+	watchEvents(ctx, "persistentvolumeclaims", health, watcher, open, func(event watch.Event) {
+		pvc, ok := event.Object.(*corev1.PersistentVolumeClaim)
+
+		// Skip this event if it can't be parsed into a pvc
+		if !ok {
+			return
+		}
+
+		// modified events are mostly the labels patched by the controller and scheduler themselves
+		if event.Type == watch.Added {
+			handlePvcAdded(work, kube, live.Get(), pvc)
+		}
+	})
+}
+
+// how often an idle watch loop reports that it is still running
+const watchHeartbeat = 10 * time.Second
+
+// calls handle for every event of a watch until ctx is cancelled
+// the api server ends watches routinely, they are opened again from the last resource version seen.
+// progress is recorded in health so the readiness probe can tell a stalled or dead watch apart
+
+func watchEvents(ctx context.Context, name string, health *structInternal.Health, watcher watch.Interface, open func(resourceVersion string) (watch.Interface, error), handle func(event watch.Event)) {
+	health.WatchStarted(name)
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+
+	defer func() { watcher.Stop() }()
+
+	resourceVersion := ""
 
 	for {
 		select {
@@ -38,142 +149,358 @@ func WatchSts(ctx context.Context, kube kubernetes.Interface, cfg structInternal
 		case <-ctx.Done():
 			return
 
-		// sts was added or deleted
-		case event := <-events:
-			sts, ok := event.Object.(*appsv1.StatefulSet)
+		case <-heartbeat.C:
+			health.WatchAlive(name)
 
-			// Skip this event if it can't be parsed into a sts
+		case event, ok := <-watcher.ResultChan():
 			if !ok {
+				// the channel is also closed when the context is cancelled
+				if ctx.Err() != nil {
+					return
+				}
+
+				log.Printf("[INFO] Watch for %s ended, opening it again...", name)
+				next, ok := reopenWatch(ctx, name, health, open, resourceVersion)
+				if !ok {
+					return
+				}
+				watcher = next
+				health.WatchRestarted(name)
 				continue
 			}
 
-			switch event.Type {
+			if event.Type == watch.Error {
+				// usually the resource version is too old, the next watch starts from the current state
+				log.Printf("[ERROR] Watch for %s failed: %s", name, apierrors.FromObject(event.Object))
+				resourceVersion = ""
+				watcher.Stop()
+				continue
+			}
 
-			case watch.Added:
-				// sts added
-				handleAdded(kube, cfg, sts)
-			case watch.Deleted:
-				// sts deleted
-				handleDeleted(kube, cfg, sts)
+			if object, err := meta.Accessor(event.Object); err == nil {
+				resourceVersion = object.GetResourceVersion()
 			}
+
+			health.WatchEvent(name)
+			handle(event)
 		}
 	}
-
 }
 
-// scan performed on controller startup to find unattached pvcs and assign labels to them
+// delays between attempts to open an ended watch again
+const (
+	watchRetryMin = time.Second
+	watchRetryMax = time.Minute
+)
 
-func InitialScan(kube kubernetes.Interface, cfg structInternal.ControllerConfig) {
-	log.Print("[INFO] Starting initial scan...")
+// opens an ended watch again, retrying with backoff until it succeeds or ctx is cancelled
+// when the resource version is too old, the watch starts again from the current state, which
+// replays every existing object as an added event
 
-	for _, pvc := range FindUnattachedPVCs(kube, cfg) {
+func reopenWatch(ctx context.Context, name string, health *structInternal.Health, open func(resourceVersion string) (watch.Interface, error), resourceVersion string) (watch.Interface, bool) {
+	delay := watchRetryMin
 
-		// add time stamp label if not found
-		_, ok := pvc.Labels[cfg.TimeLabel]
-		if !ok {
-			log.Printf("[INFO] Adding missing label %s to %s", cfg.TimeLabel, pvc.Name)
-			SetPvcLabel(kube, cfg.TimeLabel, time.Now().Format(cfg.TimeFormat), pvc.Namespace, pvc.Name)
+	for {
+		next, err := open(resourceVersion)
+		if err == nil {
+			return next, true
 		}
 
-		// add notification count label if not found
-		_, ok = pvc.Labels[cfg.NotifLabel]
-		if !ok {
-			log.Printf("[INFO] Adding missing label %s to %s", cfg.NotifLabel, pvc.Name)
-			SetPvcLabel(kube, cfg.NotifLabel, "0", pvc.Namespace, pvc.Name)
+		if apierrors.IsGone(err) || apierrors.IsResourceExpired(err) {
+			log.Printf("[INFO] Resource version of the watch for %s expired, watching from the current state.", name)
+			resourceVersion = ""
+		} else {
+			log.Printf("[ERROR] Failed to open watch for %s again, retrying in %s: %s", name, delay, err)
+		}
+		// not ready until the watch is open again, but the loop is still running
+		health.WatchClosed(name)
+		health.WatchAlive(name)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false
+		case <-timer.C:
 		}
+
+		delay = min(2*delay, watchRetryMax)
+	}
+}
+
+// scan performed on controller startup to find unattached pvcs and assign labels to them
+
+func InitialScan(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig) error {
+	log.Print("[INFO] Starting initial scan...")
+
+	unattached, err := FindUnattachedPVCs(ctx, kube, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, pvc := range unattached {
+		// adds the missing state and migrates the labels of earlier versions
+		ensureState(ctx, kube, cfg, pvc, "")
 	}
 
 	log.Print("[INFO] Initial scan complete.")
+	return nil
 }
 
-// scans all pvcs and removes all volume-cleaner related labels
-func ResetLabels(kube kubernetes.Interface, cfg structInternal.ControllerConfig) {
+// scans all pvcs and removes all volume-cleaner related labels and the state annotation
+func ResetLabels(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig) error {
 	log.Print("Resetting labels...")
 
-	for _, namespace := range NsList(kube) {
-		for _, pvc := range PvcList(kube, namespace.Name) {
-			_, ok := pvc.Labels[cfg.TimeLabel]
-			if ok {
-				RemovePvcLabel(kube, cfg.TimeLabel, namespace.Name, pvc.Name)
-			}
-			_, ok = pvc.Labels[cfg.NotifLabel]
-			if ok {
-				RemovePvcLabel(kube, cfg.NotifLabel, namespace.Name, pvc.Name)
-			}
+	namespaces, err := NsList(ctx, kube, cfg.Scope)
+	if err != nil {
+		return err
+	}
 
+	for _, namespace := range namespaces {
+		pvcs, err := PvcList(ctx, kube, namespace.Name)
+		if err != nil {
+			return err
+		}
+
+		for _, pvc := range pvcs {
+			clearPvcState(ctx, kube, cfg, pvc, "labels reset with RESET_RUN")
 		}
 	}
+
+	return nil
 }
 
 // triggered on sts creation event
 // will remove labels from all associated pvcs
 
-func handleAdded(kube kubernetes.Interface, cfg structInternal.ControllerConfig, sts *appsv1.StatefulSet) {
+func handleAdded(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, sts *appsv1.StatefulSet) {
 	log.Printf("[INFO] STS added: %s", sts.Name)
 
-	for _, vol := range sts.Spec.Template.Spec.Volumes {
-		if vol.PersistentVolumeClaim == nil {
-			continue
-		}
+	for _, claim := range stsClaims(sts) {
+		attachClaim(ctx, kube, cfg, sts.Namespace, claim, sts.Name)
+	}
+}
 
-		// get pvc object from name
-		pvcObj, err := kube.CoreV1().PersistentVolumeClaims(sts.Namespace).Get(context.TODO(), vol.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
-		if err != nil {
-			log.Printf("[ERROR] Failed to find PVC object %s: %s", vol.PersistentVolumeClaim.ClaimName, err)
-			continue
-		}
-		log.Printf("[INFO] Found PVC object %s", pvcObj.Name)
+// triggered on sts modification event
+// claims that were added to the sts lose their labels, claims that were removed
+// are labelled unless another sts still uses them
 
-		// ignore if storage class not in config
-		if IgnoreStorageClass(pvcObj.Spec.StorageClassName, cfg.StorageClasses) {
-			continue
-		}
+func handleModified(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, sts *appsv1.StatefulSet, previous []string) {
+	current := stsClaims(sts)
 
-		// remove labels if found
-		_, ok := pvcObj.Labels[cfg.TimeLabel]
-		if ok {
-			log.Printf("[INFO] Removing label %s", cfg.TimeLabel)
-			RemovePvcLabel(kube, cfg.TimeLabel, sts.Namespace, vol.PersistentVolumeClaim.ClaimName)
+	for _, claim := range current {
+		if !slices.Contains(previous, claim) {
+			log.Printf("[INFO] STS %s now references PVC %s", sts.Name, claim)
+			attachClaim(ctx, kube, cfg, sts.Namespace, claim, sts.Name)
 		}
+	}
 
-		_, ok = pvcObj.Labels[cfg.NotifLabel]
-		if ok {
-			log.Printf("[INFO] Removing label %s", cfg.NotifLabel)
-			RemovePvcLabel(kube, cfg.NotifLabel, sts.Namespace, vol.PersistentVolumeClaim.ClaimName)
+	for _, claim := range previous {
+		if !slices.Contains(current, claim) {
+			log.Printf("[INFO] STS %s no longer references PVC %s", sts.Name, claim)
+			detachClaim(ctx, kube, cfg, sts.Namespace, claim, sts.Name)
 		}
-
 	}
 }
 
 // triggered on sts deletion event
 // will add labels to associated pvcs
 
-func handleDeleted(kube kubernetes.Interface, cfg structInternal.ControllerConfig, sts *appsv1.StatefulSet) {
+func handleDeleted(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, sts *appsv1.StatefulSet) {
 	log.Printf("[INFO] STS deleted: %s", sts.Name)
 
+	for _, claim := range stsClaims(sts) {
+		detachClaim(ctx, kube, cfg, sts.Namespace, claim, sts.Name)
+	}
+}
+
+// triggered on pvc creation event
+// will add labels if no sts uses the pvc
+
+func handlePvcAdded(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, pvc *corev1.PersistentVolumeClaim) {
+	// ignore if storage class not in config
+	if IgnoreStorageClass(pvc.Spec.StorageClassName, cfg.StorageClasses) {
+		return
+	}
+
+	// only label pvcs in the same namespaces the initial scan covers
+	if !namespaceManaged(ctx, kube, cfg.Scope, pvc.Namespace) {
+		return
+	}
+
+	if claimAttached(ctx, kube, pvc.Namespace, pvc.Name, "") {
+		return
+	}
+
+	// an existing state is kept so the unattached time is not reset
+	if ensureState(ctx, kube, cfg, *pvc, "") {
+		log.Printf("[INFO] PVC added: %s. Not attached to any stateful set, labels added.", pvc.Name)
+	}
+}
+
+// makes sure an unattached pvc carries its state, starting the grace period now if it has none
+// labels of earlier versions are migrated to the state annotation. returns true if a new state was added
+
+func ensureState(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, pvc corev1.PersistentVolumeClaim, workload string) bool {
+	if protectedPvc(ctx, kube, cfg, pvc) {
+		return false
+	}
+
+	keys := cfg.StateKeys()
+
+	added := false
+	err := retryPvc(ctx, kube, pvc, func(latest corev1.PersistentVolumeClaim) error {
+		added = false
+		pvc = latest
+
+		state, ok, legacy, err := keys.Read(pvc.Labels, pvc.Annotations)
+		switch {
+		case err != nil:
+			// starting over can only postpone the deletion
+			log.Printf("[ERROR] PVC %s from NS %s: %s. Starting the grace period again.", pvc.Name, pvc.Namespace, err)
+		case !ok:
+			log.Printf("[INFO] Adding missing state to %s", pvc.Name)
+		case legacy:
+			log.Printf("[INFO] Migrating labels of %s to the state annotation", pvc.Name)
+			return SetPvcState(ctx, kube, keys, state, pvc)
+		default:
+			return nil
+		}
+
+		added = true
+		return SetPvcState(ctx, kube, keys, newState(cfg, workload), pvc)
+	})
+	if !added || err != nil {
+		return false
+	}
+
+	reason := "not attached to any workload"
+	if workload != "" {
+		reason = "detached from " + workload
+	}
+	RecordAudit(ctx, kube, cfg.Audit, PvcAuditEntry(cfg.Clock.Now(), structInternal.ActorController, structInternal.AuditMark, pvc, reason))
+	return true
+}
+
+// returns true if a protection rule covers the pvc, which is then never labelled
+// a state added before the rule existed is removed
+
+func protectedPvc(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, pvc corev1.PersistentVolumeClaim) bool {
+	rule, ok := cfg.Protection.Protects(pvc.ObjectMeta)
+	if !ok {
+		return false
+	}
+
+	log.Printf("[INFO][PROTECTED] PVC %s from NS %s is protected by rule %s. Not labelled.", pvc.Name, pvc.Namespace, rule)
+	if clearPvcState(ctx, kube, cfg, pvc, "protected by rule "+rule) {
+		log.Printf("[INFO][PROTECTED] Removed label %s and state", cfg.TimeLabel)
+	}
+	return true
+}
+
+// returns the state of a pvc detached from workload right now
+func newState(cfg structInternal.ControllerConfig, workload string) structInternal.VolumeState {
+	return structInternal.VolumeState{
+		DetachedAt:   cfg.Clock.Now().UTC().Truncate(time.Second),
+		LastWorkload: workload,
+	}
+}
+
+// removes the volume cleaner labels from a pvc that is now used by a sts
+
+func attachClaim(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, ns string, claim string, stsName string) {
+	// get pvc object from name
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	pvcObj, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(callCtx, claim, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[ERROR] Failed to find PVC object %s: %s", claim, err)
+		return
+	}
+	log.Printf("[INFO] Found PVC object %s", pvcObj.Name)
+
+	// ignore if storage class not in config
+	if IgnoreStorageClass(pvcObj.Spec.StorageClassName, cfg.StorageClasses) {
+		return
+	}
+
+	// remove labels and state if found
+	if clearPvcState(ctx, kube, cfg, *pvcObj, "attached to statefulset/"+stsName) {
+		log.Printf("[INFO] Removed label %s and state", cfg.TimeLabel)
+	}
+}
+
+// labels a pvc that a sts stopped using, unless another sts in the namespace still uses it
+
+func detachClaim(ctx context.Context, kube kubernetes.Interface, cfg structInternal.ControllerConfig, ns string, claim string, stsName string) {
+	// get pvc object to check storage class
+	callCtx, cancel := utilsInternal.CallContext(ctx)
+	defer cancel()
+	pvcObj, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(callCtx, claim, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[ERROR] Failed to find PVC object %s: %s", claim, err)
+		return
+	}
+
+	log.Printf("[INFO] Found PVC object %s", pvcObj.Name)
+
+	// ignore if storage class not in config
+	if IgnoreStorageClass(pvcObj.Spec.StorageClassName, cfg.StorageClasses) {
+		return
+	}
+
+	if claimAttached(ctx, kube, ns, claim, stsName) {
+		log.Printf("[INFO] PVC %s is still used by another stateful set.", claim)
+		return
+	}
+
+	if protectedPvc(ctx, kube, cfg, *pvcObj) {
+		return
+	}
+
+	log.Printf("[INFO] Adding labels.")
+	pvc := *pvcObj
+	err = retryPvc(ctx, kube, pvc, func(latest corev1.PersistentVolumeClaim) error {
+		pvc = latest
+		return SetPvcState(ctx, kube, cfg.StateKeys(), newState(cfg, "statefulset/"+stsName), pvc)
+	})
+	if err == nil {
+		RecordAudit(ctx, kube, cfg.Audit, PvcAuditEntry(cfg.Clock.Now(), structInternal.ActorController, structInternal.AuditMark, pvc, "detached from statefulset/"+stsName))
+	}
+}
+
+// returns the names of all pvcs mounted by a sts
+
+func stsClaims(sts *appsv1.StatefulSet) []string {
+	claims := make([]string, 0)
 	for _, vol := range sts.Spec.Template.Spec.Volumes {
 		if vol.PersistentVolumeClaim == nil {
 			continue
 		}
+		claims = append(claims, vol.PersistentVolumeClaim.ClaimName)
+	}
+	return claims
+}
 
-		// get pvc object to check storage class
-		pvcObj, err := kube.CoreV1().PersistentVolumeClaims(sts.Namespace).Get(context.TODO(), vol.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
-		if err != nil {
-			log.Printf("[ERROR] Failed to find PVC object %s: %s", vol.PersistentVolumeClaim.ClaimName, err)
-			continue
-		}
+// returns true if any sts in the namespace, other than the one named exclude, mounts the claim
+// if the statefulsets cannot be listed the claim is assumed attached, so it is never labelled by mistake
 
-		log.Printf("[INFO] Found PVC object %s", pvcObj.Name)
+func claimAttached(ctx context.Context, kube kubernetes.Interface, ns string, claim string, exclude string) bool {
+	statefulsets, err := StsList(ctx, kube, ns)
+	if err != nil {
+		log.Printf("[ERROR] %s", err)
+		return true
+	}
 
-		// ignore if storage class not in config
-		if IgnoreStorageClass(pvcObj.Spec.StorageClassName, cfg.StorageClasses) {
+	for _, sts := range statefulsets {
+		if sts.Name == exclude {
 			continue
 		}
-
-		log.Printf("[INFO] Adding labels.")
-		SetPvcLabel(kube, cfg.TimeLabel, time.Now().Format(cfg.TimeFormat), sts.Namespace, vol.PersistentVolumeClaim.ClaimName)
-		SetPvcLabel(kube, cfg.NotifLabel, "0", sts.Namespace, vol.PersistentVolumeClaim.ClaimName)
+		if slices.Contains(stsClaims(&sts), claim) {
+			return true
+		}
 	}
+	return false
 }
 
 func IgnoreStorageClass(name *string, storageClasses []string) bool {
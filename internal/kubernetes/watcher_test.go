@@ -3,11 +3,14 @@ package kubernetes
 import (
 	// standard packages
 	"context"
+	"regexp"
 	"testing"
 	"time"
 
 	// external packages
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	// internal packages
 	structInternal "volume-cleaner/internal/structure"
@@ -37,29 +40,31 @@ func TestWatcherLabelling(t *testing.T) {
 		ctx := context.Background()
 
 		cfg := structInternal.ControllerConfig{
-			Namespace:  "test",
-			TimeLabel:  "volume-cleaner/unattached-time",
-			NotifLabel: "volume-cleaner/notification-count",
-			TimeFormat: "2006-01-02_15-04-05Z",
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           structInternal.RealClock{},
 		}
 
-		go WatchSts(ctx, kube, cfg)
+		go WatchSts(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
 
 		time.Sleep(2 * time.Second)
 
 		// no pvc should have labels right now
-		pvcs := PvcList(kube, "test")
+		pvcs := listPvcs(t, kube, "test")
 
 		_, ok := pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		// mock a stateful set attached to a pvc1
@@ -72,18 +77,18 @@ func TestWatcherLabelling(t *testing.T) {
 
 		// should be no change
 
-		pvcs = PvcList(kube, "test")
+		pvcs = listPvcs(t, kube, "test")
 
 		_, ok = pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		// delete sts
@@ -95,18 +100,18 @@ func TestWatcherLabelling(t *testing.T) {
 
 		// should have new labels
 
-		pvcs = PvcList(kube, "test")
+		pvcs = listPvcs(t, kube, "test")
 
 		_, ok = pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, true)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, true)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		// add back sts1
@@ -116,18 +121,18 @@ func TestWatcherLabelling(t *testing.T) {
 
 		time.Sleep(2 * time.Second)
 
-		pvcs = PvcList(kube, "test")
+		pvcs = listPvcs(t, kube, "test")
 
 		_, ok = pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		ctx.Done()
@@ -158,14 +163,16 @@ func TestWatcherStorageClassFilter(t *testing.T) {
 		ctx := context.Background()
 
 		cfg := structInternal.ControllerConfig{
-			Namespace:      "test",
-			TimeLabel:      "volume-cleaner/unattached-time",
-			NotifLabel:     "volume-cleaner/notification-count",
-			TimeFormat:     "2006-01-02_15-04-05Z",
-			StorageClasses: []string{"non-existent-storage-class"},
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			StorageClasses:  []string{"non-existent-storage-class"},
+			Clock:           structInternal.RealClock{},
 		}
 
-		go WatchSts(ctx, kube, cfg)
+		go WatchSts(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
 
 		// mock a stateful set attached to a pvc1
 		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc1"); stsErr != nil {
@@ -181,18 +188,18 @@ func TestWatcherStorageClassFilter(t *testing.T) {
 
 		// should not have new labels
 
-		pvcs := PvcList(kube, "test")
+		pvcs := listPvcs(t, kube, "test")
 
 		_, ok := pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		ctx.Done()
@@ -200,6 +207,255 @@ func TestWatcherStorageClassFilter(t *testing.T) {
 	})
 }
 
+func TestWatcherConfigReload(t *testing.T) {
+
+	t.Run("reloaded storage classes apply without restarting the watcher", func(t *testing.T) {
+		// create fake client
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := structInternal.ControllerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			StorageClasses:  []string{"non-existent-storage-class"},
+			Clock:           structInternal.RealClock{},
+		}
+		live := structInternal.NewLive(cfg)
+
+		go WatchSts(ctx, kube, live, structInternal.NewHealth(structInternal.RealClock{}))
+
+		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc1"); stsErr != nil {
+			t.Fatalf("Error injecting sts add: %v", stsErr)
+		}
+		if eventErr := kube.DeleteStatefulSet(context.TODO(), "sts1", "test"); eventErr != nil {
+			t.Fatalf("Error injecting event add: %v", eventErr)
+		}
+
+		time.Sleep(2 * time.Second)
+
+		// storage class is filtered out
+		_, ok := listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"]
+		assert.Equal(t, ok, false)
+
+		// accept every storage class
+		cfg.StorageClasses = []string{}
+		live.Set(cfg)
+
+		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc1"); stsErr != nil {
+			t.Fatalf("Error injecting sts add: %v", stsErr)
+		}
+		if eventErr := kube.DeleteStatefulSet(context.TODO(), "sts1", "test"); eventErr != nil {
+			t.Fatalf("Error injecting event add: %v", eventErr)
+		}
+
+		time.Sleep(2 * time.Second)
+
+		_, ok = listPvcs(t, kube, "test")[0].Labels["volume-cleaner/unattached-time"]
+		assert.Equal(t, ok, true)
+	})
+}
+
+func TestWatcherPvcEvents(t *testing.T) {
+
+	t.Run("standalone and recreated pvcs are labelled promptly", func(t *testing.T) {
+		// create fake client
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "unmanaged", nil); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := structInternal.ControllerConfig{
+			Scope:           structInternal.NamespaceScope{Selector: structInternal.DefaultNamespaceSelector},
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)),
+		}
+
+		go WatchPvc(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
+
+		// give the watcher time to start
+		time.Sleep(500 * time.Millisecond)
+
+		// pvc2 is used by a stateful set, so it must stay unlabelled
+		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc2"); stsErr != nil {
+			t.Fatalf("Error injecting sts add: %v", stsErr)
+		}
+
+		for _, name := range []string{"pvc1", "pvc2"} {
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "test"); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc3", "unmanaged"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		assert.Eventually(t, func() bool {
+			return pvcLabel(kube, "test", "pvc1", "volume-cleaner/unattached-time") == "2025-06-01_12-00-00Z" &&
+				pvcAnnotation(kube, "test", "pvc1", "volume-cleaner/state") != ""
+		}, 5*time.Second, 50*time.Millisecond)
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc2", "volume-cleaner/unattached-time"))
+		assert.Equal(t, "", pvcLabel(kube, "unmanaged", "pvc3", "volume-cleaner/unattached-time"))
+
+		// recreating pvc1 with the same name labels the new object
+		if pvcErr := kube.DeletePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc delete: %v", pvcErr)
+		}
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+
+		assert.Eventually(t, func() bool {
+			return pvcLabel(kube, "test", "pvc1", "volume-cleaner/unattached-time") != ""
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+}
+
+func TestWatcherStsModified(t *testing.T) {
+
+	t.Run("editing sts volumes updates labels immediately", func(t *testing.T) {
+		// create fake client
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+			t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+		}
+
+		for _, name := range []string{"pvc1", "pvc2"} {
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "test"); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+		}
+
+		// sts2 shares pvc1 with sts1
+		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", "test", "pvc1"); stsErr != nil {
+			t.Fatalf("Error injecting sts add: %v", stsErr)
+		}
+		if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts2", "test", "pvc1"); stsErr != nil {
+			t.Fatalf("Error injecting sts add: %v", stsErr)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := structInternal.ControllerConfig{
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           structInternal.RealClock{},
+		}
+
+		go WatchSts(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
+
+		// give the watcher time to start
+		time.Sleep(500 * time.Millisecond)
+
+		// sts1 switches from pvc1 to pvc2: pvc1 is still used by sts2
+		if stsErr := kube.UpdateStatefulSetPvcs(context.TODO(), "sts1", "test", "pvc2"); stsErr != nil {
+			t.Fatalf("Error injecting sts update: %v", stsErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc1", "volume-cleaner/unattached-time"))
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc2", "volume-cleaner/unattached-time"))
+
+		// sts2 drops pvc1: nothing uses it anymore
+		if stsErr := kube.UpdateStatefulSetPvcs(context.TODO(), "sts2", "test"); stsErr != nil {
+			t.Fatalf("Error injecting sts update: %v", stsErr)
+		}
+
+		assert.Eventually(t, func() bool {
+			return pvcLabel(kube, "test", "pvc1", "volume-cleaner/unattached-time") != "" &&
+				pvcAnnotation(kube, "test", "pvc1", "volume-cleaner/state") != ""
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Equal(t, "statefulset/sts2", pvcState(t, kube, "test", "pvc1").LastWorkload)
+
+		// sts2 picks pvc1 back up
+		if stsErr := kube.UpdateStatefulSetPvcs(context.TODO(), "sts2", "test", "pvc1"); stsErr != nil {
+			t.Fatalf("Error injecting sts update: %v", stsErr)
+		}
+
+		assert.Eventually(t, func() bool {
+			return pvcLabel(kube, "test", "pvc1", "volume-cleaner/unattached-time") == "" &&
+				pvcAnnotation(kube, "test", "pvc1", "volume-cleaner/state") == ""
+		}, 5*time.Second, 50*time.Millisecond)
+
+		// sts2 goes away while sts1 takes over both pvcs, neither ends up labelled
+		if eventErr := kube.DeleteStatefulSet(context.TODO(), "sts2", "test"); eventErr != nil {
+			t.Fatalf("Error injecting event add: %v", eventErr)
+		}
+		if eventErr := kube.UpdateStatefulSetPvcs(context.TODO(), "sts1", "test", "pvc1", "pvc2"); eventErr != nil {
+			t.Fatalf("Error injecting sts update: %v", eventErr)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc1", "volume-cleaner/unattached-time"))
+		assert.Equal(t, "", pvcLabel(kube, "test", "pvc2", "volume-cleaner/unattached-time"))
+	})
+}
+
+// returns the value of a label on a pvc, empty if missing
+func pvcLabel(kube *testInternal.FakeClient, ns string, name string, label string) string {
+	pvc, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return pvc.Labels[label]
+}
+
+func pvcAnnotation(kube *testInternal.FakeClient, ns string, name string, annotation string) string {
+	pvc, err := kube.CoreV1().PersistentVolumeClaims(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return pvc.Annotations[annotation]
+}
+
+// returns the decoded state annotation of a pvc, failing the test if it is missing or invalid
+func pvcState(t *testing.T, kube *testInternal.FakeClient, ns string, name string) structInternal.VolumeState {
+	t.Helper()
+	value := pvcAnnotation(kube, ns, name, "volume-cleaner/state")
+	if value == "" {
+		t.Fatalf("PVC %s has no state", name)
+	}
+	state, err := structInternal.DecodeVolumeState(value)
+	if err != nil {
+		t.Fatalf("Error decoding state of %s: %v", name, err)
+	}
+	return state
+}
+
 func TestInitialScan(t *testing.T) {
 
 	t.Run("successful labelling of unatatched pvcs on controller startup", func(t *testing.T) {
@@ -221,50 +477,126 @@ func TestInitialScan(t *testing.T) {
 		}
 
 		// no pvc should have labels right now
-		pvcs := PvcList(kube, "test")
+		pvcs := listPvcs(t, kube, "test")
 
 		_, ok := pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		cfg := structInternal.ControllerConfig{
-			Namespace:  "test",
-			TimeLabel:  "volume-cleaner/unattached-time",
-			NotifLabel: "volume-cleaner/notification-count",
-			TimeFormat: "2006-01-02_15-04-05Z",
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           structInternal.RealClock{},
 		}
 
-		InitialScan(kube, cfg)
+		InitialScan(context.TODO(), kube, cfg)
 
 		time.Sleep(2 * time.Second)
 
 		// should have new labels
 
-		pvcs = PvcList(kube, "test")
+		pvcs = listPvcs(t, kube, "test")
 
 		_, ok = pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, true)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, true)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, true)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, true)
 
 	})
 }
 
+func TestWatcherProtection(t *testing.T) {
+	kube := testInternal.NewFakeClient()
+
+	labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+	if namespaceErr := kube.CreateNamespace(context.TODO(), "test", labels); namespaceErr != nil {
+		t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+	}
+
+	// created by a database operator
+	pgdata := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Name:            "pgdata",
+		Namespace:       "test",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "postgresql.cnpg.io/v1", Kind: "Cluster", Name: "main"}},
+	}}
+	if _, pvcErr := kube.CoreV1().PersistentVolumeClaims("test").Create(context.TODO(), pgdata, metav1.CreateOptions{}); pvcErr != nil {
+		t.Fatalf("Error injecting pvc add: %v", pvcErr)
+	}
+	for _, name := range []string{"scratch-1", "home"} {
+		if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), name, "test"); pvcErr != nil {
+			t.Fatalf("Error injecting pvc add: %v", pvcErr)
+		}
+	}
+
+	cfg := structInternal.ControllerConfig{
+		Scope:           structInternal.NamespaceScope{Selector: structInternal.DefaultNamespaceSelector},
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		Audit:           structInternal.AuditConfig{Namespace: "das"},
+		Clock:           testInternal.NewFakeClock(time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC)),
+	}
+
+	// marked before the rules existed
+	SetPvcState(context.TODO(), kube, cfg.StateKeys(), structInternal.VolumeState{
+		DetachedAt: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+	}, getPvc(t, kube, "test", "scratch-1"))
+
+	cfg.Protection = structInternal.Protection{Rules: []structInternal.ProtectionRule{
+		{Name: "operators", OwnerKinds: []string{"Cluster"}},
+		{Name: "scratch", NamePattern: regexp.MustCompile("^scratch-")},
+	}}
+
+	resetAuditShards(t)
+	InitialScan(context.TODO(), kube, cfg)
+
+	assert.NotContains(t, getPvc(t, kube, "test", "pgdata").Labels, cfg.TimeLabel)
+	assert.NotContains(t, getPvc(t, kube, "test", "scratch-1").Labels, cfg.TimeLabel)
+	assert.NotContains(t, getPvc(t, kube, "test", "scratch-1").Annotations, cfg.StateAnnotation)
+	assert.Contains(t, getPvc(t, kube, "test", "home").Labels, cfg.TimeLabel)
+
+	entries, err := ReadAudit(context.TODO(), kube, "das")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		unmark := entries[0]
+		if unmark.Action != structInternal.AuditUnmark {
+			unmark = entries[1]
+		}
+		assert.Equal(t, "scratch-1", unmark.Name)
+		assert.Equal(t, "protected by rule scratch", unmark.Reason)
+	}
+
+	// a stateful set letting go of a protected pvc does not mark it either
+	detachClaim(context.TODO(), kube, cfg, "test", "pgdata", "db")
+	assert.NotContains(t, getPvc(t, kube, "test", "pgdata").Labels, cfg.TimeLabel)
+
+	report := Reconcile(context.TODO(), kube, cfg)
+	assert.Equal(t, 0, report.Labelled)
+	assert.ElementsMatch(t, []structInternal.ProtectedVolume{
+		{Namespace: "test", Name: "pgdata", Rule: "operators"},
+		{Namespace: "test", Name: "scratch-1", Rule: "scratch"},
+	}, report.Protected)
+}
+
 func TestResetLabels(t *testing.T) {
 
 	t.Run("successful resetting of labels on controller startup", func(t *testing.T) {
@@ -286,47 +618,49 @@ func TestResetLabels(t *testing.T) {
 		}
 
 		cfg := structInternal.ControllerConfig{
-			Namespace:  "test",
-			TimeLabel:  "volume-cleaner/unattached-time",
-			NotifLabel: "volume-cleaner/notification-count",
-			TimeFormat: "2006-01-02_15-04-05Z",
+			Namespace:       "test",
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           structInternal.RealClock{},
 		}
 
-		InitialScan(kube, cfg)
+		InitialScan(context.TODO(), kube, cfg)
 
 		// pvcs should be labelled
-		pvcs := PvcList(kube, "test")
+		pvcs := listPvcs(t, kube, "test")
 
 		_, ok := pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, true)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, true)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, true)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, true)
 
-		ResetLabels(kube, cfg)
+		ResetLabels(context.TODO(), kube, cfg)
 
 		time.Sleep(2 * time.Second)
 
 		// should have all labels removed
 
-		pvcs = PvcList(kube, "test")
+		pvcs = listPvcs(t, kube, "test")
 
 		_, ok = pvcs[0].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[0].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[0].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 		_, ok = pvcs[1].Labels["volume-cleaner/unattached-time"]
 		assert.Equal(t, ok, false)
 
-		_, ok = pvcs[1].Labels["volume-cleaner/notification-count"]
+		_, ok = pvcs[1].Annotations["volume-cleaner/state"]
 		assert.Equal(t, ok, false)
 
 	})
@@ -424,3 +758,53 @@ func TestIgnoreStorageClass(t *testing.T) {
 		})
 	}
 }
+
+func TestWatcherScope(t *testing.T) {
+
+	t.Run("statefulsets outside the namespace scope are ignored", func(t *testing.T) {
+		kube := testInternal.NewFakeClient()
+
+		labels := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+		for _, ns := range []string{"team-a", "kube-system"} {
+			if namespaceErr := kube.CreateNamespace(context.TODO(), ns, labels); namespaceErr != nil {
+				t.Fatalf("Error injecting namespace add: %v", namespaceErr)
+			}
+			if _, pvcErr := kube.CreatePersistentVolumeClaim(context.TODO(), "pvc1", ns); pvcErr != nil {
+				t.Fatalf("Error injecting pvc add: %v", pvcErr)
+			}
+			if stsErr := kube.CreateStatefulSetWithPvc(context.TODO(), "sts1", ns, "pvc1"); stsErr != nil {
+				t.Fatalf("Error injecting sts add: %v", stsErr)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg := structInternal.ControllerConfig{
+			Scope:           structInternal.NamespaceScope{Exclude: []string{"kube-*"}},
+			TimeLabel:       "volume-cleaner/unattached-time",
+			NotifLabel:      "volume-cleaner/notification-count",
+			StateAnnotation: "volume-cleaner/state",
+			TimeFormat:      "2006-01-02_15-04-05Z",
+			Clock:           testInternal.NewFakeClock(time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)),
+		}
+
+		go WatchSts(ctx, kube, structInternal.NewLive(cfg), structInternal.NewHealth(structInternal.RealClock{}))
+
+		// give the watcher time to start
+		time.Sleep(500 * time.Millisecond)
+
+		for _, ns := range []string{"team-a", "kube-system"} {
+			if stsErr := kube.DeleteStatefulSet(context.TODO(), "sts1", ns); stsErr != nil {
+				t.Fatalf("Error injecting sts delete: %v", stsErr)
+			}
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		assert.Equal(t, "2025-06-01_12-00-00Z", listPvcs(t, kube, "team-a")[0].Labels["volume-cleaner/unattached-time"])
+
+		_, ok := listPvcs(t, kube, "kube-system")[0].Labels["volume-cleaner/unattached-time"]
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,58 @@
+package structure
+
+import (
+	// standard packages
+	"encoding/json"
+	"time"
+)
+
+// who changed a volume
+const (
+	ActorController = "controller"
+	ActorScheduler  = "scheduler"
+	// followed by the name of the kubernetes user, e.g. "cli:jane.doe@statcan.gc.ca"
+	ActorCLIPrefix = "cli:"
+)
+
+// what was done to a volume
+const (
+	// the time label and state were added, the grace period started
+	AuditMark = "mark"
+	// the time label and state were removed, the volume is in use again
+	AuditUnmark = "unmark"
+	// days were added to the grace period
+	AuditExtend = "extend"
+	// the scheduler recorded a notice or the policy it applies in the state
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	// a deleted pvc was recreated from its archive
+	AuditRestore = "restore"
+)
+
+// a single change made to a volume, entries are only ever appended
+
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+
+	// PersistentVolumeClaim or PersistentVolume
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+
+	Reason string `json:"reason"`
+
+	// the spec of the volume when the action was taken
+	Spec json.RawMessage `json:"spec,omitempty"`
+}
+
+// returns true if the entry matches every filter that is set
+
+func (e AuditEntry) Matches(namespace string, name string, action string, since time.Time) bool {
+	return (namespace == "" || e.Namespace == namespace) &&
+		(name == "" || e.Name == name) &&
+		(action == "" || e.Action == action) &&
+		!e.Time.Before(since)
+}
@@ -0,0 +1,95 @@
+package structure
+
+import (
+	// standard packages
+	"time"
+)
+
+// layout used to key holidays by calendar date
+const DateLayout = "2006-01-02"
+
+// describes which days count towards a grace period
+// the zero value counts every day, which matches the original 24 hour day behaviour
+
+type Calendar struct {
+	BusinessDays bool
+	Holidays     map[string]struct{}
+	Location     *time.Location
+}
+
+// returns the location used to decide which calendar date a time falls on, defaults to UTC
+func (c Calendar) Zone() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// returns true if the date is a configured holiday
+func (c Calendar) IsHoliday(t time.Time) bool {
+	_, ok := c.Holidays[t.In(c.Zone()).Format(DateLayout)]
+	return ok
+}
+
+// returns true if the date is neither a weekend nor a holiday
+// always true when business days are not enabled
+func (c Calendar) IsBusinessDay(t time.Time) bool {
+	if !c.BusinessDays {
+		return true
+	}
+
+	local := t.In(c.Zone())
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.IsHoliday(local)
+}
+
+// returns the moment a grace period of the given number of days that started at start runs out
+// with business days enabled, weekends and holidays are skipped so the deadline always
+// lands on a business day at the same time of day as start
+
+func (c Calendar) Deadline(start time.Time, days int) time.Time {
+	if !c.BusinessDays {
+		return start.Add(time.Duration(days) * 24 * time.Hour)
+	}
+
+	deadline := start.In(c.Zone())
+	for counted := 0; counted < days; {
+		deadline = deadline.AddDate(0, 0, 1)
+		if c.IsBusinessDay(deadline) {
+			counted++
+		}
+	}
+	return deadline
+}
+
+// returns the number of (business) days between now and the end of the grace period
+// the value is negative once the grace period has passed
+
+func (c Calendar) DaysLeft(now time.Time, start time.Time, days int) float64 {
+	if !c.BusinessDays {
+		return float64(days) - now.Sub(start).Hours()/24
+	}
+
+	deadline := c.Deadline(start, days)
+	if !now.Before(deadline) {
+		return -now.Sub(deadline).Hours() / 24
+	}
+
+	// count whole business days between the two dates, then correct for
+	// the time of day so the result behaves like the 24 hour day version
+	local := now.In(c.Zone())
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Zone())
+	to := deadline.In(c.Zone())
+	until := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, c.Zone())
+
+	businessDays := 0
+	for day := from.AddDate(0, 0, 1); !day.After(until); day = day.AddDate(0, 0, 1) {
+		if c.IsBusinessDay(day) {
+			businessDays++
+		}
+	}
+
+	return float64(businessDays) + (to.Sub(until)-local.Sub(from)).Hours()/24
+}
@@ -0,0 +1,116 @@
+package structure
+
+import (
+	// standard packages
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarBusinessDays(t *testing.T) {
+	cal := Calendar{
+		BusinessDays: true,
+		Holidays:     map[string]struct{}{"2025-12-25": {}, "2025-12-26": {}},
+	}
+
+	tests := []struct {
+		name     string
+		input    time.Time
+		expected bool
+	}{
+		{
+			name:     "weekday",
+			input:    time.Date(2025, time.December, 23, 12, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "saturday",
+			input:    time.Date(2025, time.December, 20, 12, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "sunday",
+			input:    time.Date(2025, time.December, 21, 12, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "holiday",
+			input:    time.Date(2025, time.December, 25, 12, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, cal.IsBusinessDay(tt.input))
+		})
+	}
+
+	t.Run("disabled calendar treats every day as a business day", func(t *testing.T) {
+		assert.True(t, Calendar{}.IsBusinessDay(time.Date(2025, time.December, 25, 12, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("holidays are evaluated in the configured location", func(t *testing.T) {
+		toronto, err := time.LoadLocation("America/Toronto")
+		if err != nil {
+			t.Skipf("timezone database unavailable: %s", err)
+		}
+		local := Calendar{BusinessDays: true, Holidays: cal.Holidays, Location: toronto}
+
+		// 2am UTC on the 26th is the evening of the 25th in Toronto
+		assert.False(t, local.IsBusinessDay(time.Date(2025, time.December, 26, 2, 0, 0, 0, time.UTC)))
+		// 2am UTC on the 25th is the evening of the 24th in Toronto
+		assert.True(t, local.IsBusinessDay(time.Date(2025, time.December, 25, 2, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestCalendarDeadline(t *testing.T) {
+	// tuesday before christmas
+	start := time.Date(2025, time.December, 23, 12, 0, 0, 0, time.UTC)
+
+	t.Run("raw days", func(t *testing.T) {
+		assert.Equal(t, start.Add(5*24*time.Hour), Calendar{}.Deadline(start, 5))
+	})
+
+	t.Run("business days skip weekends and holidays", func(t *testing.T) {
+		cal := Calendar{
+			BusinessDays: true,
+			Holidays:     map[string]struct{}{"2025-12-25": {}, "2025-12-26": {}},
+		}
+		// wed 24, (thu 25, fri 26, sat 27, sun 28 skipped), mon 29, tue 30
+		assert.Equal(t, time.Date(2025, time.December, 30, 12, 0, 0, 0, time.UTC), cal.Deadline(start, 3))
+		assert.Equal(t, start, cal.Deadline(start, 0))
+	})
+}
+
+func TestCalendarDaysLeft(t *testing.T) {
+	start := time.Date(2025, time.December, 23, 12, 0, 0, 0, time.UTC)
+
+	t.Run("raw days", func(t *testing.T) {
+		now := start.Add(36 * time.Hour)
+		assert.InDelta(t, 3.5, Calendar{}.DaysLeft(now, start, 5), 1e-9)
+	})
+
+	t.Run("business days", func(t *testing.T) {
+		cal := Calendar{
+			BusinessDays: true,
+			Holidays:     map[string]struct{}{"2025-12-25": {}, "2025-12-26": {}},
+		}
+
+		// deadline is tue 30 at noon
+
+		// at the start, all three business days remain
+		assert.InDelta(t, 3, cal.DaysLeft(start, start, 3), 1e-9)
+
+		// over the holidays and the weekend, only mon 29 and tue 30 remain
+		assert.InDelta(t, 2, cal.DaysLeft(time.Date(2025, time.December, 27, 12, 0, 0, 0, time.UTC), start, 3), 1e-9)
+
+		// time of day is still taken into account
+		assert.InDelta(t, 1.5, cal.DaysLeft(time.Date(2025, time.December, 29, 0, 0, 0, 0, time.UTC), start, 3), 1e-9)
+
+		// past the deadline the value goes negative
+		assert.InDelta(t, -1, cal.DaysLeft(time.Date(2025, time.December, 31, 12, 0, 0, 0, time.UTC), start, 3), 1e-9)
+	})
+}
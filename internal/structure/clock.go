@@ -0,0 +1,25 @@
+package structure
+
+import (
+	// standard packages
+	"time"
+)
+
+// abstracts the wall clock so that time based logic (staleness, emails, labelling)
+// can be driven deterministically in tests and simulations
+
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+}
+
+// default clock backed by the time package
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
@@ -1,26 +1,72 @@
 package structure
 
+import (
+	// standard packages
+	"slices"
+	"time"
+)
+
 /*
 Example configs
 
 controller:
 
 NAMESPACE: "anray-liu"
+NAMESPACE_SELECTOR: "app.kubernetes.io/part-of=kubeflow-profile"
+INCLUDE_NAMESPACES: "team-*, anray-liu"
+EXCLUDE_NAMESPACES: "kube-*, kubeflow"
 TIME_LABEL: "volume-cleaner/unattached-time"
 NOTIF_LABEL: "volume-cleaner/notification-count"
+STATE_ANNOTATION: "volume-cleaner/state"
 TIME_FORMAT: "2006-01-02_15-04-05Z"
 STORAGE_CLASSES: "default"
+RECONCILE_INTERVAL: "6h"
+USAGE_ANNOTATION: "volume-cleaner/last-mounted"
+AUDIT_NAMESPACE: "das"
+AUDIT_WEBHOOK_URL: "https://audit.example.ca/volume-cleaner"
+WEBHOOK_ADDR: ":8443"
+WEBHOOK_CERT_FILE: "/etc/volume-cleaner/tls/tls.crt"
+WEBHOOK_KEY_FILE: "/etc/volume-cleaner/tls/tls.key"
+PROTECTION_FILE: "/etc/volume-cleaner-protection/rules.yaml"
 
 scheduler:
 
 NAMESPACE: "anray-liu"
+NAMESPACE_SELECTOR: "app.kubernetes.io/part-of=kubeflow-profile"
+INCLUDE_NAMESPACES: "team-*, anray-liu"
+EXCLUDE_NAMESPACES: "kube-*, kubeflow"
 TIME_LABEL: "volume-cleaner/unattached-time"
 NOTIF_LABEL: "volume-cleaner/notification-count"
+STATE_ANNOTATION: "volume-cleaner/state"
 IGNORE_LABEL: "volume-cleaner/ignore"
 GRACE_PERIOD: "180"
 TIME_FORMAT: "2006-01-02_15-04-05Z"
 DRY_RUN: "true"
 NOTIF_TIMES: "1, 2, 3, 4, 7, 30"
+BUSINESS_DAYS: "true"
+HOLIDAYS: "2025-12-25, 2026-01-01"
+HOLIDAY_FILE: "/etc/volume-cleaner/holidays.ics"
+TIMEZONE: "America/Toronto"
+SCHEDULE: "0 0 * * *"
+USAGE_ANNOTATION: "volume-cleaner/last-mounted"
+PRICES: "default=0.05, standard=0.05, managed-premium=0.15"
+CURRENCY: "CAD"
+SWEEP_VOLUMES: "true"
+DELETE_DISKS: "false"
+CONCURRENCY: "8"
+KUBE_QPS: "20"
+EMAIL_QPS: "5"
+ARCHIVE_ENDPOINT: "https://minio.example.ca"
+ARCHIVE_BUCKET: "volume-archives"
+ARCHIVE_REGION: "us-east-1"
+ARCHIVE_ACCESS_KEY: "Random ACCESSKEY"
+ARCHIVE_SECRET_KEY: "Random SECRETKEY"
+ARCHIVE_IMAGE: "volume-cleaner-scheduler:latest"
+ARCHIVE_STORAGE_CLASSES: "default, managed-premium"
+ARCHIVE_TIMEOUT: "1h"
+AUDIT_NAMESPACE: "das"
+AUDIT_WEBHOOK_URL: "https://audit.example.ca/volume-cleaner"
+PROTECTION_FILE: "/etc/volume-cleaner-protection/rules.yaml"
 
 BASE_URL: "https://api.notification.canada.ca",
 ENDPOINT: "/v2/notifications/email",
@@ -29,24 +75,51 @@ API_KEY: "Random APIKEY",
 */
 
 type ControllerConfig struct {
-	Namespace      string
-	TimeLabel      string
-	NotifLabel     string
-	TimeFormat     string
-	StorageClasses []string
-	ResetRun       bool
+	Namespace            string
+	Scope                NamespaceScope
+	TimeLabel            string
+	NotifLabel           string
+	StateAnnotation      string
+	TimeFormat           string
+	StorageClasses       []string
+	ResetRun             bool
+	ReconcileInterval    time.Duration
+	UsageAnnotation      string
+	HealthAddr           string
+	WatchStaleness       time.Duration
+	ConfigReloadInterval time.Duration
+	Audit                AuditConfig
+	Webhook              WebhookConfig
+	Protection           Protection
+	Clock                Clock
 }
 
 type SchedulerConfig struct {
-	Namespace   string
-	TimeLabel   string
-	NotifLabel  string
-	IgnoreLabel string
-	TimeFormat  string
-	GracePeriod int
-	DryRun      bool
-	NotifTimes  []int
-	EmailCfg    EmailConfig
+	Namespace       string
+	Scope           NamespaceScope
+	TimeLabel       string
+	NotifLabel      string
+	StateAnnotation string
+	IgnoreLabel     string
+	TimeFormat      string
+	GracePeriod     int
+	DryRun          bool
+	NotifTimes      []int
+	UsageAnnotation string
+	Prices          PriceTable
+	SweepVolumes    bool
+	DeleteDisks     bool
+	Concurrency     int
+	KubeQPS         float64
+	EmailQPS        float64
+	RunTimeout      time.Duration
+	Archive         ArchiveConfig
+	Audit           AuditConfig
+	Protection      Protection
+	EmailCfg        EmailConfig
+	Clock           Clock
+	Calendar        Calendar
+	Schedule        Schedule
 }
 
 type EmailConfig struct {
@@ -56,6 +129,57 @@ type EmailConfig struct {
 	APIKey          string
 }
 
+// s3 compatible storage the contents of stale pvcs are uploaded to before they are deleted
+
+type ArchiveConfig struct {
+	// empty when pvcs are deleted without being archived
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	// image of the job uploading a pvc, it has to contain the archiver
+	Image string
+
+	// storage classes whose pvcs are archived, all of them when empty
+	StorageClasses []string
+
+	// longest an archive job may run
+	Timeout time.Duration
+}
+
+// returns true if pvcs are archived before they are deleted
+func (cfg ArchiveConfig) Enabled() bool {
+	return cfg.Endpoint != ""
+}
+
+// returns true if pvcs of the storage class are archived
+func (cfg ArchiveConfig) Archives(storageClass string) bool {
+	return cfg.Enabled() && (len(cfg.StorageClasses) == 0 || slices.Contains(cfg.StorageClasses, storageClass))
+}
+
+// where the label changes and deletions of volume-cleaner are recorded, both sinks are optional
+
+type AuditConfig struct {
+	// namespace of the audit configmaps, empty when they are not written
+	Namespace string
+
+	// receives every audit entry as a json POST
+	WebhookURL string
+}
+
+// admission webhook warning about workloads that use pvcs marked as unattached
+
+type WebhookConfig struct {
+	// empty when the webhook is not served
+	Addr string
+
+	// certificate and key served to the api server, read again when they change
+	CertFile string
+	KeyFile  string
+}
+
 // For internal use
 
 // Represents the main request body structure for sending Email Notifications with GC Notify
@@ -71,4 +195,10 @@ type Personalisation struct {
 	VolumeName   string `json:"volume_name"`
 	DaysLeft     string `json:"days_left"`
 	DeletionDate string `json:"deletion_date"`
+	Size         string `json:"size"`
+	StorageClass string `json:"storage_class"`
+
+	// empty when no price is configured for the storage class
+	MonthlyCost          string `json:"monthly_cost"`
+	NamespaceMonthlyCost string `json:"namespace_monthly_cost"`
 }
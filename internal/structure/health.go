@@ -0,0 +1,175 @@
+package structure
+
+import (
+	// standard packages
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// how long a watch may go without an event or heartbeat before the controller is no longer ready
+const DefaultWatchStaleness = time.Minute
+
+// state of the controller reported by the health endpoints
+// written by the watchers and the initial scan, read by the http handlers
+
+type Health struct {
+	mu      sync.Mutex
+	clock   Clock
+	synced  bool
+	watches map[string]WatchState
+}
+
+// progress of a single watch
+
+type WatchState struct {
+	Started time.Time `json:"started"`
+
+	// zero until the first event is received
+	LastEvent time.Time `json:"lastEvent"`
+
+	// last event or heartbeat, an idle watch loop still reports that it is running
+	LastSeen time.Time `json:"lastSeen"`
+
+	Events int `json:"events"`
+
+	// times the watch was opened again after the api server ended it
+	Restarts int `json:"restarts"`
+
+	// the watch ended and could not be opened again yet, it is retried until it is
+	Closed bool `json:"closed"`
+}
+
+func NewHealth(clock Clock) *Health {
+	return &Health{clock: clock, watches: make(map[string]WatchState)}
+}
+
+// marks the initial scan as complete
+func (h *Health) SetSynced() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.synced = true
+}
+
+// returns true once the initial scan is complete
+func (h *Health) Synced() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.synced
+}
+
+// registers a watch, or resets it when the same watch is started again
+func (h *Health) WatchStarted(name string) {
+	h.update(name, func(state *WatchState) {
+		now := h.clock.Now()
+		*state = WatchState{Started: now, LastSeen: now}
+	})
+}
+
+// records an event received by a watch
+func (h *Health) WatchEvent(name string) {
+	h.update(name, func(state *WatchState) {
+		state.LastEvent = h.clock.Now()
+		state.LastSeen = state.LastEvent
+		state.Events++
+	})
+}
+
+// records that an idle watch loop is still running
+func (h *Health) WatchAlive(name string) {
+	h.update(name, func(state *WatchState) {
+		state.LastSeen = h.clock.Now()
+	})
+}
+
+// records that a watch was opened again
+func (h *Health) WatchRestarted(name string) {
+	h.update(name, func(state *WatchState) {
+		state.LastSeen = h.clock.Now()
+		state.Restarts++
+		state.Closed = false
+	})
+}
+
+// records that a watch ended and could not be opened again yet
+func (h *Health) WatchClosed(name string) {
+	h.update(name, func(state *WatchState) {
+		state.Closed = true
+	})
+}
+
+func (h *Health) update(name string, change func(state *WatchState)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state := h.watches[name]
+	change(&state)
+	h.watches[name] = state
+}
+
+// returns a copy of every watch state keyed by name
+func (h *Health) Watches() map[string]WatchState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return maps.Clone(h.watches)
+}
+
+// returns nil when every watch loop was seen within staleness, the initial scan and closed watches
+// are left to Ready. a watch loop that stopped running is only fixed by a restart
+
+func (h *Health) Live(staleness time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	names := make([]string, 0, len(h.watches))
+	for name := range h.watches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if since := h.clock.Since(h.watches[name].LastSeen); since > staleness {
+			errs = append(errs, fmt.Errorf("watch %s was last seen %s ago", name, since.Round(time.Second)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// returns nil when the initial scan is complete and every watch is open and was seen within staleness
+// otherwise every reason the controller is not ready is returned
+
+func (h *Health) Ready(staleness time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.synced {
+		return errors.New("initial scan has not completed")
+	}
+	if len(h.watches) == 0 {
+		return errors.New("no watch has started")
+	}
+
+	names := make([]string, 0, len(h.watches))
+	for name := range h.watches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		state := h.watches[name]
+		if state.Closed {
+			errs = append(errs, fmt.Errorf("watch %s is closed", name))
+			continue
+		}
+		if since := h.clock.Since(state.LastSeen); since > staleness {
+			errs = append(errs, fmt.Errorf("watch %s was last seen %s ago", name, since.Round(time.Second)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,32 @@
+package structure
+
+import (
+	// standard packages
+	"sync"
+)
+
+// holds a value (e.g. the controller config) that can be swapped while other goroutines read it
+// used to apply config file changes without restarting the controller
+
+type Live[T any] struct {
+	mu    sync.RWMutex
+	value T
+}
+
+func NewLive[T any](value T) *Live[T] {
+	return &Live[T]{value: value}
+}
+
+// returns a copy of the current value
+func (l *Live[T]) Get() T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.value
+}
+
+// replaces the current value
+func (l *Live[T]) Set(value T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.value = value
+}
@@ -0,0 +1,32 @@
+package structure
+
+import (
+	// standard packages
+	"sync"
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLive(t *testing.T) {
+	live := NewLive(ControllerConfig{StorageClasses: []string{"default"}})
+	assert.Equal(t, []string{"default"}, live.Get().StorageClasses)
+
+	// concurrent readers and writers must not race (run with -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			live.Set(ControllerConfig{StorageClasses: []string{"standard"}})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = live.Get()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"standard"}, live.Get().StorageClasses)
+}
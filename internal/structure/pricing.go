@@ -0,0 +1,39 @@
+package structure
+
+import (
+	// standard packages
+	"fmt"
+)
+
+// bytes in one GiB, prices are per GiB per month
+const bytesPerGiB = 1 << 30
+
+// monthly price of one GiB of storage per storage class
+// the zero value has no prices, which disables cost estimation
+
+type PriceTable struct {
+	Prices   map[string]float64
+	Currency string
+}
+
+// returns true if at least one storage class has a price
+func (p PriceTable) IsSet() bool {
+	return len(p.Prices) > 0
+}
+
+// returns the monthly cost of a volume, false if its storage class has no price
+func (p PriceTable) MonthlyCost(storageClass string, bytes int64) (float64, bool) {
+	price, ok := p.Prices[storageClass]
+	if !ok {
+		return 0, false
+	}
+	return price * float64(bytes) / bytesPerGiB, true
+}
+
+// formats an amount with two decimals followed by the currency, e.g. "12.50 CAD"
+func (p PriceTable) Format(amount float64) string {
+	if p.Currency == "" {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, p.Currency)
+}
@@ -0,0 +1,46 @@
+package structure
+
+import (
+	// standard packages
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceTable(t *testing.T) {
+	prices := PriceTable{Prices: map[string]float64{"standard": 0.05, "premium": 0.15}, Currency: "CAD"}
+
+	assert.True(t, prices.IsSet())
+	assert.False(t, PriceTable{}.IsSet())
+
+	cost, ok := prices.MonthlyCost("premium", 10<<30)
+	assert.True(t, ok)
+	assert.InDelta(t, 1.5, cost, 1e-9)
+
+	cost, ok = prices.MonthlyCost("standard", 512<<20)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.025, cost, 1e-9)
+
+	_, ok = prices.MonthlyCost("unknown", 10<<30)
+	assert.False(t, ok)
+
+	assert.Equal(t, "1.50 CAD", prices.Format(1.5))
+	assert.Equal(t, "0.03", PriceTable{}.Format(0.025))
+}
+
+func TestRunReportCost(t *testing.T) {
+	prices := PriceTable{Prices: map[string]float64{"standard": 0.05, "premium": 0.15}}
+
+	report := NewRunReport()
+	report.AddReclaimed(CapacityKey{Namespace: "ns1", StorageClass: "standard"}, 100<<30)
+	report.AddReclaimed(CapacityKey{Namespace: "ns2", StorageClass: "premium"}, 10<<30)
+	report.AddPending(CapacityKey{Namespace: "ns2", StorageClass: "premium"}, 20<<30)
+
+	// unpriced storage classes are not counted
+	report.AddReclaimed(CapacityKey{Namespace: "ns1", StorageClass: "unknown"}, 100<<30)
+
+	assert.InDelta(t, 6.5, report.MonthlySavings(prices), 1e-9)
+	assert.InDelta(t, 3.0, report.PendingMonthlyCost(prices), 1e-9)
+	assert.Equal(t, 0.0, report.MonthlySavings(PriceTable{}))
+}
@@ -0,0 +1,95 @@
+package structure
+
+import (
+	// standard packages
+	"regexp"
+	"slices"
+
+	// external packages
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+/*
+Protection rules keep pvcs out of volume-cleaner altogether: the controller never marks a protected
+pvc as unattached (and removes the mark of one that was protected afterwards), and the scheduler
+never notifies about or deletes one. Rules are read from PROTECTION_FILE, e.g.
+
+	- name: databases
+	  selector: "app in (postgres, mysql)"
+	- name: scratch
+	  namePattern: "^scratch-"
+	- name: pinned
+	  annotation: "example.com/keep=true"
+	- name: operators
+	  ownerKinds: ["Cluster", "Kafka"]
+
+Every condition set in a rule has to match, a pvc matching any rule is protected.
+*/
+
+type ProtectionRule struct {
+	// shown in logs and reports as "protected by rule <name>"
+	Name string
+
+	// nil when the rule does not look at labels
+	Selector labels.Selector
+
+	// nil when the rule does not look at names
+	NamePattern *regexp.Regexp
+
+	// empty when the rule does not look at annotations. an empty value only requires the key
+	Annotation      string
+	AnnotationValue string
+
+	// kinds of the owner references, e.g. "Cluster" for pvcs created by a database operator
+	OwnerKinds []string
+}
+
+// returns true if the rule has at least one condition, a rule without any would protect everything
+func (r ProtectionRule) HasCondition() bool {
+	return r.Selector != nil || r.NamePattern != nil || r.Annotation != "" || len(r.OwnerKinds) > 0
+}
+
+// returns true if every condition of the rule matches the object
+func (r ProtectionRule) Matches(object metav1.ObjectMeta) bool {
+	if !r.HasCondition() {
+		return false
+	}
+
+	if r.Selector != nil && !r.Selector.Matches(labels.Set(object.Labels)) {
+		return false
+	}
+
+	if r.NamePattern != nil && !r.NamePattern.MatchString(object.Name) {
+		return false
+	}
+
+	if r.Annotation != "" {
+		value, ok := object.Annotations[r.Annotation]
+		if !ok || (r.AnnotationValue != "" && value != r.AnnotationValue) {
+			return false
+		}
+	}
+
+	if len(r.OwnerKinds) > 0 && !slices.ContainsFunc(object.OwnerReferences, func(owner metav1.OwnerReference) bool {
+		return slices.Contains(r.OwnerKinds, owner.Kind)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+type Protection struct {
+	Rules []ProtectionRule
+}
+
+// returns the name of the first rule protecting the object, false if none does
+func (p Protection) Protects(object metav1.ObjectMeta) (string, bool) {
+	for _, rule := range p.Rules {
+		if rule.Matches(object) {
+			return rule.Name, true
+		}
+	}
+	return "", false
+}
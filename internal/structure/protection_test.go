@@ -0,0 +1,69 @@
+package structure
+
+import (
+	// standard packages
+	"regexp"
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestProtectionRuleMatches(t *testing.T) {
+	pvc := metav1.ObjectMeta{
+		Name:        "pgdata-main-1",
+		Labels:      map[string]string{"app": "postgres"},
+		Annotations: map[string]string{"example.com/keep": "true"},
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "postgresql.cnpg.io/v1", Kind: "Cluster", Name: "main"},
+		},
+	}
+
+	t.Run("single conditions", func(t *testing.T) {
+		assert.True(t, ProtectionRule{Selector: labels.SelectorFromSet(labels.Set{"app": "postgres"})}.Matches(pvc))
+		assert.False(t, ProtectionRule{Selector: labels.SelectorFromSet(labels.Set{"app": "mysql"})}.Matches(pvc))
+
+		assert.True(t, ProtectionRule{NamePattern: regexp.MustCompile("^pgdata-")}.Matches(pvc))
+		assert.False(t, ProtectionRule{NamePattern: regexp.MustCompile("^scratch-")}.Matches(pvc))
+
+		assert.True(t, ProtectionRule{Annotation: "example.com/keep"}.Matches(pvc))
+		assert.True(t, ProtectionRule{Annotation: "example.com/keep", AnnotationValue: "true"}.Matches(pvc))
+		assert.False(t, ProtectionRule{Annotation: "example.com/keep", AnnotationValue: "false"}.Matches(pvc))
+		assert.False(t, ProtectionRule{Annotation: "example.com/pinned"}.Matches(pvc))
+
+		assert.True(t, ProtectionRule{OwnerKinds: []string{"Kafka", "Cluster"}}.Matches(pvc))
+		assert.False(t, ProtectionRule{OwnerKinds: []string{"Kafka"}}.Matches(metav1.ObjectMeta{Name: "data"}))
+	})
+
+	t.Run("every condition of a rule has to match", func(t *testing.T) {
+		rule := ProtectionRule{
+			NamePattern: regexp.MustCompile("^pgdata-"),
+			OwnerKinds:  []string{"Cluster"},
+		}
+		assert.True(t, rule.Matches(pvc))
+
+		rule.OwnerKinds = []string{"Kafka"}
+		assert.False(t, rule.Matches(pvc))
+	})
+
+	t.Run("a rule without conditions protects nothing", func(t *testing.T) {
+		assert.False(t, ProtectionRule{Name: "empty"}.Matches(pvc))
+	})
+
+	t.Run("the first matching rule is reported", func(t *testing.T) {
+		protection := Protection{Rules: []ProtectionRule{
+			{Name: "scratch", NamePattern: regexp.MustCompile("^scratch-")},
+			{Name: "databases", Selector: labels.SelectorFromSet(labels.Set{"app": "postgres"})},
+			{Name: "operators", OwnerKinds: []string{"Cluster"}},
+		}}
+
+		rule, ok := protection.Protects(pvc)
+		assert.True(t, ok)
+		assert.Equal(t, "databases", rule)
+
+		_, ok = protection.Protects(metav1.ObjectMeta{Name: "home"})
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,197 @@
+package structure
+
+import (
+	// standard packages
+	"time"
+)
+
+// Summary of a controller reconciliation: how far the labels had drifted from the cluster state
+
+type DriftReport struct {
+	Namespaces int
+	Scanned    int
+
+	// labels added to unattached pvcs the watchers missed
+	Labelled int
+
+	// labels removed from pvcs that are attached again
+	Unlabelled int
+
+	// pvcs whose usage was recorded because a running pod mounts them
+	Mounted int
+
+	// unattached pvcs that are not labelled because of a protection rule
+	Protected []ProtectedVolume
+
+	// list calls that failed, the affected namespaces are left as they are until the next pass
+	Errors int
+
+	// audit entries that could not be recorded since the previous pass, including those of the watchers
+	AuditErrors int
+}
+
+// returns the total number of pvcs that had to be corrected
+func (r DriftReport) Drift() int {
+	return r.Labelled + r.Unlabelled
+}
+
+// Summary of a scheduler run
+
+type RunReport struct {
+	Errors  int
+	Deleted int
+	Emailed int
+
+	// released pvs deleted by the sweeper
+	VolumesDeleted int
+
+	// volumes left for the next run because the run was stopped early
+	Skipped int
+
+	// pvcs uploaded to the archive before they were deleted
+	Archived []ArchiveRecord
+
+	// labelled pvcs left alone because of a protection rule
+	Protected []ProtectedVolume
+
+	// audit entries that could not be recorded
+	AuditErrors int
+
+	// bytes per namespace and storage class
+	Capacity map[CapacityKey]CapacityTotals
+
+	// pvc results of every managed namespace, including those without stale pvcs
+	Namespaces map[string]NamespaceSummary
+}
+
+// pvc results of a single namespace
+
+type NamespaceSummary struct {
+	// labelled pvcs found in the namespace
+	Scanned int
+
+	Errors  int
+	Deleted int
+	Emailed int
+}
+
+// a pvc left alone because of a protection rule
+
+type ProtectedVolume struct {
+	Namespace string
+	Name      string
+	Rule      string
+}
+
+// where the contents of a pvc were archived
+// stored as json next to the archive, so the pvc can be restored once it is gone
+
+type ArchiveRecord struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+
+	// of the uploaded tarball
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+
+	// what the pvc is recreated with
+	StorageClass string   `json:"storageClass,omitempty"`
+	Capacity     string   `json:"capacity"`
+	AccessModes  []string `json:"accessModes,omitempty"`
+
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+// groups pvcs in the capacity summary
+
+type CapacityKey struct {
+	Namespace    string
+	StorageClass string
+}
+
+type CapacityTotals struct {
+	// bytes of the pvcs deleted during the run
+	Reclaimed int64
+
+	// bytes of the unattached pvcs that are still waiting for deletion
+	Pending int64
+}
+
+func NewRunReport() RunReport {
+	return RunReport{
+		Capacity:   make(map[CapacityKey]CapacityTotals),
+		Namespaces: make(map[string]NamespaceSummary),
+	}
+}
+
+func (r *RunReport) AddReclaimed(key CapacityKey, bytes int64) {
+	totals := r.Capacity[key]
+	totals.Reclaimed += bytes
+	r.Capacity[key] = totals
+}
+
+func (r *RunReport) AddPending(key CapacityKey, bytes int64) {
+	totals := r.Capacity[key]
+	totals.Pending += bytes
+	r.Capacity[key] = totals
+}
+
+// adds the counters and capacity of another report, e.g. the result of a single volume
+
+func (r *RunReport) Merge(other RunReport) {
+	r.Errors += other.Errors
+	r.Deleted += other.Deleted
+	r.Emailed += other.Emailed
+	r.VolumesDeleted += other.VolumesDeleted
+	r.Skipped += other.Skipped
+	r.AuditErrors += other.AuditErrors
+	r.Archived = append(r.Archived, other.Archived...)
+	r.Protected = append(r.Protected, other.Protected...)
+
+	for key, totals := range other.Capacity {
+		r.AddReclaimed(key, totals.Reclaimed)
+		r.AddPending(key, totals.Pending)
+	}
+
+	for namespace, summary := range other.Namespaces {
+		current := r.Namespaces[namespace]
+		current.Scanned += summary.Scanned
+		current.Errors += summary.Errors
+		current.Deleted += summary.Deleted
+		current.Emailed += summary.Emailed
+		r.Namespaces[namespace] = current
+	}
+}
+
+// returns the capacity summed over every namespace and storage class
+func (r RunReport) Total() CapacityTotals {
+	total := CapacityTotals{}
+	for _, totals := range r.Capacity {
+		total.Reclaimed += totals.Reclaimed
+		total.Pending += totals.Pending
+	}
+	return total
+}
+
+// returns the monthly cost of the volumes deleted during the run
+// storage classes without a price are not counted
+func (r RunReport) MonthlySavings(prices PriceTable) float64 {
+	savings := 0.0
+	for key, totals := range r.Capacity {
+		cost, _ := prices.MonthlyCost(key.StorageClass, totals.Reclaimed)
+		savings += cost
+	}
+	return savings
+}
+
+// returns the monthly cost of the volumes still waiting for deletion
+// storage classes without a price are not counted
+func (r RunReport) PendingMonthlyCost(prices PriceTable) float64 {
+	pending := 0.0
+	for key, totals := range r.Capacity {
+		cost, _ := prices.MonthlyCost(key.StorageClass, totals.Pending)
+		pending += cost
+	}
+	return pending
+}
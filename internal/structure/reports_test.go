@@ -0,0 +1,63 @@
+package structure
+
+import (
+	// standard packages
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReportCapacity(t *testing.T) {
+	report := NewRunReport()
+
+	standard := CapacityKey{Namespace: "ns1", StorageClass: "standard"}
+	premium := CapacityKey{Namespace: "ns2", StorageClass: "premium"}
+
+	report.AddReclaimed(standard, 10)
+	report.AddReclaimed(standard, 5)
+	report.AddPending(standard, 1)
+	report.AddPending(premium, 20)
+
+	assert.Equal(t, CapacityTotals{Reclaimed: 15, Pending: 1}, report.Capacity[standard])
+	assert.Equal(t, CapacityTotals{Pending: 20}, report.Capacity[premium])
+	assert.Equal(t, CapacityTotals{Reclaimed: 15, Pending: 21}, report.Total())
+
+	assert.Equal(t, CapacityTotals{}, NewRunReport().Total())
+}
+
+func TestDriftReport(t *testing.T) {
+	assert.Equal(t, 3, DriftReport{Namespaces: 2, Scanned: 10, Labelled: 1, Unlabelled: 2}.Drift())
+}
+
+func TestRunReportMerge(t *testing.T) {
+	key := CapacityKey{Namespace: "ns1", StorageClass: "standard"}
+
+	report := NewRunReport()
+	report.Errors = 1
+	report.AddReclaimed(key, 10)
+	report.Namespaces["ns1"] = NamespaceSummary{Scanned: 2, Errors: 1}
+
+	other := NewRunReport()
+	other.Deleted = 1
+	other.Emailed = 2
+	other.VolumesDeleted = 3
+	other.AddReclaimed(key, 5)
+	other.AddPending(key, 1)
+	other.Namespaces["ns1"] = NamespaceSummary{Scanned: 1, Deleted: 1}
+	other.Namespaces["ns2"] = NamespaceSummary{Scanned: 1, Emailed: 2}
+	other.Archived = []ArchiveRecord{{Namespace: "ns1", Name: "pvc1", URL: "http://minio.das:9000/archives/ns1/pvc1.tar.gz", Size: 5}}
+	other.Protected = []ProtectedVolume{{Namespace: "ns2", Name: "pgdata", Rule: "databases"}}
+
+	report.Merge(other)
+
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 1, report.Deleted)
+	assert.Equal(t, 2, report.Emailed)
+	assert.Equal(t, 3, report.VolumesDeleted)
+	assert.Equal(t, CapacityTotals{Reclaimed: 15, Pending: 1}, report.Capacity[key])
+	assert.Equal(t, NamespaceSummary{Scanned: 3, Errors: 1, Deleted: 1}, report.Namespaces["ns1"])
+	assert.Equal(t, NamespaceSummary{Scanned: 1, Emailed: 2}, report.Namespaces["ns2"])
+	assert.Equal(t, other.Archived, report.Archived)
+	assert.Equal(t, other.Protected, report.Protected)
+}
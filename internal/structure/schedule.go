@@ -0,0 +1,72 @@
+package structure
+
+import (
+	// standard packages
+	"time"
+)
+
+// cron schedule of the scheduler job (e.g. "0 0 * * *"), used to predict when it will next run
+// each field holds the allowed values, the zero value never runs and leaves times unchanged
+
+type Schedule struct {
+	Minutes  map[int]struct{}
+	Hours    map[int]struct{}
+	Days     map[int]struct{}
+	Months   map[int]struct{}
+	Weekdays map[int]struct{}
+
+	// cron matches on day of month OR day of week when both are restricted
+	DaysRestricted     bool
+	WeekdaysRestricted bool
+}
+
+// returns true if the schedule was configured
+func (s Schedule) IsSet() bool {
+	return len(s.Minutes) > 0
+}
+
+// returns true if the schedule runs on the date of t
+func (s Schedule) matchesDay(t time.Time) bool {
+	if _, ok := s.Months[int(t.Month())]; !ok {
+		return false
+	}
+
+	_, dayOk := s.Days[t.Day()]
+	_, weekdayOk := s.Weekdays[int(t.Weekday())]
+
+	if s.DaysRestricted && s.WeekdaysRestricted {
+		return dayOk || weekdayOk
+	}
+	return dayOk && weekdayOk
+}
+
+// returns the first run strictly after t
+// schedules are evaluated in UTC, like kubernetes cronjobs without a timeZone
+// returns t unchanged if the schedule is not set or never runs within the next five years
+
+func (s Schedule) Next(t time.Time) time.Time {
+	if !s.IsSet() {
+		return t
+	}
+
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+
+	for next.Before(limit) {
+		if !s.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if _, ok := s.Hours[next.Hour()]; !ok {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if _, ok := s.Minutes[next.Minute()]; !ok {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+
+	return t
+}
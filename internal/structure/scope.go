@@ -0,0 +1,57 @@
+package structure
+
+import (
+	// standard packages
+	"path"
+
+	// external packages
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// label selecting kubeflow profile namespaces, used when NAMESPACE_SELECTOR is not set
+const DefaultNamespaceSelector = "app.kubernetes.io/part-of=kubeflow-profile"
+
+// The namespaces managed by the volume cleaner
+// a namespace must match the selector and the include list, and must not match the exclude list
+// the zero value manages every namespace
+
+type NamespaceScope struct {
+	// label selector, evaluated by the api server when listing namespaces
+	Selector string
+
+	// names or glob patterns (e.g. "team-*"), every namespace is included when empty
+	Include []string
+
+	// names or glob patterns of namespaces that are never managed, e.g. "kube-*"
+	// wins over the include list so platform namespaces stay protected
+	Exclude []string
+}
+
+// returns true if a namespace with the given name and labels is managed
+
+func (s NamespaceScope) Matches(name string, nsLabels map[string]string) bool {
+	if s.Selector != "" {
+		selector, err := labels.Parse(s.Selector)
+		if err != nil || !selector.Matches(labels.Set(nsLabels)) {
+			return false
+		}
+	}
+
+	if len(s.Include) > 0 && !matchesAny(s.Include, name) {
+		return false
+	}
+
+	return !matchesAny(s.Exclude, name)
+}
+
+// returns true if the name matches one of the glob patterns
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// bad patterns are reported by validation, they never match
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
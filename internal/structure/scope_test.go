@@ -0,0 +1,45 @@
+package structure
+
+import (
+	// standard packages
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceScopeMatches(t *testing.T) {
+	kubeflow := map[string]string{"app.kubernetes.io/part-of": "kubeflow-profile"}
+
+	t.Run("zero value manages every namespace", func(t *testing.T) {
+		assert.True(t, NamespaceScope{}.Matches("anything", nil))
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		scope := NamespaceScope{Selector: DefaultNamespaceSelector}
+
+		assert.True(t, scope.Matches("profile", kubeflow))
+		assert.False(t, scope.Matches("platform", nil))
+		assert.False(t, NamespaceScope{Selector: "=bad"}.Matches("profile", kubeflow))
+	})
+
+	t.Run("include and exclude globs", func(t *testing.T) {
+		scope := NamespaceScope{
+			Include: []string{"team-*", "shared"},
+			Exclude: []string{"team-admin", "*-system"},
+		}
+
+		assert.True(t, scope.Matches("team-a", nil))
+		assert.True(t, scope.Matches("shared", nil))
+		assert.False(t, scope.Matches("other", nil))
+		assert.False(t, scope.Matches("team-admin", nil))
+		assert.False(t, scope.Matches("team-system", nil))
+	})
+
+	t.Run("exclude only", func(t *testing.T) {
+		scope := NamespaceScope{Selector: DefaultNamespaceSelector, Exclude: []string{"kube-*"}}
+
+		assert.True(t, scope.Matches("profile", kubeflow))
+		assert.False(t, scope.Matches("kube-profile", kubeflow))
+	})
+}
@@ -47,6 +47,19 @@ func (s *Set) Difference(otherSet *Set) *Set {
 	return newSet
 }
 
+// returns a new set with values in both self and otherSet
+func (s *Set) Intersection(otherSet *Set) *Set {
+	newSet := NewSet()
+
+	for v := range s.list {
+		if otherSet.Has(v) {
+			newSet.Add(v)
+		}
+	}
+
+	return newSet
+}
+
 func NewSet() *Set {
 	s := &Set{}
 	s.list = make(map[string]struct{})
@@ -141,3 +141,23 @@ func TestSetDifference(t *testing.T) {
 
 	})
 }
+
+func TestSetIntersection(t *testing.T) {
+
+	t.Run("valid set intersection", func(t *testing.T) {
+		newSet := func(values ...string) *Set {
+			s := NewSet()
+			for _, v := range values {
+				s.Add(v)
+			}
+			return s
+		}
+
+		s := newSet("1", "2", "3")
+
+		assert.Equal(t, newSet(), s.Intersection(NewSet()))
+		assert.Equal(t, newSet("1"), s.Intersection(newSet("1", "4")))
+		assert.Equal(t, newSet("1", "2", "3"), s.Intersection(newSet("3", "2", "1")))
+		assert.Equal(t, newSet(), NewSet().Intersection(s))
+	})
+}
@@ -0,0 +1,178 @@
+package structure
+
+import (
+	// standard packages
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+/*
+The lifecycle of an unattached volume is stored as json in the STATE_ANNOTATION annotation, e.g.
+
+	{
+	  "detachedAt": "2025-07-01T12:00:00Z",
+	  "notificationsSent": [{"sentAt": "2025-07-20T00:00:00Z", "channel": "email"}],
+	  "lastWorkload": "statefulset/web",
+	  "policy": {"gracePeriod": 30, "notifTimes": [10, 1]},
+	  "extensions": [{"at": "2025-07-25T09:00:00Z", "days": 14, "reason": "thesis data"}]
+	}
+
+TIME_LABEL is kept next to it, holding the detach time, so that unattached volumes can still be
+found with a label selector. Volumes labelled by earlier versions (TIME_LABEL and NOTIF_LABEL
+without the annotation) are migrated the first time they are read.
+*/
+
+// annotation holding the state when STATE_ANNOTATION is not set, so that deployments configured
+// before it existed keep starting and have their labels migrated
+const DefaultStateAnnotation = "volume-cleaner/state"
+
+// channel of the notifications sent by the scheduler
+const EmailChannel = "email"
+
+// the highest notification count label of earlier versions that is migrated, the label can be
+// edited by hand and every notification it counts is written to the annotation
+const MaxLegacyNotifications = 100
+
+type VolumeState struct {
+	// when the volume lost its last workload
+	DetachedAt time.Time `json:"detachedAt"`
+
+	NotificationsSent []Notification `json:"notificationsSent,omitempty"`
+
+	// the workload the volume was detached from (e.g. "statefulset/web"), empty if never known
+	LastWorkload string `json:"lastWorkload,omitempty"`
+
+	// grace period and notification days the scheduler applied last
+	Policy *Policy `json:"policy,omitempty"`
+
+	// extra days added to the grace period, e.g. by an operator editing the annotation
+	Extensions []Extension `json:"extensions,omitempty"`
+}
+
+type Notification struct {
+	// zero for notifications migrated from the notification count label
+	SentAt  time.Time `json:"sentAt,omitzero"`
+	Channel string    `json:"channel"`
+}
+
+type Policy struct {
+	GracePeriod int   `json:"gracePeriod"`
+	NotifTimes  []int `json:"notifTimes,omitempty"`
+}
+
+type Extension struct {
+	At     time.Time `json:"at"`
+	Days   int       `json:"days"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// the most days a single extension, and all of them together, can add to a grace period
+const MaxExtensionDays = 365
+
+// returns true if the extension adds between one and MaxExtensionDays days
+// the annotation can be edited by hand, a negative value would shorten the grace period
+func (e Extension) Valid() bool {
+	return e.Days >= 1 && e.Days <= MaxExtensionDays
+}
+
+// returns the total number of days added by the valid extensions, at most MaxExtensionDays
+func (s VolumeState) ExtensionDays() int {
+	days := 0
+	for _, extension := range s.Extensions {
+		if extension.Valid() {
+			days += extension.Days
+		}
+	}
+	return min(days, MaxExtensionDays)
+}
+
+// returns the policy the scheduler applies with this config
+func (cfg SchedulerConfig) Policy() Policy {
+	return Policy{GracePeriod: cfg.GracePeriod, NotifTimes: slices.Clone(cfg.NotifTimes)}
+}
+
+// returns true if the recorded policy matches p
+func (s VolumeState) HasPolicy(p Policy) bool {
+	return s.Policy != nil && s.Policy.GracePeriod == p.GracePeriod && slices.Equal(s.Policy.NotifTimes, p.NotifTimes)
+}
+
+// returns the json stored in the annotation
+func (s VolumeState) Encode() string {
+	// only plain values, marshalling cannot fail
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
+
+func DecodeVolumeState(value string) (VolumeState, error) {
+	var state VolumeState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return VolumeState{}, fmt.Errorf("invalid state annotation: %w", err)
+	}
+	if state.DetachedAt.IsZero() {
+		return VolumeState{}, fmt.Errorf("invalid state annotation: detachedAt is missing")
+	}
+	return state, nil
+}
+
+// the metadata keys holding the state of a volume
+
+type StateKeys struct {
+	TimeLabel       string
+	NotifLabel      string
+	StateAnnotation string
+	TimeFormat      string
+}
+
+func (cfg ControllerConfig) StateKeys() StateKeys {
+	return StateKeys{TimeLabel: cfg.TimeLabel, NotifLabel: cfg.NotifLabel, StateAnnotation: cfg.StateAnnotation, TimeFormat: cfg.TimeFormat}
+}
+
+func (cfg SchedulerConfig) StateKeys() StateKeys {
+	return StateKeys{TimeLabel: cfg.TimeLabel, NotifLabel: cfg.NotifLabel, StateAnnotation: cfg.StateAnnotation, TimeFormat: cfg.TimeFormat}
+}
+
+// reads the state of a volume from its labels and annotations
+// ok is false when the volume is not marked as unattached. legacy is true when the state was
+// built from the labels of an earlier version and still has to be written to the annotation
+
+func (keys StateKeys) Read(labels map[string]string, annotations map[string]string) (state VolumeState, ok bool, legacy bool, err error) {
+	if value, found := annotations[keys.StateAnnotation]; found {
+		state, err = DecodeVolumeState(value)
+		return state, err == nil, false, err
+	}
+
+	timestamp, found := labels[keys.TimeLabel]
+	if !found {
+		return VolumeState{}, false, false, nil
+	}
+
+	detachedAt, err := time.Parse(keys.TimeFormat, timestamp)
+	if err != nil {
+		return VolumeState{}, false, false, fmt.Errorf("invalid label %s: %w", keys.TimeLabel, err)
+	}
+	state = VolumeState{DetachedAt: detachedAt.UTC()}
+
+	// a missing count is the same as a pvc labelled a moment ago
+	if count, found := labels[keys.NotifLabel]; found {
+		sent, err := strconv.Atoi(count)
+		if err != nil || sent < 0 {
+			return VolumeState{}, false, false, fmt.Errorf("invalid label %s: %q is not a notification count", keys.NotifLabel, count)
+		}
+		if sent > MaxLegacyNotifications {
+			return VolumeState{}, false, false, fmt.Errorf("invalid label %s: %d notifications is more than %d", keys.NotifLabel, sent, MaxLegacyNotifications)
+		}
+		for range sent {
+			state.NotificationsSent = append(state.NotificationsSent, Notification{Channel: EmailChannel})
+		}
+	}
+
+	return state, true, true, nil
+}
+
+// returns the value of the time label for a state
+func (keys StateKeys) TimeValue(state VolumeState) string {
+	return state.DetachedAt.UTC().Format(keys.TimeFormat)
+}
@@ -0,0 +1,107 @@
+package structure
+
+import (
+	// standard packages
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeys = StateKeys{
+	TimeLabel:       "volume-cleaner/unattached-time",
+	NotifLabel:      "volume-cleaner/notification-count",
+	StateAnnotation: "volume-cleaner/state",
+	TimeFormat:      "2006-01-02_15-04-05Z",
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	detached := time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)
+	state := VolumeState{
+		DetachedAt:        detached,
+		NotificationsSent: []Notification{{SentAt: detached.AddDate(0, 0, 20), Channel: EmailChannel}},
+		LastWorkload:      "statefulset/web",
+		Policy:            &Policy{GracePeriod: 30, NotifTimes: []int{10, 1}},
+		Extensions:        []Extension{{At: detached.AddDate(0, 0, 25), Days: 14, Reason: "thesis data"}, {Days: 7}},
+	}
+
+	decoded, err := DecodeVolumeState(state.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, state, decoded)
+	assert.Equal(t, 21, decoded.ExtensionDays())
+
+	assert.True(t, decoded.HasPolicy(Policy{GracePeriod: 30, NotifTimes: []int{10, 1}}))
+	assert.False(t, decoded.HasPolicy(Policy{GracePeriod: 30, NotifTimes: []int{10}}))
+	assert.False(t, VolumeState{}.HasPolicy(Policy{GracePeriod: 30}))
+
+	// extensions edited by hand can neither shorten the grace period nor pin the volume forever
+	state.Extensions = []Extension{{Days: -30}, {Days: 0}, {Days: 7}, {Days: 100000}}
+	assert.Equal(t, 7, state.ExtensionDays())
+	state.Extensions = []Extension{{Days: 300}, {Days: 300}}
+	assert.Equal(t, MaxExtensionDays, state.ExtensionDays())
+
+	_, err = DecodeVolumeState(`{"notificationsSent": []}`)
+	assert.ErrorContains(t, err, "detachedAt is missing")
+
+	_, err = DecodeVolumeState(`not json`)
+	assert.ErrorContains(t, err, "invalid state annotation")
+}
+
+func TestStateRead(t *testing.T) {
+	detached := time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("annotation", func(t *testing.T) {
+		annotations := map[string]string{testKeys.StateAnnotation: VolumeState{DetachedAt: detached}.Encode()}
+
+		// the annotation wins over labels left behind
+		labels := map[string]string{testKeys.TimeLabel: "2025-01-01_00-00-00Z", testKeys.NotifLabel: "3"}
+
+		state, ok, legacy, err := testKeys.Read(labels, annotations)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, legacy)
+		assert.Equal(t, VolumeState{DetachedAt: detached}, state)
+		assert.Equal(t, "2025-07-01_12-00-00Z", testKeys.TimeValue(state))
+	})
+
+	t.Run("legacy labels are migrated", func(t *testing.T) {
+		labels := map[string]string{testKeys.TimeLabel: "2025-07-01_12-00-00Z", testKeys.NotifLabel: "2"}
+
+		state, ok, legacy, err := testKeys.Read(labels, nil)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, legacy)
+		assert.Equal(t, detached, state.DetachedAt)
+		assert.Equal(t, []Notification{{Channel: EmailChannel}, {Channel: EmailChannel}}, state.NotificationsSent)
+
+		// a missing count means no notification was sent
+		state, ok, _, err = testKeys.Read(map[string]string{testKeys.TimeLabel: "2025-07-01_12-00-00Z"}, nil)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Empty(t, state.NotificationsSent)
+	})
+
+	t.Run("not marked as unattached", func(t *testing.T) {
+		_, ok, _, err := testKeys.Read(map[string]string{testKeys.NotifLabel: "1"}, nil)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		_, _, _, err := testKeys.Read(map[string]string{testKeys.TimeLabel: "yesterday"}, nil)
+		assert.ErrorContains(t, err, testKeys.TimeLabel)
+
+		_, _, _, err = testKeys.Read(map[string]string{testKeys.TimeLabel: "2025-07-01_12-00-00Z", testKeys.NotifLabel: "-1"}, nil)
+		assert.ErrorContains(t, err, testKeys.NotifLabel)
+
+		// a hand edited count is not expanded into the annotation
+		_, ok, _, err := testKeys.Read(map[string]string{testKeys.TimeLabel: "2025-07-01_12-00-00Z", testKeys.NotifLabel: "999999999"}, nil)
+		assert.ErrorContains(t, err, "999999999 notifications is more than 100")
+		assert.False(t, ok)
+
+		_, ok, _, err = testKeys.Read(nil, map[string]string{testKeys.StateAnnotation: "{"})
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,329 @@
+package structure
+
+import (
+	// standard packages
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	// external packages
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// checks every field of the controller config and reports all problems at once
+
+func (cfg ControllerConfig) Validate() error {
+	var errs []error
+
+	errs = append(errs, validateLabelKey("TIME_LABEL", cfg.TimeLabel))
+	errs = append(errs, validateLabelKey("NOTIF_LABEL", cfg.NotifLabel))
+	// defaulted when not set
+	if cfg.StateAnnotation != "" {
+		errs = append(errs, validateLabelKey("STATE_ANNOTATION", cfg.StateAnnotation))
+	}
+	errs = append(errs, validateTimeFormat(cfg.TimeFormat))
+	errs = append(errs, validateScope(cfg.Scope))
+
+	// usage tracking is optional
+	if cfg.UsageAnnotation != "" {
+		errs = append(errs, validateLabelKey("USAGE_ANNOTATION", cfg.UsageAnnotation))
+	}
+
+	if cfg.ReconcileInterval < 0 {
+		errs = append(errs, fmt.Errorf("RECONCILE_INTERVAL: must not be negative, got %s", cfg.ReconcileInterval))
+	}
+
+	// the health endpoints are optional
+	if cfg.HealthAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.HealthAddr); err != nil {
+			errs = append(errs, fmt.Errorf("HEALTH_ADDR: %w", err))
+		}
+	}
+	if cfg.WatchStaleness <= 0 {
+		errs = append(errs, fmt.Errorf("WATCH_STALENESS: must be positive, got %s", cfg.WatchStaleness))
+	}
+	if cfg.ConfigReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("CONFIG_RELOAD_INTERVAL: must be positive, got %s", cfg.ConfigReloadInterval))
+	}
+
+	errs = append(errs, cfg.Audit.Validate())
+	errs = append(errs, cfg.Webhook.Validate())
+	errs = append(errs, cfg.Protection.Validate())
+
+	if cfg.Clock == nil {
+		errs = append(errs, errors.New("clock is not set"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// checks every field of the scheduler config and reports all problems at once
+
+func (cfg SchedulerConfig) Validate() error {
+	var errs []error
+
+	errs = append(errs, validateLabelKey("TIME_LABEL", cfg.TimeLabel))
+	errs = append(errs, validateLabelKey("NOTIF_LABEL", cfg.NotifLabel))
+	// defaulted when not set
+	if cfg.StateAnnotation != "" {
+		errs = append(errs, validateLabelKey("STATE_ANNOTATION", cfg.StateAnnotation))
+	}
+
+	// the ignore label is optional
+	if cfg.IgnoreLabel != "" {
+		errs = append(errs, validateLabelKey("IGNORE_LABEL", cfg.IgnoreLabel))
+	}
+
+	if cfg.UsageAnnotation != "" {
+		errs = append(errs, validateLabelKey("USAGE_ANNOTATION", cfg.UsageAnnotation))
+	}
+
+	errs = append(errs, validateTimeFormat(cfg.TimeFormat))
+	errs = append(errs, validateScope(cfg.Scope))
+
+	if cfg.GracePeriod < 1 {
+		errs = append(errs, fmt.Errorf("GRACE_PERIOD: must be at least one day, got %d", cfg.GracePeriod))
+	}
+
+	for _, days := range cfg.NotifTimes {
+		if days < 0 || days > cfg.GracePeriod {
+			errs = append(errs, fmt.Errorf("NOTIF_TIMES: %d is not within the grace period of %d days", days, cfg.GracePeriod))
+		}
+	}
+
+	// disks are only deleted along with the pvs found by the sweeper
+	if cfg.DeleteDisks && !cfg.SweepVolumes {
+		errs = append(errs, errors.New("DELETE_DISKS: requires SWEEP_VOLUMES to be enabled"))
+	}
+
+	if cfg.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("CONCURRENCY: must be at least one worker, got %d", cfg.Concurrency))
+	}
+	if cfg.KubeQPS < 0 {
+		errs = append(errs, fmt.Errorf("KUBE_QPS: must not be negative, got %g", cfg.KubeQPS))
+	}
+	if cfg.EmailQPS < 0 {
+		errs = append(errs, fmt.Errorf("EMAIL_QPS: must not be negative, got %g", cfg.EmailQPS))
+	}
+	if cfg.RunTimeout < 0 {
+		errs = append(errs, fmt.Errorf("RUN_TIMEOUT: must not be negative, got %s", cfg.RunTimeout))
+	}
+
+	if cfg.Clock == nil {
+		errs = append(errs, errors.New("clock is not set"))
+	}
+
+	errs = append(errs, cfg.Archive.Validate())
+	errs = append(errs, cfg.Audit.Validate())
+	errs = append(errs, cfg.Protection.Validate())
+	errs = append(errs, cfg.EmailCfg.Validate(cfg.DryRun))
+
+	return errors.Join(errs...)
+}
+
+// checks the archive config, nothing is required when archiving is off
+
+func (cfg ArchiveConfig) Validate() error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	parsed, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("ARCHIVE_ENDPOINT: %w", err))
+	} else if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("ARCHIVE_ENDPOINT: %q must be an absolute http(s) url", cfg.Endpoint))
+	}
+
+	if cfg.Bucket == "" {
+		errs = append(errs, errors.New("ARCHIVE_BUCKET: must be set when ARCHIVE_ENDPOINT is set"))
+	}
+	if cfg.Region == "" {
+		errs = append(errs, errors.New("ARCHIVE_REGION: must not be empty"))
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		errs = append(errs, errors.New("ARCHIVE_ACCESS_KEY, ARCHIVE_SECRET_KEY: must be set when ARCHIVE_ENDPOINT is set"))
+	}
+	if cfg.Image == "" {
+		errs = append(errs, errors.New("ARCHIVE_IMAGE: must be set when ARCHIVE_ENDPOINT is set"))
+	}
+
+	// presigned urls are valid for seven days at most
+	if cfg.Timeout <= 0 || cfg.Timeout > 7*24*time.Hour {
+		errs = append(errs, fmt.Errorf("ARCHIVE_TIMEOUT: must be positive and at most 168h, got %s", cfg.Timeout))
+	}
+
+	return errors.Join(errs...)
+}
+
+// checks the audit sinks that are set
+
+func (cfg AuditConfig) Validate() error {
+	var errs []error
+
+	if cfg.Namespace != "" {
+		if problems := validation.IsDNS1123Label(cfg.Namespace); len(problems) > 0 {
+			errs = append(errs, fmt.Errorf("AUDIT_NAMESPACE: %q is not a valid namespace: %s", cfg.Namespace, strings.Join(problems, "; ")))
+		}
+	}
+
+	if cfg.WebhookURL != "" {
+		parsed, err := url.Parse(cfg.WebhookURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("AUDIT_WEBHOOK_URL: %w", err))
+		} else if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("AUDIT_WEBHOOK_URL: %q must be an absolute http(s) url", cfg.WebhookURL))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checks the protection rules, each needs a unique name and at least one condition
+
+func (p Protection) Validate() error {
+	var errs []error
+
+	seen := make(map[string]bool)
+	for _, rule := range p.Rules {
+		switch {
+		case rule.Name == "":
+			errs = append(errs, errors.New("PROTECTION_FILE: every rule needs a name"))
+		case seen[rule.Name]:
+			errs = append(errs, fmt.Errorf("PROTECTION_FILE: rule %q is defined twice", rule.Name))
+		}
+		seen[rule.Name] = true
+
+		if !rule.HasCondition() {
+			errs = append(errs, fmt.Errorf("PROTECTION_FILE: rule %q has no selector, namePattern, annotation or ownerKinds", rule.Name))
+		}
+		if rule.Annotation != "" {
+			errs = append(errs, validateLabelKey(fmt.Sprintf("PROTECTION_FILE: rule %q: annotation", rule.Name), rule.Annotation))
+		}
+		if slices.Contains(rule.OwnerKinds, "") {
+			errs = append(errs, fmt.Errorf("PROTECTION_FILE: rule %q: owner kinds must not be empty", rule.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checks the webhook config, the certificate is only required when the webhook is served
+
+func (cfg WebhookConfig) Validate() error {
+	if cfg.Addr == "" {
+		return nil
+	}
+
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("WEBHOOK_ADDR: %w", err))
+	}
+	// the api server only calls webhooks over https
+	if cfg.CertFile == "" {
+		errs = append(errs, errors.New("WEBHOOK_CERT_FILE: required when WEBHOOK_ADDR is set"))
+	}
+	if cfg.KeyFile == "" {
+		errs = append(errs, errors.New("WEBHOOK_KEY_FILE: required when WEBHOOK_ADDR is set"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// checks the email config, secrets are only required when emails will actually be sent
+
+func (cfg EmailConfig) Validate(dryRun bool) error {
+	var errs []error
+
+	parsed, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("BASE_URL: %w", err))
+	} else if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("BASE_URL: %q must be an absolute http(s) url", cfg.BaseURL))
+	}
+
+	if _, err := url.Parse(cfg.BaseURL + cfg.Endpoint); err != nil {
+		errs = append(errs, fmt.Errorf("ENDPOINT: %w", err))
+	}
+
+	if !dryRun {
+		if cfg.APIKey == "" {
+			errs = append(errs, errors.New("API_KEY: must be set when not running in dry run mode"))
+		}
+		if cfg.EmailTemplateID == "" {
+			errs = append(errs, errors.New("EMAIL_TEMPLATE_ID: must be set when not running in dry run mode"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// label keys must be qualified names, e.g. volume-cleaner/unattached-time
+
+func validateLabelKey(name string, key string) error {
+	if key == "" {
+		return fmt.Errorf("%s: must not be empty", name)
+	}
+	if problems := validation.IsQualifiedName(key); len(problems) > 0 {
+		return fmt.Errorf("%s: %q is not a valid label key: %s", name, key, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// the selector has to parse and every include / exclude entry has to be a valid glob
+
+func validateScope(scope NamespaceScope) error {
+	var errs []error
+
+	if _, err := labels.Parse(scope.Selector); err != nil {
+		errs = append(errs, fmt.Errorf("NAMESPACE_SELECTOR: %q is not a valid label selector: %w", scope.Selector, err))
+	}
+
+	for _, pattern := range scope.Include {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("INCLUDE_NAMESPACES: %q is not a valid pattern", pattern))
+		}
+	}
+
+	for _, pattern := range scope.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("EXCLUDE_NAMESPACES: %q is not a valid pattern", pattern))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// the time format is used to write timestamps into label values and read them back
+// so it has to survive a round trip and only produce valid label values
+
+func validateTimeFormat(format string) error {
+	if format == "" {
+		return errors.New("TIME_FORMAT: must not be empty")
+	}
+
+	reference := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	formatted := reference.Format(format)
+
+	parsed, err := time.Parse(format, formatted)
+	if err != nil {
+		return fmt.Errorf("TIME_FORMAT: %q cannot be parsed back: %w", format, err)
+	}
+	if !parsed.Equal(reference) {
+		return fmt.Errorf("TIME_FORMAT: %q loses precision, %s was read back as %s", format, reference, parsed)
+	}
+	if problems := validation.IsValidLabelValue(formatted); len(problems) > 0 {
+		return fmt.Errorf("TIME_FORMAT: %q does not produce a valid label value: %s", format, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package structure
+
+import (
+	// standard packages
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func validSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		IgnoreLabel:     "volume-cleaner/ignore",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		GracePeriod:     180,
+		NotifTimes:      []int{30, 7, 1},
+		Concurrency:     1,
+		Clock:           RealClock{},
+		EmailCfg: EmailConfig{
+			BaseURL:         "https://api.notification.canada.ca",
+			Endpoint:        "/v2/notifications/email",
+			EmailTemplateID: "Random Template",
+			APIKey:          "Random APIKEY",
+		},
+	}
+}
+
+func validArchiveConfig() ArchiveConfig {
+	return ArchiveConfig{
+		Endpoint:  "http://minio.das:9000",
+		Bucket:    "volume-archives",
+		Region:    "us-east-1",
+		AccessKey: "Random ACCESSKEY",
+		SecretKey: "Random SECRETKEY",
+		Image:     "volume-cleaner-scheduler:latest",
+		Timeout:   time.Hour,
+	}
+}
+
+func TestControllerConfigValidate(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		cfg := ControllerConfig{
+			TimeLabel:            "volume-cleaner/unattached-time",
+			NotifLabel:           "volume-cleaner/notification-count",
+			StateAnnotation:      "volume-cleaner/state",
+			TimeFormat:           "2006-01-02_15-04-05Z",
+			HealthAddr:           ":8080",
+			WatchStaleness:       time.Minute,
+			ConfigReloadInterval: 30 * time.Second,
+			Clock:                RealClock{},
+		}
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		err := ControllerConfig{
+			NotifLabel:        "not a label!",
+			ReconcileInterval: -1,
+			UsageAnnotation:   "last mounted",
+			HealthAddr:        "8080",
+			Audit:             AuditConfig{Namespace: "Volume Cleaner", WebhookURL: "audit.example.ca"},
+			Webhook:           WebhookConfig{Addr: ":8443", KeyFile: "/etc/volume-cleaner/tls/tls.key"},
+		}.Validate()
+
+		assert.ErrorContains(t, err, "TIME_LABEL: must not be empty")
+		assert.ErrorContains(t, err, "RECONCILE_INTERVAL")
+		assert.ErrorContains(t, err, "USAGE_ANNOTATION")
+		assert.ErrorContains(t, err, "HEALTH_ADDR")
+		assert.ErrorContains(t, err, "WATCH_STALENESS")
+		assert.ErrorContains(t, err, "CONFIG_RELOAD_INTERVAL")
+		assert.ErrorContains(t, err, "NOTIF_LABEL")
+		assert.ErrorContains(t, err, "TIME_FORMAT: must not be empty")
+		assert.ErrorContains(t, err, "clock is not set")
+		assert.ErrorContains(t, err, "AUDIT_NAMESPACE")
+		assert.ErrorContains(t, err, "AUDIT_WEBHOOK_URL")
+		assert.ErrorContains(t, err, "WEBHOOK_CERT_FILE: required")
+		assert.NotContains(t, err.Error(), "WEBHOOK_KEY_FILE")
+	})
+}
+
+func TestSchedulerConfigValidate(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		assert.NoError(t, validSchedulerConfig().Validate())
+	})
+
+	tests := []struct {
+		name     string
+		modify   func(cfg *SchedulerConfig)
+		expected string
+	}{
+		{
+			name:     "invalid label key",
+			modify:   func(cfg *SchedulerConfig) { cfg.IgnoreLabel = "volume-cleaner/ignore me" },
+			expected: "IGNORE_LABEL",
+		},
+		{
+			name:     "time format with spaces is not a valid label value",
+			modify:   func(cfg *SchedulerConfig) { cfg.TimeFormat = "2006-01-02 15:04:05" },
+			expected: "does not produce a valid label value",
+		},
+		{
+			name:     "time format without seconds loses precision",
+			modify:   func(cfg *SchedulerConfig) { cfg.TimeFormat = "2006-01-02_15-04" },
+			expected: "loses precision",
+		},
+		{
+			name:     "grace period too low",
+			modify:   func(cfg *SchedulerConfig) { cfg.GracePeriod = 0 },
+			expected: "GRACE_PERIOD",
+		},
+		{
+			name:     "notification outside grace period",
+			modify:   func(cfg *SchedulerConfig) { cfg.NotifTimes = []int{365, 1} },
+			expected: "NOTIF_TIMES: 365",
+		},
+		{
+			name:     "invalid namespace selector",
+			modify:   func(cfg *SchedulerConfig) { cfg.Scope.Selector = "part-of in (" },
+			expected: "NAMESPACE_SELECTOR",
+		},
+		{
+			name:     "invalid namespace pattern",
+			modify:   func(cfg *SchedulerConfig) { cfg.Scope.Exclude = []string{"kube-[system"} },
+			expected: "EXCLUDE_NAMESPACES",
+		},
+		{
+			name:     "disks deleted without the sweeper",
+			modify:   func(cfg *SchedulerConfig) { cfg.DeleteDisks = true },
+			expected: "DELETE_DISKS",
+		},
+		{
+			name:     "no workers",
+			modify:   func(cfg *SchedulerConfig) { cfg.Concurrency = 0 },
+			expected: "CONCURRENCY",
+		},
+		{
+			name:     "negative rate",
+			modify:   func(cfg *SchedulerConfig) { cfg.EmailQPS = -1 },
+			expected: "EMAIL_QPS",
+		},
+		{
+			name:     "negative run timeout",
+			modify:   func(cfg *SchedulerConfig) { cfg.RunTimeout = -time.Hour },
+			expected: "RUN_TIMEOUT",
+		},
+		{
+			name: "archive without bucket",
+			modify: func(cfg *SchedulerConfig) {
+				cfg.Archive = validArchiveConfig()
+				cfg.Archive.Bucket = ""
+			},
+			expected: "ARCHIVE_BUCKET",
+		},
+		{
+			name: "archive timeout longer than a presigned url lasts",
+			modify: func(cfg *SchedulerConfig) {
+				cfg.Archive = validArchiveConfig()
+				cfg.Archive.Timeout = 30 * 24 * time.Hour
+			},
+			expected: "ARCHIVE_TIMEOUT",
+		},
+		{
+			name:     "invalid audit namespace",
+			modify:   func(cfg *SchedulerConfig) { cfg.Audit.Namespace = "das/audit" },
+			expected: "AUDIT_NAMESPACE",
+		},
+		{
+			name: "protection rule without condition",
+			modify: func(cfg *SchedulerConfig) {
+				cfg.Protection = Protection{Rules: []ProtectionRule{{Name: "everything"}}}
+			},
+			expected: `PROTECTION_FILE: rule "everything" has no selector`,
+		},
+		{
+			name: "duplicate protection rule",
+			modify: func(cfg *SchedulerConfig) {
+				cfg.Protection = Protection{Rules: []ProtectionRule{
+					{Name: "pinned", Annotation: "example.com/keep"},
+					{Name: "pinned", OwnerKinds: []string{"Cluster"}},
+				}}
+			},
+			expected: `rule "pinned" is defined twice`,
+		},
+		{
+			name: "invalid protection annotation",
+			modify: func(cfg *SchedulerConfig) {
+				cfg.Protection = Protection{Rules: []ProtectionRule{{Name: "pinned", Annotation: "keep me"}}}
+			},
+			expected: `PROTECTION_FILE: rule "pinned": annotation`,
+		},
+		{
+			name:     "relative base url",
+			modify:   func(cfg *SchedulerConfig) { cfg.EmailCfg.BaseURL = "api.notification.canada.ca" },
+			expected: "BASE_URL",
+		},
+		{
+			name:     "missing api key",
+			modify:   func(cfg *SchedulerConfig) { cfg.EmailCfg.APIKey = "" },
+			expected: "API_KEY",
+		},
+		{
+			name:     "missing template id",
+			modify:   func(cfg *SchedulerConfig) { cfg.EmailCfg.EmailTemplateID = "" },
+			expected: "EMAIL_TEMPLATE_ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validSchedulerConfig()
+			tt.modify(&cfg)
+			assert.ErrorContains(t, cfg.Validate(), tt.expected)
+		})
+	}
+
+	t.Run("archive", func(t *testing.T) {
+		cfg := validSchedulerConfig()
+		cfg.Archive = validArchiveConfig()
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		cfg := validSchedulerConfig()
+		cfg.Audit = AuditConfig{Namespace: "das", WebhookURL: "https://audit.example.ca/volume-cleaner"}
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("ignore label is optional", func(t *testing.T) {
+		cfg := validSchedulerConfig()
+		cfg.IgnoreLabel = ""
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("secrets are not required in dry run mode", func(t *testing.T) {
+		cfg := validSchedulerConfig()
+		cfg.DryRun = true
+		cfg.EmailCfg.APIKey = ""
+		cfg.EmailCfg.EmailTemplateID = ""
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		cfg := validSchedulerConfig()
+		cfg.TimeLabel = ""
+		cfg.GracePeriod = 0
+		cfg.EmailCfg.APIKey = ""
+
+		err := cfg.Validate()
+		assert.ErrorContains(t, err, "TIME_LABEL")
+		assert.ErrorContains(t, err, "GRACE_PERIOD")
+		assert.ErrorContains(t, err, "API_KEY")
+	})
+}
@@ -0,0 +1,374 @@
+package utils
+
+import (
+	// standard packages
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// size and checksum of an uploaded archive, reported by the archive job in its termination message
+
+type ArchiveResult struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// returned when the contents of a volume changed between measuring and uploading its archive,
+// or a download does not match the archive that was uploaded
+var errChecksumMismatch = errors.New("archive does not match its checksum")
+
+// writes dir as a gzipped tarball to w
+// the output only depends on the files, so writing the same directory twice gives the same bytes.
+// regular files, directories and symlinks are kept, sockets, devices and pipes are left out
+
+func WriteArchive(w io.Writer, dir string) error {
+	zipped := gzip.NewWriter(w)
+	archive := tar.NewWriter(zipped)
+
+	// WalkDir visits entries in lexical order
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular() && !info.IsDir():
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		// a file growing after its header was written would corrupt the tarball
+		_, err = io.CopyN(archive, file, header.Size)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return zipped.Close()
+}
+
+// measures the archive of dir, returning its size and checksum without storing it
+
+func MeasureArchive(dir string) (ArchiveResult, error) {
+	hash := sha256.New()
+	counter := &countingWriter{w: hash}
+	if err := WriteArchive(counter, dir); err != nil {
+		return ArchiveResult{}, err
+	}
+	return ArchiveResult{Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// uploads the archive of dir to a presigned url
+// s3 does not accept uploads of unknown length, so the archive is measured first and then streamed,
+// the checksum of the streamed bytes has to match the one measured
+
+func UploadArchive(ctx context.Context, client *http.Client, dir string, url string) (ArchiveResult, error) {
+	measured, err := MeasureArchive(dir)
+	if err != nil {
+		return ArchiveResult{}, err
+	}
+
+	reader, writer := io.Pipe()
+	hash := sha256.New()
+	written := make(chan error, 1)
+	go func() {
+		err := WriteArchive(io.MultiWriter(hash, writer), dir)
+		writer.CloseWithError(err)
+		written <- err
+	}()
+	// unblocks the writer when the request ends early
+	defer reader.Close()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, url, reader)
+	if err != nil {
+		return ArchiveResult{}, fmt.Errorf("failed to create upload request: %w", err)
+	}
+	request.ContentLength = measured.Size
+	request.Header.Set("Content-Type", "application/gzip")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return ArchiveResult{}, fmt.Errorf("failed to upload archive: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return ArchiveResult{}, fmt.Errorf("failed to upload archive: %s: %s", response.Status, body)
+	}
+
+	reader.Close()
+	if err := <-written; err != nil {
+		return ArchiveResult{}, fmt.Errorf("failed to upload archive: %w", err)
+	}
+
+	if uploaded := hex.EncodeToString(hash.Sum(nil)); uploaded != measured.SHA256 {
+		return ArchiveResult{}, fmt.Errorf("%w: expected sha256 %s, uploaded %s", errChecksumMismatch, measured.SHA256, uploaded)
+	}
+
+	return measured, nil
+}
+
+// downloads an archive from a presigned url and extracts it into dir
+// the checksum of the download has to match expected, a mismatch is only known once every file was
+// written, the caller discards dir then
+
+func RestoreArchive(ctx context.Context, client *http.Client, url string, dir string, expected string) (ArchiveResult, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ArchiveResult{}, fmt.Errorf("failed to create download request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return ArchiveResult{}, fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return ArchiveResult{}, fmt.Errorf("failed to download archive: %s: %s", response.Status, body)
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{w: hash}
+	if err := ExtractArchive(io.TeeReader(response.Body, counter), dir); err != nil {
+		return ArchiveResult{}, err
+	}
+
+	// the gzip stream may end before the body does
+	if _, err := io.Copy(counter, response.Body); err != nil {
+		return ArchiveResult{}, fmt.Errorf("failed to download archive: %w", err)
+	}
+
+	result := ArchiveResult{Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	if result.SHA256 != expected {
+		return result, fmt.Errorf("%w: expected sha256 %s, downloaded %s", errChecksumMismatch, expected, result.SHA256)
+	}
+	return result, nil
+}
+
+// extracts a gzipped tarball written by WriteArchive into dir
+// nothing is written outside of dir: entries leaving it are refused, files and directories are
+// created through an os.Root and symlinks are only created at the end, never inside one another
+
+func ExtractArchive(r io.Reader, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+	defer root.Close()
+
+	zipped, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	archive := tar.NewReader(zipped)
+
+	var links []*tar.Header
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("failed to extract archive: %q is outside of the volume", header.Name)
+		}
+		mode := fs.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := root.Mkdir(name, mode); err != nil && !errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+		case tar.TypeReg:
+			if err := extractFile(root, name, mode, archive); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+			// no symlink exists yet, so the path stays inside dir
+			os.Chtimes(filepath.Join(dir, name), header.ModTime, header.ModTime)
+		case tar.TypeSymlink:
+			links = append(links, header)
+			continue
+		default:
+			continue
+		}
+
+		if err := restoreOwner(filepath.Join(dir, name), header); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+
+	for _, link := range links {
+		name := filepath.FromSlash(link.Name)
+		if err := symlinkOutsideLinks(dir, name, link.Linkname); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		if err := restoreOwner(filepath.Join(dir, name), link); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// gives an extracted entry back to its owner, e.g. the notebook user, when running as root
+// otherwise the files stay with the user extracting them
+
+func restoreOwner(path string, header *tar.Header) error {
+	err := os.Lchown(path, header.Uid, header.Gid)
+	if errors.Is(err, fs.ErrPermission) {
+		return nil
+	}
+	return err
+}
+
+func extractFile(root *os.Root, name string, mode fs.FileMode, content io.Reader) error {
+	file, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// creates a symlink in dir, refusing one whose parent directories are symlinks themselves
+// since those could point anywhere
+
+func symlinkOutsideLinks(dir string, name string, target string) error {
+	parent := dir
+	for _, part := range strings.Split(filepath.Dir(name), string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		parent = filepath.Join(parent, part)
+		info, err := os.Lstat(parent)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", parent)
+		}
+	}
+	return os.Symlink(target, filepath.Join(dir, name))
+}
+
+// stores a small object, e.g. a manifest, at a presigned url
+
+func PutObject(ctx context.Context, client *http.Client, url string, body []byte, contentType string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("failed to upload object: %s: %s", response.Status, message)
+	}
+	return nil
+}
+
+// reads a small object, e.g. a manifest, from a presigned url
+
+func GetObject(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	defer response.Body.Close()
+
+	// manifests are a few hundred bytes
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download object: %s: %.1024s", response.Status, body)
+	}
+	return body, nil
+}
+
+// counts the bytes written through it
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	// standard packages
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+// returns the entries of a gzipped tarball, file names mapped onto their contents or link targets
+func readArchive(t *testing.T, archive []byte) map[string]string {
+	zipped, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Error reading archive: %v", err)
+	}
+	reader := tar.NewReader(zipped)
+
+	entries := make(map[string]string)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Error reading archive: %v", err)
+		}
+		content, _ := io.ReadAll(reader)
+		entries[header.Name] = string(content) + header.Linkname
+	}
+}
+
+func TestUploadArchive(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "notebooks", "empty"), 0o755); err != nil {
+		t.Fatalf("Error creating directories: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notebooks", "train.ipynb"), []byte(`{"cells": []}`), 0o644); err != nil {
+		t.Fatalf("Error creating file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "results.csv"), []byte("epoch,loss\n1,0.5\n"), 0o644); err != nil {
+		t.Fatalf("Error creating file: %v", err)
+	}
+	if err := os.Symlink("results.csv", filepath.Join(dir, "latest.csv")); err != nil {
+		t.Fatalf("Error creating symlink: %v", err)
+	}
+
+	store := NewFakeObjectStore(testArchiveCfg)
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	cfg := testArchiveCfg
+	cfg.Endpoint = server.URL
+	key := ArchiveKey("team-a", "data", time.Now())
+
+	t.Run("archive is uploaded with its checksum", func(t *testing.T) {
+		signed, err := PresignPut(cfg, key, time.Now(), time.Hour)
+		assert.NoError(t, err)
+
+		result, err := UploadArchive(context.TODO(), server.Client(), dir, signed)
+		assert.NoError(t, err)
+
+		uploaded, _ := store.Object("/volume-archives/" + key)
+		checksum := sha256.Sum256(uploaded)
+		assert.Equal(t, int64(len(uploaded)), result.Size)
+		assert.Equal(t, hex.EncodeToString(checksum[:]), result.SHA256)
+
+		assert.Equal(t, map[string]string{
+			"latest.csv":            "results.csv",
+			"notebooks/":            "",
+			"notebooks/empty/":      "",
+			"notebooks/train.ipynb": `{"cells": []}`,
+			"results.csv":           "epoch,loss\n1,0.5\n",
+		}, readArchive(t, uploaded))
+
+		// the same files always give the same archive
+		measured, err := MeasureArchive(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, result, measured)
+	})
+
+	t.Run("rejected upload", func(t *testing.T) {
+		wrong := cfg
+		wrong.SecretKey = "not the secret"
+		signed, err := PresignPut(wrong, key, time.Now(), time.Hour)
+		assert.NoError(t, err)
+
+		_, err = UploadArchive(context.TODO(), server.Client(), dir, signed)
+		assert.ErrorContains(t, err, "403")
+		assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := MeasureArchive(filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+	})
+}
+
+// writes a gzipped tarball of the given entries, in order
+func buildArchive(t *testing.T, headers ...*tar.Header) []byte {
+	var buffer bytes.Buffer
+	zipped := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(zipped)
+	for _, header := range headers {
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatalf("Error writing archive: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			archive.Write(bytes.Repeat([]byte("x"), int(header.Size)))
+		}
+	}
+	archive.Close()
+	zipped.Close()
+	return buffer.Bytes()
+}
+
+func TestRestoreArchive(t *testing.T) {
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "notebooks"), 0o755); err != nil {
+		t.Fatalf("Error creating directories: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "notebooks", "train.ipynb"), []byte(`{"cells": []}`), 0o600); err != nil {
+		t.Fatalf("Error creating file: %v", err)
+	}
+	if err := os.Symlink("notebooks/train.ipynb", filepath.Join(source, "latest.ipynb")); err != nil {
+		t.Fatalf("Error creating symlink: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := WriteArchive(&archive, source); err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+	checksum := sha256.Sum256(archive.Bytes())
+
+	store := NewFakeObjectStore(testArchiveCfg)
+	store.Put("/volume-archives/team-a/data/20250715T000000Z.tar.gz", archive.Bytes())
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	cfg := testArchiveCfg
+	cfg.Endpoint = server.URL
+	download, err := PresignGet(cfg, "team-a/data/20250715T000000Z.tar.gz", time.Now(), time.Hour)
+	assert.NoError(t, err)
+
+	t.Run("files come back as they were archived", func(t *testing.T) {
+		target := t.TempDir()
+
+		result, err := RestoreArchive(context.TODO(), server.Client(), download, target, hex.EncodeToString(checksum[:]))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(archive.Len()), result.Size)
+
+		content, err := os.ReadFile(filepath.Join(target, "notebooks", "train.ipynb"))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"cells": []}`, string(content))
+
+		info, err := os.Stat(filepath.Join(target, "notebooks", "train.ipynb"))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		link, err := os.Readlink(filepath.Join(target, "latest.ipynb"))
+		assert.NoError(t, err)
+		assert.Equal(t, "notebooks/train.ipynb", link)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		_, err := RestoreArchive(context.TODO(), server.Client(), download, t.TempDir(), "0000")
+		assert.ErrorIs(t, err, errChecksumMismatch)
+	})
+
+	t.Run("missing archive", func(t *testing.T) {
+		missing, _ := PresignGet(cfg, "team-a/data/20250101T000000Z.tar.gz", time.Now(), time.Hour)
+		_, err := RestoreArchive(context.TODO(), server.Client(), missing, t.TempDir(), "0000")
+		assert.ErrorContains(t, err, "404")
+	})
+}
+
+func TestExtractArchive(t *testing.T) {
+	t.Run("entries outside of the volume are refused", func(t *testing.T) {
+		parent := t.TempDir()
+		target := filepath.Join(parent, "data")
+		if err := os.Mkdir(target, 0o755); err != nil {
+			t.Fatalf("Error creating directory: %v", err)
+		}
+
+		archive := buildArchive(t, &tar.Header{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1})
+		assert.ErrorContains(t, ExtractArchive(bytes.NewReader(archive), target), "outside of the volume")
+		assert.NoFileExists(t, filepath.Join(parent, "escaped"))
+	})
+
+	t.Run("files are not written through symlinks", func(t *testing.T) {
+		outside := t.TempDir()
+		target := t.TempDir()
+
+		// symlinks are created last, so the file lands in a directory of its own
+		archive := buildArchive(t,
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "link/", Typeflag: tar.TypeDir, Mode: 0o755},
+			&tar.Header{Name: "link/file", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1},
+		)
+		err := ExtractArchive(bytes.NewReader(archive), target)
+		assert.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(outside, "file"))
+
+		// nor are symlinks created inside other symlinks
+		archive = buildArchive(t,
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "link/nested", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		)
+		assert.ErrorContains(t, ExtractArchive(bytes.NewReader(archive), t.TempDir()), "is a symlink")
+		assert.NoFileExists(t, filepath.Join(outside, "nested"))
+	})
+}
+
+func TestObjects(t *testing.T) {
+	store := NewFakeObjectStore(testArchiveCfg)
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	cfg := testArchiveCfg
+	cfg.Endpoint = server.URL
+	key := ManifestKey("team-a/data/20250715T000000Z.tar.gz")
+	assert.Equal(t, "team-a/data/20250715T000000Z.json", key)
+
+	upload, _ := PresignPut(cfg, key, time.Now(), time.Hour)
+	assert.NoError(t, PutObject(context.TODO(), server.Client(), upload, []byte(`{"name": "data"}`), "application/json"))
+
+	download, _ := PresignGet(cfg, key, time.Now(), time.Hour)
+	object, err := GetObject(context.TODO(), server.Client(), download)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name": "data"}`, string(object))
+
+	// a url signed for an upload cannot be used to read
+	_, err = GetObject(context.TODO(), server.Client(), upload)
+	assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	// standard packages
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// posts an audit entry as json to a webhook, any 2xx response accepts it
+
+func SendAuditEntry(ctx context.Context, client *http.Client, url string, entry structInternal.AuditEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create audit request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send audit entry: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("failed to send audit entry: %s: %s", response.Status, message)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	// standard packages
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// layout used by all day events in ics files (DTSTART;VALUE=DATE:20251225)
+const icsDateLayout = "20060102"
+
+// builds the calendar used to count grace periods from the config values
+// holidays can be provided inline, through an ics file (e.g. a mounted configmap), or both
+
+func LoadCalendar(businessDays bool, holidays string, holidayFile string, timezone string) (structInternal.Calendar, error) {
+	parsedHolidays, holidayErr := ParseHolidays(holidays)
+	location, tzErr := ParseTimezone(timezone)
+
+	cal := structInternal.Calendar{
+		BusinessDays: businessDays,
+		Holidays:     parsedHolidays,
+		Location:     location,
+	}
+
+	if err := errors.Join(holidayErr, tzErr); err != nil {
+		return cal, err
+	}
+
+	if holidayFile == "" {
+		return cal, nil
+	}
+
+	file, err := os.Open(holidayFile)
+	if err != nil {
+		return cal, fmt.Errorf("failed to open holiday file: %w", err)
+	}
+	defer file.Close()
+
+	fromFile, err := ParseICS(file)
+	if err != nil {
+		return cal, fmt.Errorf("failed to parse holiday file: %w", err)
+	}
+
+	for day := range fromFile {
+		cal.Holidays[day] = struct{}{}
+	}
+
+	log.Printf("[INFO] Loaded %d holidays.", len(cal.Holidays))
+
+	return cal, nil
+}
+
+// read a comma separated list of dates (YYYY-MM-DD) and convert to a set of holidays
+// every invalid date is reported
+
+func ParseHolidays(str string) (map[string]struct{}, error) {
+	holidays := make(map[string]struct{})
+	var errs []error
+
+	for _, val := range ParseStrList(str) {
+		if val == "" {
+			continue
+		}
+		day, err := time.Parse(structInternal.DateLayout, val)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse holiday: %w", err))
+			continue
+		}
+		holidays[day.Format(structInternal.DateLayout)] = struct{}{}
+	}
+
+	return holidays, errors.Join(errs...)
+}
+
+// read the events of an ics calendar and convert them to a set of holidays
+// only the date part of DTSTART and DTEND is used, multi day events mark every day
+// from DTSTART up to but not including DTEND
+
+func ParseICS(reader io.Reader) (map[string]struct{}, error) {
+	holidays := make(map[string]struct{})
+
+	// ics lines longer than 75 octets are folded onto lines starting with whitespace
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var start, end time.Time
+	inEvent := false
+
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		// drop parameters such as ;VALUE=DATE
+		name, _, _ = strings.Cut(name, ";")
+
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent = true
+				start, end = time.Time{}, time.Time{}
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			if len(value) < len(icsDateLayout) {
+				continue
+			}
+			day, err := time.Parse(icsDateLayout, value[:len(icsDateLayout)])
+			if err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				start = day
+			} else {
+				end = day
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || !inEvent {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			holidays[start.Format(structInternal.DateLayout)] = struct{}{}
+			for day := start.AddDate(0, 0, 1); day.Before(end); day = day.AddDate(0, 0, 1) {
+				holidays[day.Format(structInternal.DateLayout)] = struct{}{}
+			}
+		}
+	}
+
+	return holidays, nil
+}
+
+// read an IANA timezone name (e.g. America/Toronto), defaults to UTC
+
+func ParseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC, fmt.Errorf("failed to load timezone: %w", err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	// standard packages
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20251225\r\n" +
+	"DTEND;VALUE=DATE:20251227\r\n" +
+	"SUMMARY:Christmas and Boxing\r\n" +
+	"  Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20260101T050000Z\r\n" +
+	"SUMMARY:New Year's Day\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseHolidays(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]struct{}
+	}{
+		{
+			name:     "nothing",
+			input:    "",
+			expected: map[string]struct{}{},
+		},
+		{
+			name:     "single date",
+			input:    "2025-12-25",
+			expected: map[string]struct{}{"2025-12-25": {}},
+		},
+		{
+			name:     "multiple dates with whitespace",
+			input:    " 2025-12-25,   2026-01-01 ",
+			expected: map[string]struct{}{"2025-12-25": {}, "2026-01-01": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseHolidays(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual, "for input: %q", tt.input)
+		})
+	}
+
+	t.Run("invalid dates are all reported", func(t *testing.T) {
+		_, err := ParseHolidays("2025-12-25, christmas, 2025-13-01")
+		assert.ErrorContains(t, err, "christmas")
+		assert.ErrorContains(t, err, "2025-13-01")
+	})
+}
+
+func TestParseICS(t *testing.T) {
+	holidays, err := ParseICS(strings.NewReader(testICS))
+	assert.NoError(t, err)
+
+	// multi day events cover every day up to DTEND, date times only keep the date
+	assert.Equal(t, map[string]struct{}{
+		"2025-12-25": {},
+		"2025-12-26": {},
+		"2026-01-01": {},
+	}, holidays)
+}
+
+func TestLoadCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.ics")
+	if err := os.WriteFile(path, []byte(testICS), 0o600); err != nil {
+		t.Fatalf("Error writing holiday file: %v", err)
+	}
+
+	cal, err := LoadCalendar(true, "2025-07-01", path, "")
+	assert.NoError(t, err)
+
+	assert.True(t, cal.BusinessDays)
+	assert.Equal(t, time.UTC, cal.Location)
+	assert.Len(t, cal.Holidays, 4)
+	assert.False(t, cal.IsBusinessDay(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)))
+	assert.False(t, cal.IsBusinessDay(time.Date(2025, time.December, 26, 12, 0, 0, 0, time.UTC)))
+	assert.True(t, cal.IsBusinessDay(time.Date(2025, time.December, 29, 12, 0, 0, 0, time.UTC)))
+
+	t.Run("missing file and bad timezone", func(t *testing.T) {
+		_, err := LoadCalendar(true, "", filepath.Join(t.TempDir(), "missing.ics"), "")
+		assert.Error(t, err)
+
+		_, err = LoadCalendar(true, "", "", "Mars/Olympus_Mons")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"log"
+
+	// external packages
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// returns the size and storage class of a pvc
+// the claim status is preferred since it reflects resizes, the bound pv is used when the claim does
+// not report them (e.g. while a resize is pending), and the requested size is the last resort
+
+func VolumeDetails(ctx context.Context, kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim) (resource.Quantity, string) {
+	size, hasSize := pvc.Status.Capacity[corev1.ResourceStorage]
+
+	storageClass := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+
+	if (!hasSize || storageClass == "") && pvc.Spec.VolumeName != "" {
+		callCtx, cancel := CallContext(ctx)
+		defer cancel()
+		pv, err := kube.CoreV1().PersistentVolumes().Get(callCtx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			log.Printf("[ERROR] Failed to find PV object %s: %s", pvc.Spec.VolumeName, err)
+		} else {
+			if !hasSize {
+				size, hasSize = pv.Spec.Capacity[corev1.ResourceStorage]
+			}
+			if storageClass == "" {
+				storageClass = pv.Spec.StorageClassName
+			}
+		}
+	}
+
+	if !hasSize {
+		size = pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+
+	return size, storageClass
+}
+
+// formats a number of bytes with binary suffixes, e.g. 1073741824 is "1Gi"
+
+func FormatBytes(bytes int64) string {
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
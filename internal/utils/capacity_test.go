@@ -0,0 +1,92 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// returns a pointer to the given string
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestVolumeDetails(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv1"},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+			StorageClassName: "premium",
+		},
+	}
+	kube := fake.NewClientset(pv)
+
+	tests := []struct {
+		name          string
+		pvc           corev1.PersistentVolumeClaim
+		expectedSize  string
+		expectedClass string
+	}{
+		{
+			name: "claim status",
+			pvc: corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv1", StorageClassName: strPtr("standard")},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+				},
+			},
+			expectedSize:  "10Gi",
+			expectedClass: "standard",
+		},
+		{
+			name: "bound volume",
+			pvc: corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv1"},
+			},
+			expectedSize:  "20Gi",
+			expectedClass: "premium",
+		},
+		{
+			name: "requested size",
+			pvc: corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: strPtr("standard"),
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+					},
+				},
+			},
+			expectedSize:  "5Gi",
+			expectedClass: "standard",
+		},
+		{
+			name: "missing volume",
+			pvc: corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "missing"},
+			},
+			expectedSize:  "0",
+			expectedClass: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, storageClass := VolumeDetails(context.TODO(), kube, tt.pvc)
+			assert.Equal(t, tt.expectedSize, size.String())
+			assert.Equal(t, tt.expectedClass, storageClass)
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0", FormatBytes(0))
+	assert.Equal(t, "512Mi", FormatBytes(512*1024*1024))
+	assert.Equal(t, "15Gi", FormatBytes(15*1024*1024*1024))
+}
@@ -0,0 +1,490 @@
+package utils
+
+import (
+	// standard packages
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	// external packages
+	"sigs.k8s.io/yaml"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// maps the keys of the yaml/json config file onto the env vars they stand in for
+// env vars always win over the file so single values can be overridden per deployment
+
+var configFileKeys = map[string]string{
+	"namespace":            "NAMESPACE",
+	"namespaceSelector":    "NAMESPACE_SELECTOR",
+	"includeNamespaces":    "INCLUDE_NAMESPACES",
+	"excludeNamespaces":    "EXCLUDE_NAMESPACES",
+	"timeLabel":            "TIME_LABEL",
+	"notifLabel":           "NOTIF_LABEL",
+	"stateAnnotation":      "STATE_ANNOTATION",
+	"ignoreLabel":          "IGNORE_LABEL",
+	"timeFormat":           "TIME_FORMAT",
+	"storageClasses":       "STORAGE_CLASSES",
+	"resetRun":             "RESET_RUN",
+	"reconcileInterval":    "RECONCILE_INTERVAL",
+	"usageAnnotation":      "USAGE_ANNOTATION",
+	"healthAddr":           "HEALTH_ADDR",
+	"watchStaleness":       "WATCH_STALENESS",
+	"configReloadInterval": "CONFIG_RELOAD_INTERVAL",
+	"prices":               "PRICES",
+	"currency":             "CURRENCY",
+	"sweepVolumes":         "SWEEP_VOLUMES",
+	"deleteDisks":          "DELETE_DISKS",
+	"concurrency":          "CONCURRENCY",
+	"kubeQPS":              "KUBE_QPS",
+	"emailQPS":             "EMAIL_QPS",
+	"runTimeout":           "RUN_TIMEOUT",
+	"archiveEndpoint":      "ARCHIVE_ENDPOINT",
+	"archiveBucket":        "ARCHIVE_BUCKET",
+	"archiveRegion":        "ARCHIVE_REGION",
+	"archiveAccessKey":     "ARCHIVE_ACCESS_KEY",
+	"archiveSecretKey":     "ARCHIVE_SECRET_KEY",
+	"archiveImage":         "ARCHIVE_IMAGE",
+	"archiveClasses":       "ARCHIVE_STORAGE_CLASSES",
+	"archiveTimeout":       "ARCHIVE_TIMEOUT",
+	"auditNamespace":       "AUDIT_NAMESPACE",
+	"auditWebhookURL":      "AUDIT_WEBHOOK_URL",
+	"webhookAddr":          "WEBHOOK_ADDR",
+	"webhookCertFile":      "WEBHOOK_CERT_FILE",
+	"webhookKeyFile":       "WEBHOOK_KEY_FILE",
+	"protectionFile":       "PROTECTION_FILE",
+	"gracePeriod":          "GRACE_PERIOD",
+	"dryRun":               "DRY_RUN",
+	"notifTimes":           "NOTIF_TIMES",
+	"businessDays":         "BUSINESS_DAYS",
+	"holidays":             "HOLIDAYS",
+	"holidayFile":          "HOLIDAY_FILE",
+	"timezone":             "TIMEZONE",
+	"schedule":             "SCHEDULE",
+	"baseURL":              "BASE_URL",
+	"endpoint":             "ENDPOINT",
+	"emailTemplateID":      "EMAIL_TEMPLATE_ID",
+	"apiKey":               "API_KEY",
+}
+
+// address of the controller health endpoints when HEALTH_ADDR is not set
+const DefaultHealthAddr = ":8080"
+
+// how often the controller checks CONFIG_FILE and PROTECTION_FILE when CONFIG_RELOAD_INTERVAL is not set
+const DefaultConfigReloadInterval = 30 * time.Second
+
+// defaults of the archive, used when ARCHIVE_REGION and ARCHIVE_TIMEOUT are not set
+const (
+	DefaultArchiveRegion  = "us-east-1"
+	DefaultArchiveTimeout = time.Hour
+)
+
+// where config values are read from: env vars first, then the config file
+
+type ConfigSource struct {
+	File map[string]string
+	Env  func(key string) (string, bool)
+}
+
+// returns a source that only reads env vars
+func EnvSource() ConfigSource {
+	return ConfigSource{File: map[string]string{}, Env: os.LookupEnv}
+}
+
+// returns the value for an env var name
+func (src ConfigSource) Get(key string) string {
+	value, _ := src.Lookup(key)
+	return value
+}
+
+// returns the value for an env var name and whether it was set at all
+// used where an empty value means something different than the default
+func (src ConfigSource) Lookup(key string) (string, bool) {
+	if src.Env != nil {
+		if value, ok := src.Env(key); ok {
+			return value, true
+		}
+	}
+	value, ok := src.File[key]
+	return value, ok
+}
+
+// returns true for "true" and "1"
+func (src ConfigSource) Bool(key string) bool {
+	value := src.Get(key)
+	return value == "true" || value == "1"
+}
+
+// reads a yaml or json config file (json is valid yaml) and returns its values keyed by env var name
+// lists are joined with commas so they go through the same parsers as env vars
+// a missing file has no values, e.g. an optional configmap that was not created
+
+func LoadConfigFile(path string) (map[string]string, error) {
+	content, err := readOptionalFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return ParseConfigFile(content)
+}
+
+// reads a file that may not exist, a missing file is read as empty
+
+func readOptionalFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return content, err
+}
+
+func ParseConfigFile(content []byte) (map[string]string, error) {
+	raw := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	values := make(map[string]string)
+	var errs []error
+
+	for key, value := range raw {
+		envKey, ok := configFileKeys[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file: unknown key %q", key))
+			continue
+		}
+
+		switch typed := value.(type) {
+		case nil:
+			values[envKey] = ""
+		case []interface{}:
+			items := make([]string, 0, len(typed))
+			for _, item := range typed {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[envKey] = strings.Join(items, ",")
+		case map[string]interface{}:
+			errs = append(errs, fmt.Errorf("config file: %q must be a value or a list", key))
+		default:
+			values[envKey] = fmt.Sprint(typed)
+		}
+	}
+
+	return values, errors.Join(errs...)
+}
+
+// builds the namespace scope, kubeflow profiles are selected unless NAMESPACE_SELECTOR is set
+// an empty NAMESPACE_SELECTOR selects every namespace
+
+func LoadNamespaceScope(src ConfigSource) structInternal.NamespaceScope {
+	selector, ok := src.Lookup("NAMESPACE_SELECTOR")
+	if !ok {
+		selector = structInternal.DefaultNamespaceSelector
+	}
+
+	return structInternal.NamespaceScope{
+		Selector: selector,
+		Include:  ParseStrList(src.Get("INCLUDE_NAMESPACES")),
+		Exclude:  ParseStrList(src.Get("EXCLUDE_NAMESPACES")),
+	}
+}
+
+// returns STATE_ANNOTATION, or the default annotation when it is not set
+
+func LoadStateAnnotation(src ConfigSource) string {
+	if annotation := src.Get("STATE_ANNOTATION"); annotation != "" {
+		return annotation
+	}
+	return structInternal.DefaultStateAnnotation
+}
+
+// builds the controller config, returning every parsing and validation error
+
+func LoadControllerConfig(src ConfigSource) (structInternal.ControllerConfig, error) {
+	reconcileInterval, reconcileErr := ParseInterval(src.Get("RECONCILE_INTERVAL"))
+
+	watchStaleness, stalenessErr := ParseInterval(src.Get("WATCH_STALENESS"))
+	if watchStaleness == 0 && stalenessErr == nil {
+		watchStaleness = structInternal.DefaultWatchStaleness
+	}
+
+	reloadInterval, reloadErr := ParseInterval(src.Get("CONFIG_RELOAD_INTERVAL"))
+	if reloadInterval == 0 && reloadErr == nil {
+		reloadInterval = DefaultConfigReloadInterval
+	}
+	if reloadErr != nil {
+		reloadErr = fmt.Errorf("CONFIG_RELOAD_INTERVAL: %w", reloadErr)
+	}
+
+	// served by default, an empty value turns the endpoints off
+	healthAddr, ok := src.Lookup("HEALTH_ADDR")
+	if !ok {
+		healthAddr = DefaultHealthAddr
+	}
+
+	protection, protectionErr := LoadProtection(src.Get("PROTECTION_FILE"))
+
+	cfg := structInternal.ControllerConfig{
+		Namespace:            src.Get("NAMESPACE"),
+		Scope:                LoadNamespaceScope(src),
+		TimeLabel:            src.Get("TIME_LABEL"),
+		NotifLabel:           src.Get("NOTIF_LABEL"),
+		StateAnnotation:      LoadStateAnnotation(src),
+		TimeFormat:           src.Get("TIME_FORMAT"),
+		StorageClasses:       ParseStrList(src.Get("STORAGE_CLASSES")),
+		ResetRun:             src.Bool("RESET_RUN"),
+		ReconcileInterval:    reconcileInterval,
+		UsageAnnotation:      src.Get("USAGE_ANNOTATION"),
+		HealthAddr:           healthAddr,
+		WatchStaleness:       watchStaleness,
+		ConfigReloadInterval: reloadInterval,
+		Audit:                LoadAuditConfig(src),
+		Webhook: structInternal.WebhookConfig{
+			Addr:     src.Get("WEBHOOK_ADDR"),
+			CertFile: src.Get("WEBHOOK_CERT_FILE"),
+			KeyFile:  src.Get("WEBHOOK_KEY_FILE"),
+		},
+		Protection: protection,
+		Clock:      structInternal.RealClock{},
+	}
+
+	return cfg, errors.Join(reconcileErr, stalenessErr, reloadErr, protectionErr, cfg.Validate())
+}
+
+// builds the scheduler config, returning every parsing and validation error
+
+func LoadSchedulerConfig(src ConfigSource) (structInternal.SchedulerConfig, error) {
+	// Initialize an EmailConfig struct
+	emailCfg := structInternal.EmailConfig{
+		BaseURL:         src.Get("BASE_URL"),
+		Endpoint:        src.Get("ENDPOINT"),
+		EmailTemplateID: src.Get("EMAIL_TEMPLATE_ID"),
+		APIKey:          src.Get("API_KEY"),
+	}
+
+	// parse every value first so that all problems can be reported together
+	gracePeriod, graceErr := ParseGracePeriod(src.Get("GRACE_PERIOD"))
+	notifTimes, notifErr := ParseNotifTimes(src.Get("NOTIF_TIMES"))
+	calendar, calendarErr := LoadCalendar(
+		src.Bool("BUSINESS_DAYS"),
+		src.Get("HOLIDAYS"),
+		src.Get("HOLIDAY_FILE"),
+		src.Get("TIMEZONE"),
+	)
+	schedule, scheduleErr := ParseSchedule(src.Get("SCHEDULE"))
+	prices, pricesErr := LoadPriceTable(src)
+	concurrency, concurrencyErr := ParseConcurrency(src.Get("CONCURRENCY"))
+	kubeQPS, kubeQPSErr := ParseRate(src.Get("KUBE_QPS"))
+	emailQPS, emailQPSErr := ParseRate(src.Get("EMAIL_QPS"))
+	runTimeout, runTimeoutErr := ParseInterval(src.Get("RUN_TIMEOUT"))
+	archiveCfg, archiveErr := LoadArchiveConfig(src)
+	protection, protectionErr := LoadProtection(src.Get("PROTECTION_FILE"))
+
+	// Scheduler struct which composes an EmailConfig
+	cfg := structInternal.SchedulerConfig{
+		Namespace:       src.Get("NAMESPACE"),
+		Scope:           LoadNamespaceScope(src),
+		TimeLabel:       src.Get("TIME_LABEL"),
+		NotifLabel:      src.Get("NOTIF_LABEL"),
+		StateAnnotation: LoadStateAnnotation(src),
+		IgnoreLabel:     src.Get("IGNORE_LABEL"),
+		TimeFormat:      src.Get("TIME_FORMAT"),
+		GracePeriod:     gracePeriod,
+		DryRun:          src.Bool("DRY_RUN"),
+		NotifTimes:      notifTimes,
+		UsageAnnotation: src.Get("USAGE_ANNOTATION"),
+		Prices:          prices,
+		SweepVolumes:    src.Bool("SWEEP_VOLUMES"),
+		DeleteDisks:     src.Bool("DELETE_DISKS"),
+		Concurrency:     concurrency,
+		KubeQPS:         kubeQPS,
+		EmailQPS:        emailQPS,
+		RunTimeout:      runTimeout,
+		Archive:         archiveCfg,
+		Audit:           LoadAuditConfig(src),
+		Protection:      protection,
+		EmailCfg:        emailCfg,
+		Clock:           structInternal.RealClock{},
+		Calendar:        calendar,
+		Schedule:        schedule,
+	}
+
+	// values that failed to parse are left at zero, their validation would only repeat the parse error
+	var unparsed []string
+	if graceErr != nil {
+		// the notification times are checked against the grace period
+		unparsed = append(unparsed, "GRACE_PERIOD", "NOTIF_TIMES")
+	}
+	if concurrencyErr != nil {
+		unparsed = append(unparsed, "CONCURRENCY")
+	}
+	if archiveErr != nil {
+		unparsed = append(unparsed, "ARCHIVE_TIMEOUT")
+	}
+
+	// parse errors are reported first, validation of the remaining fields follows
+	return cfg, errors.Join(graceErr, notifErr, calendarErr, scheduleErr, pricesErr,
+		concurrencyErr, kubeQPSErr, emailQPSErr, runTimeoutErr, archiveErr, protectionErr,
+		withoutKeys(cfg.Validate(), unparsed...))
+}
+
+// drops the validation errors about the given keys, validation errors start with the key they are about
+
+func withoutKeys(err error, keys ...string) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(keys) == 0 {
+		return err
+	}
+
+	var kept []error
+	for _, e := range joined.Unwrap() {
+		// nested configs (e.g. the archive config) join their own errors
+		if _, nested := e.(interface{ Unwrap() []error }); nested {
+			kept = append(kept, withoutKeys(e, keys...))
+		} else if !slices.ContainsFunc(keys, func(key string) bool { return strings.HasPrefix(e.Error(), key+":") }) {
+			kept = append(kept, e)
+		}
+	}
+	return errors.Join(kept...)
+}
+
+// builds the archive config, the keys are expected to come from a secret
+
+func LoadArchiveConfig(src ConfigSource) (structInternal.ArchiveConfig, error) {
+	timeout, err := ParseInterval(src.Get("ARCHIVE_TIMEOUT"))
+	if timeout == 0 && err == nil {
+		timeout = DefaultArchiveTimeout
+	}
+
+	region := src.Get("ARCHIVE_REGION")
+	if region == "" {
+		region = DefaultArchiveRegion
+	}
+
+	cfg := structInternal.ArchiveConfig{
+		Endpoint:       src.Get("ARCHIVE_ENDPOINT"),
+		Bucket:         src.Get("ARCHIVE_BUCKET"),
+		Region:         region,
+		AccessKey:      src.Get("ARCHIVE_ACCESS_KEY"),
+		SecretKey:      src.Get("ARCHIVE_SECRET_KEY"),
+		Image:          src.Get("ARCHIVE_IMAGE"),
+		StorageClasses: ParseStrList(src.Get("ARCHIVE_STORAGE_CLASSES")),
+		Timeout:        timeout,
+	}
+
+	if err != nil {
+		err = fmt.Errorf("ARCHIVE_TIMEOUT: %w", err)
+	}
+	return cfg, err
+}
+
+// builds the audit config, shared by the controller, the scheduler and the cli
+
+func LoadAuditConfig(src ConfigSource) structInternal.AuditConfig {
+	return structInternal.AuditConfig{
+		Namespace:  src.Get("AUDIT_NAMESPACE"),
+		WebhookURL: src.Get("AUDIT_WEBHOOK_URL"),
+	}
+}
+
+// merges a reloaded controller config into the running one
+// label keys, the state annotation, the time format and the watched namespace cannot change while running
+// (pvcs labelled under the old values would be orphaned), those changes are reported and skipped
+
+func ReloadControllerConfig(current structInternal.ControllerConfig, next structInternal.ControllerConfig) (structInternal.ControllerConfig, []string) {
+	var skipped []string
+
+	if next.Namespace != current.Namespace {
+		skipped = append(skipped, "NAMESPACE")
+	}
+	if next.TimeLabel != current.TimeLabel {
+		skipped = append(skipped, "TIME_LABEL")
+	}
+	if next.NotifLabel != current.NotifLabel {
+		skipped = append(skipped, "NOTIF_LABEL")
+	}
+	if next.StateAnnotation != current.StateAnnotation {
+		skipped = append(skipped, "STATE_ANNOTATION")
+	}
+	if next.TimeFormat != current.TimeFormat {
+		skipped = append(skipped, "TIME_FORMAT")
+	}
+	// the usage watchers are only started when tracking is enabled at startup
+	if next.UsageAnnotation != current.UsageAnnotation {
+		skipped = append(skipped, "USAGE_ANNOTATION")
+	}
+	if next.HealthAddr != current.HealthAddr {
+		skipped = append(skipped, "HEALTH_ADDR")
+	}
+	// the files are watched with the interval read at startup
+	if next.ConfigReloadInterval != current.ConfigReloadInterval {
+		skipped = append(skipped, "CONFIG_RELOAD_INTERVAL")
+	}
+	if next.Webhook.Addr != current.Webhook.Addr {
+		skipped = append(skipped, "WEBHOOK_ADDR")
+	}
+	// the certificate itself is read again whenever its files change
+	if next.Webhook.CertFile != current.Webhook.CertFile {
+		skipped = append(skipped, "WEBHOOK_CERT_FILE")
+	}
+	if next.Webhook.KeyFile != current.Webhook.KeyFile {
+		skipped = append(skipped, "WEBHOOK_KEY_FILE")
+	}
+
+	merged := current
+	merged.StorageClasses = slices.Clone(next.StorageClasses)
+	merged.ReconcileInterval = next.ReconcileInterval
+	// read by the readiness probe on every request
+	merged.WatchStaleness = next.WatchStaleness
+	// the watchers and the reconciliation check the scope on every event and pass
+	merged.Scope = next.Scope
+	// read on every label change
+	merged.Audit = next.Audit
+	merged.Protection = next.Protection
+
+	sort.Strings(skipped)
+	return merged, skipped
+}
+
+// polls the config file and calls onChange whenever its content changes
+// configmap volumes are updated through a symlink swap, so comparing content is more
+// reliable than watching modification times. blocks until the context is cancelled
+
+func WatchConfigFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	// creating or removing the file is a change like any other
+	previous, err := readOptionalFile(path)
+	if err != nil {
+		log.Printf("[ERROR] Failed to read config file %s: %s", path, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			content, err := readOptionalFile(path)
+			if err != nil {
+				log.Printf("[ERROR] Failed to read config file %s: %s", path, err)
+				continue
+			}
+			if bytes.Equal(content, previous) {
+				continue
+			}
+			previous = content
+
+			log.Printf("[INFO] Config file %s changed.", path)
+			onChange()
+		}
+	}
+}
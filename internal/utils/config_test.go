@@ -0,0 +1,401 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+const testConfigFile = `
+namespace: anray-liu
+excludeNamespaces: [kube-*, kubeflow]
+timeLabel: volume-cleaner/unattached-time
+notifLabel: volume-cleaner/notification-count
+stateAnnotation: volume-cleaner/state
+ignoreLabel: volume-cleaner/ignore
+timeFormat: 2006-01-02_15-04-05Z
+storageClasses: [default, standard]
+reconcileInterval: 6h
+usageAnnotation: volume-cleaner/last-mounted
+prices: standard=0.05, premium=0.15
+currency: CAD
+sweepVolumes: true
+concurrency: 8
+emailQPS: 2.5
+runTimeout: 45m
+gracePeriod: 180
+dryRun: true
+notifTimes:
+  - 1
+  - 7
+  - 30
+baseURL: https://api.notification.canada.ca
+endpoint: /v2/notifications/email
+`
+
+// returns a lookup function over a fixed set of env vars
+func fakeEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		values, err := ParseConfigFile([]byte(testConfigFile))
+		assert.NoError(t, err)
+
+		assert.Equal(t, "anray-liu", values["NAMESPACE"])
+		assert.Equal(t, "default,standard", values["STORAGE_CLASSES"])
+		assert.Equal(t, "180", values["GRACE_PERIOD"])
+		assert.Equal(t, "true", values["DRY_RUN"])
+		assert.Equal(t, "1,7,30", values["NOTIF_TIMES"])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		values, err := ParseConfigFile([]byte(`{"gracePeriod": 30, "storageClasses": ["default"], "namespace": null}`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"GRACE_PERIOD": "30", "STORAGE_CLASSES": "default", "NAMESPACE": ""}, values)
+	})
+
+	t.Run("unknown and nested keys are reported", func(t *testing.T) {
+		_, err := ParseConfigFile([]byte("gracePeriodDays: 30\nemail:\n  apiKey: secret\n"))
+		assert.ErrorContains(t, err, "gracePeriodDays")
+		assert.ErrorContains(t, err, "email")
+	})
+
+	t.Run("invalid syntax", func(t *testing.T) {
+		_, err := ParseConfigFile([]byte("gracePeriod: [30"))
+		assert.Error(t, err)
+	})
+
+	t.Run("a missing file has no values", func(t *testing.T) {
+		// e.g. the optional configmap was not created
+		values, err := LoadConfigFile(filepath.Join(t.TempDir(), "config.yaml"))
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+}
+
+func TestConfigSource(t *testing.T) {
+	src := ConfigSource{
+		File: map[string]string{"NAMESPACE": "from-file", "TIME_LABEL": "from-file", "DRY_RUN": "1"},
+		Env:  fakeEnv(map[string]string{"NAMESPACE": "from-env", "TIME_FORMAT": ""}),
+	}
+
+	// env vars override the file, even when empty
+	assert.Equal(t, "from-env", src.Get("NAMESPACE"))
+	assert.Equal(t, "from-file", src.Get("TIME_LABEL"))
+	assert.Equal(t, "", src.Get("TIME_FORMAT"))
+	assert.Equal(t, "", src.Get("GRACE_PERIOD"))
+	assert.True(t, src.Bool("DRY_RUN"))
+	assert.False(t, src.Bool("RESET_RUN"))
+
+	// lookup tells unset values apart from empty ones
+	_, ok := src.Lookup("TIME_FORMAT")
+	assert.True(t, ok)
+	_, ok = src.Lookup("GRACE_PERIOD")
+	assert.False(t, ok)
+}
+
+func TestLoadConfig(t *testing.T) {
+	fileValues, err := ParseConfigFile([]byte(testConfigFile))
+	if err != nil {
+		t.Fatalf("Error parsing config file: %v", err)
+	}
+
+	t.Run("controller config from file with env override", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{"STORAGE_CLASSES": "premium"})}
+
+		cfg, err := LoadControllerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, "anray-liu", cfg.Namespace)
+		assert.Equal(t, "volume-cleaner/unattached-time", cfg.TimeLabel)
+		assert.Equal(t, []string{"premium"}, cfg.StorageClasses)
+		assert.Equal(t, 6*time.Hour, cfg.ReconcileInterval)
+		assert.Equal(t, "volume-cleaner/last-mounted", cfg.UsageAnnotation)
+		assert.Equal(t, structInternal.NamespaceScope{
+			Selector: structInternal.DefaultNamespaceSelector,
+			Include:  []string{},
+			Exclude:  []string{"kube-*", "kubeflow"},
+		}, cfg.Scope)
+		assert.Equal(t, DefaultHealthAddr, cfg.HealthAddr)
+		assert.Equal(t, structInternal.DefaultWatchStaleness, cfg.WatchStaleness)
+		assert.Equal(t, DefaultConfigReloadInterval, cfg.ConfigReloadInterval)
+	})
+
+	t.Run("state annotation defaults for configs of earlier versions", func(t *testing.T) {
+		cfg, err := LoadControllerConfig(ConfigSource{Env: fakeEnv(map[string]string{
+			"TIME_LABEL":  "volume-cleaner/unattached-time",
+			"NOTIF_LABEL": "volume-cleaner/notification-count",
+			"TIME_FORMAT": "2006-01-02_15-04-05Z",
+		})})
+		assert.NoError(t, err)
+		assert.Equal(t, structInternal.DefaultStateAnnotation, cfg.StateAnnotation)
+
+		_, err = LoadControllerConfig(ConfigSource{Env: fakeEnv(map[string]string{"STATE_ANNOTATION": "volume cleaner state"})})
+		assert.ErrorContains(t, err, "STATE_ANNOTATION")
+	})
+
+	t.Run("reload interval is read from the file", func(t *testing.T) {
+		fileValues, err := ParseConfigFile([]byte("configReloadInterval: 1m\n"))
+		assert.NoError(t, err)
+
+		cfg, err := LoadControllerConfig(ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{
+			"TIME_LABEL":       "volume-cleaner/unattached-time",
+			"NOTIF_LABEL":      "volume-cleaner/notification-count",
+			"STATE_ANNOTATION": "volume-cleaner/state",
+			"TIME_FORMAT":      "2006-01-02_15-04-05Z",
+		})})
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, cfg.ConfigReloadInterval)
+
+		_, err = LoadControllerConfig(ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{"CONFIG_RELOAD_INTERVAL": "-1s"})})
+		assert.ErrorContains(t, err, "CONFIG_RELOAD_INTERVAL: must be positive")
+
+		_, err = LoadControllerConfig(ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{"CONFIG_RELOAD_INTERVAL": "soon"})})
+		assert.ErrorContains(t, err, "CONFIG_RELOAD_INTERVAL: failed to parse")
+	})
+
+	t.Run("empty health address turns the endpoints off", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{
+			"HEALTH_ADDR":     "",
+			"WATCH_STALENESS": "5m",
+		})}
+
+		cfg, err := LoadControllerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, "", cfg.HealthAddr)
+		assert.Equal(t, 5*time.Minute, cfg.WatchStaleness)
+	})
+
+	t.Run("webhook needs a certificate", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{"WEBHOOK_ADDR": ":8443"})}
+
+		_, err := LoadControllerConfig(src)
+		assert.ErrorContains(t, err, "WEBHOOK_CERT_FILE")
+		assert.ErrorContains(t, err, "WEBHOOK_KEY_FILE")
+
+		src.Env = fakeEnv(map[string]string{
+			"WEBHOOK_ADDR":      ":8443",
+			"WEBHOOK_CERT_FILE": "/etc/volume-cleaner/tls/tls.crt",
+			"WEBHOOK_KEY_FILE":  "/etc/volume-cleaner/tls/tls.key",
+		})
+		cfg, err := LoadControllerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, ":8443", cfg.Webhook.Addr)
+		assert.Equal(t, "/etc/volume-cleaner/tls/tls.key", cfg.Webhook.KeyFile)
+	})
+
+	t.Run("empty namespace selector selects every namespace", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{
+			"NAMESPACE_SELECTOR": "",
+			"INCLUDE_NAMESPACES": "team-*, shared",
+		})}
+
+		cfg, err := LoadSchedulerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, "", cfg.Scope.Selector)
+		assert.Equal(t, []string{"team-*", "shared"}, cfg.Scope.Include)
+	})
+
+	t.Run("scheduler config from file", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{})}
+
+		cfg, err := LoadSchedulerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, 180, cfg.GracePeriod)
+		assert.Equal(t, []int{30, 7, 1}, cfg.NotifTimes)
+		assert.True(t, cfg.DryRun)
+		assert.Equal(t, "volume-cleaner/last-mounted", cfg.UsageAnnotation)
+		assert.True(t, cfg.SweepVolumes)
+		assert.False(t, cfg.DeleteDisks)
+		assert.Equal(t, 8, cfg.Concurrency)
+		assert.Equal(t, 0.0, cfg.KubeQPS)
+		assert.Equal(t, 2.5, cfg.EmailQPS)
+		assert.Equal(t, 45*time.Minute, cfg.RunTimeout)
+		assert.Equal(t, structInternal.PriceTable{Prices: map[string]float64{"standard": 0.05, "premium": 0.15}, Currency: "CAD"}, cfg.Prices)
+		assert.Equal(t, "https://api.notification.canada.ca", cfg.EmailCfg.BaseURL)
+		assert.False(t, cfg.Archive.Enabled())
+		assert.Equal(t, structInternal.AuditConfig{}, cfg.Audit)
+	})
+
+	t.Run("audit sinks", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{
+			"AUDIT_NAMESPACE":   "das",
+			"AUDIT_WEBHOOK_URL": "http://audit.das:8080",
+		})}
+
+		cfg, err := LoadSchedulerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, structInternal.AuditConfig{Namespace: "das", WebhookURL: "http://audit.das:8080"}, cfg.Audit)
+	})
+
+	t.Run("archive with keys from env vars", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{
+			"ARCHIVE_ENDPOINT":        "http://minio.das:9000",
+			"ARCHIVE_BUCKET":          "volume-archives",
+			"ARCHIVE_ACCESS_KEY":      "access",
+			"ARCHIVE_SECRET_KEY":      "secret",
+			"ARCHIVE_IMAGE":           "volume-cleaner-scheduler:latest",
+			"ARCHIVE_STORAGE_CLASSES": "standard",
+		})}
+
+		cfg, err := LoadSchedulerConfig(src)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultArchiveRegion, cfg.Archive.Region)
+		assert.Equal(t, DefaultArchiveTimeout, cfg.Archive.Timeout)
+		assert.True(t, cfg.Archive.Archives("standard"))
+		assert.False(t, cfg.Archive.Archives("premium"))
+	})
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		src := ConfigSource{File: fileValues, Env: fakeEnv(map[string]string{
+			"GRACE_PERIOD": "soon",
+			"SCHEDULE":     "daily",
+			"PRICES":       "standard",
+			"CONCURRENCY":  "0",
+			"RUN_TIMEOUT":  "-1h",
+			"TIME_LABEL":   "",
+
+			"ARCHIVE_ENDPOINT": "minio.das:9000",
+			"ARCHIVE_TIMEOUT":  "a while",
+		})}
+
+		_, err := LoadSchedulerConfig(src)
+		assert.ErrorContains(t, err, "grace period")
+		assert.ErrorContains(t, err, "schedule")
+		assert.ErrorContains(t, err, "price")
+		assert.ErrorContains(t, err, "concurrency")
+		assert.ErrorContains(t, err, "RUN_TIMEOUT")
+		assert.ErrorContains(t, err, "TIME_LABEL")
+		assert.ErrorContains(t, err, "ARCHIVE_ENDPOINT")
+		assert.ErrorContains(t, err, "ARCHIVE_BUCKET")
+		assert.ErrorContains(t, err, "ARCHIVE_TIMEOUT")
+
+		// the values that failed to parse are not validated again
+		assert.NotContains(t, err.Error(), "GRACE_PERIOD:")
+		assert.NotContains(t, err.Error(), "NOTIF_TIMES:")
+		assert.NotContains(t, err.Error(), "CONCURRENCY:")
+		assert.NotContains(t, err.Error(), "ARCHIVE_TIMEOUT: must be positive")
+	})
+}
+
+func TestReloadControllerConfig(t *testing.T) {
+	current := structInternal.ControllerConfig{
+		Namespace:       "anray-liu",
+		TimeLabel:       "volume-cleaner/unattached-time",
+		NotifLabel:      "volume-cleaner/notification-count",
+		StateAnnotation: "volume-cleaner/state",
+		TimeFormat:      "2006-01-02_15-04-05Z",
+		StorageClasses:  []string{"default"},
+		Clock:           structInternal.RealClock{},
+	}
+
+	t.Run("storage classes are applied", func(t *testing.T) {
+		next := current
+		next.StorageClasses = []string{"default", "standard"}
+
+		merged, skipped := ReloadControllerConfig(current, next)
+		assert.Empty(t, skipped)
+		assert.Equal(t, []string{"default", "standard"}, merged.StorageClasses)
+	})
+
+	t.Run("namespace scope is applied", func(t *testing.T) {
+		next := current
+		next.Scope = structInternal.NamespaceScope{Exclude: []string{"kube-*"}}
+
+		merged, skipped := ReloadControllerConfig(current, next)
+		assert.Empty(t, skipped)
+		assert.Equal(t, next.Scope, merged.Scope)
+	})
+
+	t.Run("reconcile interval is applied", func(t *testing.T) {
+		next := current
+		next.ReconcileInterval = time.Hour
+
+		merged, skipped := ReloadControllerConfig(current, next)
+		assert.Empty(t, skipped)
+		assert.Equal(t, time.Hour, merged.ReconcileInterval)
+	})
+
+	t.Run("watch staleness is applied, the health address is kept", func(t *testing.T) {
+		next := current
+		next.WatchStaleness = 5 * time.Minute
+		next.HealthAddr = ":9090"
+
+		merged, skipped := ReloadControllerConfig(current, next)
+		assert.Equal(t, []string{"HEALTH_ADDR"}, skipped)
+		assert.Equal(t, 5*time.Minute, merged.WatchStaleness)
+		assert.Equal(t, current.HealthAddr, merged.HealthAddr)
+	})
+
+	t.Run("webhook is kept", func(t *testing.T) {
+		next := current
+		next.Webhook = structInternal.WebhookConfig{Addr: ":8443", CertFile: "/tls/tls.crt", KeyFile: "/tls/tls.key"}
+
+		merged, skipped := ReloadControllerConfig(current, next)
+		assert.Equal(t, []string{"WEBHOOK_ADDR", "WEBHOOK_CERT_FILE", "WEBHOOK_KEY_FILE"}, skipped)
+		assert.Equal(t, current.Webhook, merged.Webhook)
+	})
+
+	t.Run("labels and namespace are kept", func(t *testing.T) {
+		next := current
+		next.Namespace = "other"
+		next.TimeLabel = "other/time"
+		next.NotifLabel = "other/count"
+		next.StateAnnotation = "other/state"
+		next.TimeFormat = "20060102150405"
+		next.StorageClasses = nil
+
+		merged, skipped := ReloadControllerConfig(current, next)
+		assert.Equal(t, []string{"NAMESPACE", "NOTIF_LABEL", "STATE_ANNOTATION", "TIME_FORMAT", "TIME_LABEL"}, skipped)
+		assert.Equal(t, current.Namespace, merged.Namespace)
+		assert.Equal(t, current.TimeLabel, merged.TimeLabel)
+		assert.Equal(t, current.NotifLabel, merged.NotifLabel)
+		assert.Equal(t, current.StateAnnotation, merged.StateAnnotation)
+		assert.Equal(t, current.TimeFormat, merged.TimeFormat)
+		assert.Empty(t, merged.StorageClasses)
+	})
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("storageClasses: [default]\n"), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 10)
+	go WatchConfigFile(ctx, path, 10*time.Millisecond, func() { changed <- struct{}{} })
+
+	// unchanged content does not trigger a reload
+	select {
+	case <-changed:
+		t.Fatal("reload triggered without a change")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("storageClasses: [default, standard]\n"), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reload not triggered after a change")
+	}
+}
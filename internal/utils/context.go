@@ -0,0 +1,16 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"time"
+)
+
+// longest a single kubernetes api call may take before it is abandoned
+const CallTimeout = 30 * time.Second
+
+// returns the context of a single api call, cancelled with ctx or after CallTimeout
+
+func CallContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, CallTimeout)
+}
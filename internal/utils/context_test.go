@@ -0,0 +1,31 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallContext(t *testing.T) {
+	t.Run("each call gets its own deadline", func(t *testing.T) {
+		callCtx, cancel := CallContext(context.Background())
+		defer cancel()
+
+		deadline, ok := callCtx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(CallTimeout), deadline, time.Second)
+	})
+
+	t.Run("cancelled with the parent", func(t *testing.T) {
+		parent, stop := context.WithCancel(context.Background())
+		callCtx, cancel := CallContext(parent)
+		defer cancel()
+
+		stop()
+		assert.ErrorIs(t, callCtx.Err(), context.Canceled)
+	})
+}
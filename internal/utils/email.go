@@ -6,13 +6,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	// external packages
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -22,7 +23,7 @@ import (
 
 // given a collection of configs, this function makes a post request to an third party email service and sends an email to a user
 
-func SendNotif(client *http.Client, conf structInternal.EmailConfig, email string, personal structInternal.Personalisation) error {
+func SendNotif(ctx context.Context, client *http.Client, conf structInternal.EmailConfig, email string, personal structInternal.Personalisation) error {
 
 	url := conf.BaseURL + conf.Endpoint
 
@@ -39,7 +40,7 @@ func SendNotif(client *http.Client, conf structInternal.EmailConfig, email strin
 	}
 
 	// Create the request and add the required headers
-	request, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
 	request.Header.Add("Authorization", "ApiKey-v1 "+conf.APIKey)
 	request.Header.Add("Content-Type", "application/json")
 
@@ -71,32 +72,107 @@ func SendNotif(client *http.Client, conf structInternal.EmailConfig, email strin
 	return errors.New(response.Status)
 }
 
+// human readable layout of the deletion date shown to users
+const DeletionDateFormat = "Monday, January 2, 2006 at 3:04 PM MST"
+
 // given a pvc, this function will aquire the details related to the pvc such as the owner of the pvc, their email, the bounded volume name and ID, and details about its deletion
 
-func EmailDetails(kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim, daysLeft float64) (string, structInternal.Personalisation) {
-	ns := pvc.Namespace
+func EmailDetails(ctx context.Context, kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim, detachedAt time.Time, cfg structInternal.SchedulerConfig) (string, structInternal.Personalisation) {
+	size, storageClass := VolumeDetails(ctx, kube, pvc)
+
+	return volumeEmailDetails(ctx, kube, pvc.Namespace, pvc.Name, size, storageClass, detachedAt, cfg)
+}
+
+// same as EmailDetails for a released pv, which is named after the claim it was bound to
+// since that is the name the owner knows
 
+func PvEmailDetails(ctx context.Context, kube kubernetes.Interface, pv corev1.PersistentVolume, detachedAt time.Time, cfg structInternal.SchedulerConfig) (string, structInternal.Personalisation) {
+	ns, name := "", pv.Name
+	if pv.Spec.ClaimRef != nil {
+		ns, name = pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name
+	}
+
+	return volumeEmailDetails(ctx, kube, ns, name, pv.Spec.Capacity[corev1.ResourceStorage], pv.Spec.StorageClassName, detachedAt, cfg)
+}
+
+func volumeEmailDetails(ctx context.Context, kube kubernetes.Interface, ns string, name string, size resource.Quantity, storageClass string, detachedAt time.Time, cfg structInternal.SchedulerConfig) (string, structInternal.Personalisation) {
 	// Acquire User Email
-	email := nsEmail(kube, ns)
+	email := nsEmail(ctx, kube, ns)
 
 	// Calculate DeletionDate
-	now := time.Now()
-	futureTime := now.Add(time.Duration(daysLeft) * 24 * time.Hour)
+	deletionDate := DeletionDate(detachedAt, cfg)
 
 	personal := structInternal.Personalisation{
 		Name:         ns,
-		VolumeName:   pvc.Name,
-		DaysLeft:     fmt.Sprintf("%f", daysLeft),
-		DeletionDate: futureTime.Format(time.UnixDate),
+		VolumeName:   name,
+		DaysLeft:     strconv.Itoa(DaysUntil(cfg.Clock.Now(), deletionDate, cfg.Calendar.Zone())),
+		DeletionDate: deletionDate.In(cfg.Calendar.Zone()).Format(DeletionDateFormat),
+		Size:         size.String(),
+		StorageClass: storageClass,
+	}
+
+	if cost, ok := cfg.Prices.MonthlyCost(storageClass, size.Value()); ok {
+		personal.MonthlyCost = cfg.Prices.Format(cost)
+	}
+	if cfg.Prices.IsSet() {
+		personal.NamespaceMonthlyCost = cfg.Prices.Format(NamespaceMonthlyCost(ctx, kube, ns, cfg))
 	}
 
 	return email, personal
 }
 
+// returns when a pvc detached at detachedAt will actually be deleted: the first scheduler
+// run after its grace period ends that falls on a business day
+
+func DeletionDate(detachedAt time.Time, cfg structInternal.SchedulerConfig) time.Time {
+	deadline := cfg.Calendar.Deadline(detachedAt, cfg.GracePeriod)
+
+	if !cfg.Schedule.IsSet() {
+		return deadline
+	}
+
+	// a run on a weekend or holiday defers the deletion, so the search moves on to the first run
+	// of the next day. bounded by a year so that a schedule that never lands on a business day
+	// cannot loop forever, the deadline is shown then
+	run := cfg.Schedule.Next(deadline)
+	for i := 0; i < 366; i++ {
+		if cfg.Calendar.IsBusinessDay(run) {
+			return run
+		}
+		local := run.In(cfg.Calendar.Zone())
+		nextDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location())
+		run = cfg.Schedule.Next(nextDay.Add(-time.Nanosecond))
+	}
+
+	return deadline
+}
+
+// returns the number of calendar days between the dates of now and then in the given location
+// e.g. 11pm today and 1am tomorrow are one day apart
+
+func DaysUntil(now time.Time, then time.Time, loc *time.Location) int {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	then = then.In(loc)
+
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(then.Year(), then.Month(), then.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := int(to.Sub(from).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
 // returns the email associated with a namespace
 
-func nsEmail(kube kubernetes.Interface, name string) string {
-	ns, err := kube.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+func nsEmail(ctx context.Context, kube kubernetes.Interface, name string) string {
+	callCtx, cancel := CallContext(ctx)
+	defer cancel()
+	ns, err := kube.CoreV1().Namespaces().Get(callCtx, name, metav1.GetOptions{})
 	if err != nil {
 		log.Printf("[ERROR] Failed to get namespace %s: %v", name, err)
 	}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	// standard packages
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	// external packages
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
@@ -39,7 +41,7 @@ func TestSendingNotif(t *testing.T) {
 	}
 
 	// sending email!
-	err := SendNotif(client, configInvalid, email, personal)
+	err := SendNotif(context.TODO(), client, configInvalid, email, personal)
 
 	log.Printf("Status: %t", err)
 
@@ -73,13 +75,20 @@ func TestEmailDetails(t *testing.T) {
 					Namespace: "test-namespace",
 				},
 				Spec: corev1.PersistentVolumeClaimSpec{
-					VolumeName: "pv-test-volume-123",
+					VolumeName:       "pv-test-volume-123",
+					StorageClassName: strPtr("standard"),
+				},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
 				},
 			},
 			expectedEmail: "test@example.com",
 			expectedPersonalisation: structInternal.Personalisation{
-				Name:       "test-namespace",
-				VolumeName: "test-pvc",
+				Name:         "test-namespace",
+				VolumeName:   "test-pvc",
+				Size:         "10Gi",
+				StorageClass: "standard",
+				MonthlyCost:  "0.50 CAD",
 			},
 		},
 		{
@@ -105,6 +114,7 @@ func TestEmailDetails(t *testing.T) {
 			expectedPersonalisation: structInternal.Personalisation{
 				Name:       "no-owner-ns",
 				VolumeName: "test-pvc-no-owner",
+				Size:       "0", // unknown without a status or a bound pv
 			},
 		},
 		{
@@ -127,12 +137,21 @@ func TestEmailDetails(t *testing.T) {
 		},
 	}
 
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	// grace period of 0 days means the pvc is due for deletion right away
+	cfg := structInternal.SchedulerConfig{
+		GracePeriod: 0,
+		Clock:       NewFakeClock(now),
+		Prices:      structInternal.PriceTable{Prices: map[string]float64{"standard": 0.05}, Currency: "CAD"},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.namespace != nil {
 				kubeClient := fake.NewClientset(tt.namespace)
 
-				email, personal := EmailDetails(kubeClient, tt.pvc, 0.0)
+				email, personal := EmailDetails(context.TODO(), kubeClient, tt.pvc, now, cfg)
 
 				// Assert the email
 				assert.Equal(t, tt.expectedEmail, email, "Email should match")
@@ -140,21 +159,139 @@ func TestEmailDetails(t *testing.T) {
 				// Assert the Personalisation struct fields, handling the time dynamically
 				assert.Equal(t, tt.expectedPersonalisation.Name, personal.Name, "Personalisation Name should match")
 				assert.Equal(t, tt.expectedPersonalisation.VolumeName, personal.VolumeName, "Personalisation VolumeName should match")
+				assert.Equal(t, tt.expectedPersonalisation.Size, personal.Size, "Personalisation Size should match")
+				assert.Equal(t, tt.expectedPersonalisation.StorageClass, personal.StorageClass, "Personalisation StorageClass should match")
+				assert.Equal(t, tt.expectedPersonalisation.MonthlyCost, personal.MonthlyCost, "Personalisation MonthlyCost should match")
+
+				// the pvc is not in the fake cluster, so nothing is unattached in the namespace
+				assert.Equal(t, "0.00 CAD", personal.NamespaceMonthlyCost)
 
 				// not that important of a value to test
-				assert.Equal(t, personal.DaysLeft, "0.000000")
+				assert.Equal(t, personal.DaysLeft, "0")
+
+				// no days left means the deletion date is the current (frozen) time
+				assert.Equal(t, "Sunday, June 1, 2025 at 12:00 PM UTC", personal.DeletionDate)
 
 			} else {
 				// For the "Non-existent Namespace" case, create a client without the namespace
 				kubeClient := fake.NewClientset()
-				email, personal := EmailDetails(kubeClient, tt.pvc, 0.0)
+				email, personal := EmailDetails(context.TODO(), kubeClient, tt.pvc, now, cfg)
 
 				assert.Equal(t, tt.expectedEmail, email, "Email should be empty for non-existent namespace")
 				assert.Equal(t, tt.expectedPersonalisation.Name, personal.Name, "Personalisation Name should match for non-existent namespace")
 				assert.Equal(t, tt.expectedPersonalisation.VolumeName, personal.VolumeName, "Personalisation VolumeName should match for non-existent namespace")
 
-				assert.Equal(t, personal.DaysLeft, "0.000000")
+				assert.Equal(t, personal.DaysLeft, "0")
 			}
 		})
 	}
 }
+
+// TestDeletionDate verifies that the deletion date follows the detach time, the grace period and the scheduler runs
+func TestDeletionDate(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skipf("timezone database unavailable: %s", err)
+	}
+
+	detachedAt := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cfg := structInternal.SchedulerConfig{
+		GracePeriod: 5,
+		Clock:       NewFakeClock(time.Date(2025, time.June, 3, 12, 0, 0, 0, time.UTC)),
+		Calendar:    structInternal.Calendar{Location: toronto},
+	}
+
+	t.Run("without a schedule the deadline is used", func(t *testing.T) {
+		assert.Equal(t, time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC), DeletionDate(detachedAt, cfg))
+	})
+
+	cfg.Schedule, err = ParseSchedule("0 0 * * *")
+	assert.NoError(t, err)
+
+	t.Run("rounded up to the next scheduler run", func(t *testing.T) {
+		assert.Equal(t, time.Date(2025, time.June, 7, 0, 0, 0, 0, time.UTC), DeletionDate(detachedAt, cfg))
+	})
+
+	t.Run("rendered in the configured timezone", func(t *testing.T) {
+		kubeClient := fake.NewClientset()
+		pvc := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "ns"}}
+
+		_, personal := EmailDetails(context.TODO(), kubeClient, pvc, detachedAt, cfg)
+
+		// midnight UTC is still friday evening in toronto
+		assert.Equal(t, "Friday, June 6, 2025 at 8:00 PM EDT", personal.DeletionDate)
+		assert.Equal(t, "3", personal.DaysLeft)
+	})
+
+	t.Run("runs on non business days are skipped", func(t *testing.T) {
+		business := cfg
+		business.Calendar = structInternal.Calendar{BusinessDays: true, Location: time.UTC}
+
+		// the deadline (5 business days) is fri june 6 at noon, the weekend runs are skipped
+		assert.Equal(t, time.Date(2025, time.June, 9, 0, 0, 0, 0, time.UTC), DeletionDate(detachedAt, business))
+
+		// frequent runs over a whole weekend and a holiday monday are skipped too
+		// the deadline is fri june 13 at 23:58, two minutes before the weekend
+		frequent := business
+		frequent.Schedule, err = ParseSchedule("*/5 * * * *")
+		assert.NoError(t, err)
+		frequent.Calendar.Holidays = map[string]struct{}{"2025-06-16": {}}
+		lateFriday := time.Date(2025, time.June, 6, 23, 58, 0, 0, time.UTC)
+		assert.Equal(t, time.Date(2025, time.June, 17, 0, 0, 0, 0, time.UTC), DeletionDate(lateFriday, frequent))
+
+		// in the calendar timezone, saturday midnight in utc is still a friday evening in toronto
+		business.Calendar = structInternal.Calendar{BusinessDays: true, Location: toronto}
+		business.Schedule, err = ParseSchedule("0 0 * * 6")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2025, time.June, 7, 0, 0, 0, 0, time.UTC), DeletionDate(detachedAt, business))
+
+		// a weekly run on saturdays never lands on a business day in utc, so the search gives up
+		// instead of looping forever and shows the deadline
+		business.Calendar = structInternal.Calendar{BusinessDays: true, Location: time.UTC}
+		assert.Equal(t, time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC), DeletionDate(detachedAt, business))
+	})
+}
+
+func TestDaysUntil(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 23, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 0, DaysUntil(now, now.Add(30*time.Minute), time.UTC))
+	assert.Equal(t, 1, DaysUntil(now, now.Add(2*time.Hour), time.UTC))
+	assert.Equal(t, 0, DaysUntil(now, now.Add(-48*time.Hour), time.UTC))
+	assert.Equal(t, 1, DaysUntil(now, now.Add(2*time.Hour), nil))
+}
+
+func TestPvEmailDetails(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-namespace",
+			Annotations: map[string]string{"owner": "test@example.com"},
+		},
+	}
+	kubeClient := fake.NewClientset(namespace)
+
+	pv := corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-11cabba3"},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			StorageClassName: "standard",
+			ClaimRef:         &corev1.ObjectReference{Namespace: "test-namespace", Name: "test-pvc"},
+		},
+	}
+
+	cfg := structInternal.SchedulerConfig{
+		GracePeriod: 5,
+		Clock:       NewFakeClock(time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)),
+	}
+
+	email, personal := PvEmailDetails(context.TODO(), kubeClient, pv, time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC), cfg)
+
+	// the owner knows the volume by the name of its former claim
+	assert.Equal(t, "test@example.com", email)
+	assert.Equal(t, "test-namespace", personal.Name)
+	assert.Equal(t, "test-pvc", personal.VolumeName)
+	assert.Equal(t, "10Gi", personal.Size)
+	assert.Equal(t, "standard", personal.StorageClass)
+	assert.Equal(t, "5", personal.DaysLeft)
+}
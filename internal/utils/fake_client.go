@@ -9,7 +9,9 @@ import (
 	// external packages
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -73,6 +75,96 @@ func (f *FakeClient) CreateStatefulSetWithPvc(ctx context.Context, stsName strin
 	return err
 }
 
+// replaces the PersistentVolumeClaims referenced by an existing StatefulSet.
+func (f *FakeClient) UpdateStatefulSetPvcs(ctx context.Context, stsName string, namespace string, pvcNames ...string) error {
+	sts, err := f.AppsV1().StatefulSets(namespace).Get(ctx, stsName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	volumes := make([]corev1.Volume, 0, len(pvcNames))
+	for _, pvcName := range pvcNames {
+		volumes = append(volumes, corev1.Volume{
+			Name: pvcName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvcName,
+				},
+			},
+		})
+	}
+	sts.Spec.Template.Spec.Volumes = volumes
+
+	_, err = f.AppsV1().StatefulSets(namespace).Update(ctx, sts, metav1.UpdateOptions{})
+	return err
+}
+
+// creates a Pod scheduled on a node that mounts the given PersistentVolumeClaims.
+func (f *FakeClient) CreatePodWithPvc(ctx context.Context, name string, namespace string, phase corev1.PodPhase, pvcNames ...string) (*corev1.Pod, error) {
+	volumes := make([]corev1.Volume, 0, len(pvcNames))
+	for _, pvcName := range pvcNames {
+		volumes = append(volumes, corev1.Volume{
+			Name: pvcName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvcName,
+				},
+			},
+		})
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(namespace + "/" + name)},
+		Spec:       corev1.PodSpec{NodeName: "node", Volumes: volumes},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	_, err := f.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return pod, err
+}
+
+// deletes a Pod by name from the specified namespace.
+func (f *FakeClient) DeletePod(ctx context.Context, name string, namespace string) error {
+	return f.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// creates a PersistentVolume bound to the given PersistentVolumeClaim.
+func (f *FakeClient) CreatePersistentVolume(ctx context.Context, name string, claimNamespace string, claimName string) error {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: claimNamespace, Name: claimName},
+		},
+	}
+	_, err := f.CoreV1().PersistentVolumes().Create(ctx, pv, metav1.CreateOptions{})
+	return err
+}
+
+// creates or updates a VolumeAttachment of a PersistentVolume with the given attached status.
+func (f *FakeClient) SetVolumeAttachment(ctx context.Context, name string, pvName string, attached bool) error {
+	attachment := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			NodeName: "node",
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+		Status: storagev1.VolumeAttachmentStatus{Attached: attached},
+	}
+
+	_, err := f.StorageV1().VolumeAttachments().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		_, err = f.StorageV1().VolumeAttachments().Create(ctx, attachment, metav1.CreateOptions{})
+		return err
+	}
+
+	_, err = f.StorageV1().VolumeAttachments().Update(ctx, attachment, metav1.UpdateOptions{})
+	return err
+}
+
+// deletes a PersistentVolumeClaim by name from the specified namespace.
+func (f *FakeClient) DeletePersistentVolumeClaim(ctx context.Context, name string, namespace string) error {
+	return f.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
 // deletes a StatefulSet by name from the specified namespace.
 func (f FakeClient) DeleteStatefulSet(ctx context.Context, name string, namespace string) error {
 	err := f.AppsV1().StatefulSets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
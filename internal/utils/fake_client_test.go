@@ -7,6 +7,7 @@ import (
 
 	// external packages
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -127,3 +128,100 @@ func TestDeleteStatefulSet(t *testing.T) {
 	_, err = f.AppsV1().StatefulSets(ns).Get(ctx, stsName, metav1.GetOptions{})
 	assert.Error(t, err, "expected error getting deleted StatefulSet")
 }
+
+// TestUpdateStatefulSetPvcs verifies that the volumes of a statefulset can be replaced
+func TestUpdateStatefulSetPvcs(t *testing.T) {
+	ctx := context.TODO()
+	f := NewFakeClient()
+
+	ns := "upd-sts-ns"
+	err := f.CreateNamespace(ctx, ns, nil)
+	assert.NoError(t, err)
+
+	stsName := "to-update-sts"
+	err = f.CreateStatefulSetWithPvc(ctx, stsName, ns, "pvc1")
+	assert.NoError(t, err)
+
+	err = f.UpdateStatefulSetPvcs(ctx, stsName, ns, "pvc2", "pvc3")
+	assert.NoError(t, err, "expected no error updating StatefulSet")
+
+	got, err := f.AppsV1().StatefulSets(ns).Get(ctx, stsName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	vols := got.Spec.Template.Spec.Volumes
+	assert.Len(t, vols, 2)
+	assert.Equal(t, "pvc2", vols[0].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, "pvc3", vols[1].PersistentVolumeClaim.ClaimName)
+
+	// updating a missing statefulset fails
+	err = f.UpdateStatefulSetPvcs(ctx, "missing", ns, "pvc1")
+	assert.Error(t, err)
+}
+
+// TestDeletePersistentVolumeClaim verifies the deletion of a Persistent Volume Claim within a namespace
+func TestDeletePersistentVolumeClaim(t *testing.T) {
+	ctx := context.TODO()
+	f := NewFakeClient()
+
+	ns := "del-pvc-ns"
+	err := f.CreateNamespace(ctx, ns, nil)
+	assert.NoError(t, err)
+
+	_, err = f.CreatePersistentVolumeClaim(ctx, "to-delete-pvc", ns)
+	assert.NoError(t, err)
+
+	err = f.DeletePersistentVolumeClaim(ctx, "to-delete-pvc", ns)
+	assert.NoError(t, err, "expected no error deleting PVC")
+
+	_, err = f.CoreV1().PersistentVolumeClaims(ns).Get(ctx, "to-delete-pvc", metav1.GetOptions{})
+	assert.Error(t, err, "expected error getting deleted PVC")
+}
+
+// TestCreatePodWithPvc verifies the creation and deletion of a pod mounting Persistent Volume Claims
+func TestCreatePodWithPvc(t *testing.T) {
+	ctx := context.TODO()
+	f := NewFakeClient()
+
+	ns := "pod-ns"
+	err := f.CreateNamespace(ctx, ns, nil)
+	assert.NoError(t, err)
+
+	_, err = f.CreatePodWithPvc(ctx, "test-pod", ns, corev1.PodRunning, "pvc1", "pvc2")
+	assert.NoError(t, err, "expected no error creating Pod")
+
+	got, err := f.CoreV1().Pods(ns).Get(ctx, "test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.PodRunning, got.Status.Phase)
+	assert.NotEmpty(t, got.Spec.NodeName)
+	assert.Len(t, got.Spec.Volumes, 2)
+	assert.Equal(t, "pvc2", got.Spec.Volumes[1].PersistentVolumeClaim.ClaimName)
+
+	err = f.DeletePod(ctx, "test-pod", ns)
+	assert.NoError(t, err, "expected no error deleting Pod")
+
+	_, err = f.CoreV1().Pods(ns).Get(ctx, "test-pod", metav1.GetOptions{})
+	assert.Error(t, err, "expected error getting deleted Pod")
+}
+
+// TestSetVolumeAttachment verifies the creation and update of a volume attachment for a bound Persistent Volume
+func TestSetVolumeAttachment(t *testing.T) {
+	ctx := context.TODO()
+	f := NewFakeClient()
+
+	err := f.CreatePersistentVolume(ctx, "pv1", "va-ns", "pvc1")
+	assert.NoError(t, err, "expected no error creating PV")
+
+	pv, err := f.CoreV1().PersistentVolumes().Get(ctx, "pv1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "pvc1", pv.Spec.ClaimRef.Name)
+
+	err = f.SetVolumeAttachment(ctx, "va1", "pv1", true)
+	assert.NoError(t, err, "expected no error creating VolumeAttachment")
+
+	err = f.SetVolumeAttachment(ctx, "va1", "pv1", false)
+	assert.NoError(t, err, "expected no error updating VolumeAttachment")
+
+	got, err := f.StorageV1().VolumeAttachments().Get(ctx, "va1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "pv1", *got.Spec.Source.PersistentVolumeName)
+	assert.False(t, got.Status.Attached)
+}
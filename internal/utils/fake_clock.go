@@ -0,0 +1,47 @@
+package utils
+
+// Abstract out a Fake Clock to be used for testing
+
+import (
+	// standard packages
+	"sync"
+	"time"
+)
+
+// clock that only moves when told to
+// safe to share between the watcher goroutine and the test itself
+type FakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+}
+
+// returns a new instance of FakeClock frozen at the given time
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{current: start}
+}
+
+// returns the frozen time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// returns the time elapsed between t and the frozen time
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// moves the clock forward (or backward if d is negative)
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.current.Add(d)
+}
+
+// jumps the clock to the given time
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = t
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	// standard packages
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFakeClock verifies that the fake clock only moves when told to
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	// frozen at the start time
+	assert.Equal(t, start, clock.Now())
+	assert.Equal(t, time.Duration(0), clock.Since(start))
+
+	// advancing moves both Now and Since
+	clock.Advance(36 * time.Hour)
+	assert.Equal(t, start.Add(36*time.Hour), clock.Now())
+	assert.Equal(t, 36*time.Hour, clock.Since(start))
+
+	// setting jumps directly to the given time
+	later := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock.Set(later)
+	assert.Equal(t, later, clock.Now())
+	assert.Equal(t, later.Sub(start), clock.Since(start))
+}
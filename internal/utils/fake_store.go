@@ -0,0 +1,85 @@
+package utils
+
+// Abstract out a Fake Object Store to be used for testing
+
+import (
+	// standard packages
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// stands in for an s3 compatible store (e.g. minio) behind an httptest server
+// presigned PUTs and GETs are checked against the keys of cfg, objects are kept by path (/<bucket>/<key>)
+type FakeObjectStore struct {
+	cfg structInternal.ArchiveConfig
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewFakeObjectStore(cfg structInternal.ArchiveConfig) *FakeObjectStore {
+	return &FakeObjectStore{cfg: cfg, objects: make(map[string][]byte)}
+}
+
+// returns an object and whether it exists
+func (s *FakeObjectStore) Object(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[path]
+	return object, ok
+}
+
+// stores an object as if it had been uploaded
+func (s *FakeObjectStore) Put(path string, object []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[path] = object
+}
+
+func (s *FakeObjectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now, err := time.Parse(amzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	expires, _ := time.ParseDuration(query.Get("X-Amz-Expires") + "s")
+
+	// signed again with the keys of the store, any change to the url breaks the signature
+	unsigned := *r.URL
+	unsigned.Host = r.Host
+	expected, _ := url.Parse(presignURL(r.Method, &unsigned, s.cfg.Region, s.cfg.AccessKey, s.cfg.SecretKey, now, expires))
+	if expected.Query().Get("X-Amz-Signature") != query.Get("X-Amz-Signature") {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			http.Error(w, "MissingContentLength", http.StatusLengthRequired)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Put(r.URL.Path, body)
+	case http.MethodGet:
+		object, ok := s.Object(r.URL.Path)
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	// external packages
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// builds the price table from PRICES and CURRENCY
+
+func LoadPriceTable(src ConfigSource) (structInternal.PriceTable, error) {
+	prices, err := ParsePrices(src.Get("PRICES"))
+	return structInternal.PriceTable{Prices: prices, Currency: src.Get("CURRENCY")}, err
+}
+
+// read a comma separated list of storage class prices per GiB-month (e.g. "standard=0.05, premium=0.15")
+// every invalid entry is reported
+
+func ParsePrices(str string) (map[string]float64, error) {
+	prices := make(map[string]float64)
+	var errs []error
+
+	for _, val := range ParseStrList(str) {
+		if val == "" {
+			continue
+		}
+
+		storageClass, amount, found := strings.Cut(val, "=")
+		if !found || storageClass == "" {
+			errs = append(errs, fmt.Errorf("failed to parse price %q: expected <storage class>=<price>", val))
+			continue
+		}
+
+		price, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse price of %s: %w", storageClass, err))
+			continue
+		}
+		if price < 0 {
+			errs = append(errs, fmt.Errorf("failed to parse price of %s: must not be negative", storageClass))
+			continue
+		}
+
+		prices[storageClass] = price
+	}
+
+	return prices, errors.Join(errs...)
+}
+
+// returns the monthly cost of a pvc, false if its storage class has no price
+
+func VolumeCost(ctx context.Context, kube kubernetes.Interface, pvc corev1.PersistentVolumeClaim, prices structInternal.PriceTable) (float64, bool) {
+	size, storageClass := VolumeDetails(ctx, kube, pvc)
+	return prices.MonthlyCost(storageClass, size.Value())
+}
+
+// returns the monthly cost of every unattached pvc (the ones carrying the time label) in a namespace
+// ignored and protected pvcs are left out since they will never be deleted
+
+func NamespaceMonthlyCost(ctx context.Context, kube kubernetes.Interface, ns string, cfg structInternal.SchedulerConfig) float64 {
+	callCtx, cancel := CallContext(ctx)
+	defer cancel()
+	pvcs, err := kube.CoreV1().PersistentVolumeClaims(ns).List(callCtx, metav1.ListOptions{LabelSelector: cfg.TimeLabel})
+	if err != nil {
+		log.Printf("[ERROR] Failed to list volume claims: %s", err)
+		return 0
+	}
+
+	total := 0.0
+	for _, pvc := range pvcs.Items {
+		if cfg.IgnoreLabel != "" && pvc.Labels[cfg.IgnoreLabel] == "true" {
+			continue
+		}
+		if _, protected := cfg.Protection.Protects(pvc.ObjectMeta); protected {
+			continue
+		}
+		cost, _ := VolumeCost(ctx, kube, pvc, cfg.Prices)
+		total += cost
+	}
+
+	return total
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	// standard packages
+	"context"
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+func TestParsePrices(t *testing.T) {
+	prices, err := ParsePrices("standard=0.05, managed-premium = 0.15")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"standard": 0.05, "managed-premium": 0.15}, prices)
+
+	prices, err = ParsePrices("")
+	assert.NoError(t, err)
+	assert.Empty(t, prices)
+
+	// every invalid entry is reported
+	_, err = ParsePrices("standard, premium=cheap, =1, default=-1")
+	assert.ErrorContains(t, err, `"standard"`)
+	assert.ErrorContains(t, err, "price of premium")
+	assert.ErrorContains(t, err, `"=1"`)
+	assert.ErrorContains(t, err, "must not be negative")
+}
+
+// returns an unattached pvc of the given size and storage class
+func pricedPvc(name string, size string, storageClass string, labels map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels},
+		Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: strPtr(storageClass)},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+		},
+	}
+}
+
+func TestNamespaceMonthlyCost(t *testing.T) {
+	unattached := map[string]string{"volume-cleaner/unattached-time": "2025-07-01_00-00-00Z"}
+	ignored := map[string]string{"volume-cleaner/unattached-time": "2025-07-01_00-00-00Z", "volume-cleaner/ignore": "true"}
+
+	kube := fake.NewClientset(
+		pricedPvc("pvc1", "10Gi", "standard", unattached),
+		pricedPvc("pvc2", "20Gi", "premium", unattached),
+		// attached pvcs do not count
+		pricedPvc("pvc3", "100Gi", "standard", nil),
+		// neither do ignored ones
+		pricedPvc("pvc4", "100Gi", "standard", ignored),
+		// nor unpriced storage classes
+		pricedPvc("pvc5", "100Gi", "unknown", unattached),
+	)
+
+	cfg := structInternal.SchedulerConfig{
+		TimeLabel:   "volume-cleaner/unattached-time",
+		IgnoreLabel: "volume-cleaner/ignore",
+		Prices:      structInternal.PriceTable{Prices: map[string]float64{"standard": 0.05, "premium": 0.15}},
+	}
+
+	cost, ok := VolumeCost(context.TODO(), kube, *pricedPvc("pvc2", "20Gi", "premium", nil), cfg.Prices)
+	assert.True(t, ok)
+	assert.InDelta(t, 3.0, cost, 1e-9)
+
+	assert.InDelta(t, 3.5, NamespaceMonthlyCost(context.TODO(), kube, "test", cfg), 1e-9)
+	assert.Equal(t, 0.0, NamespaceMonthlyCost(context.TODO(), kube, "other", cfg))
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	// standard packages
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	// external packages
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// a protection rule as written in PROTECTION_FILE
+
+type protectionFileRule struct {
+	Name        string   `json:"name"`
+	Selector    string   `json:"selector"`
+	NamePattern string   `json:"namePattern"`
+	Annotation  string   `json:"annotation"`
+	OwnerKinds  []string `json:"ownerKinds"`
+}
+
+// reads the protection rules from a yaml or json file (e.g. a mounted configmap)
+// no file means no rules, the configmap may not have been created
+
+func LoadProtection(path string) (structInternal.Protection, error) {
+	if path == "" {
+		return structInternal.Protection{}, nil
+	}
+
+	content, err := readOptionalFile(path)
+	if err != nil {
+		return structInternal.Protection{}, fmt.Errorf("PROTECTION_FILE: failed to read: %w", err)
+	}
+
+	protection, err := ParseProtection(content)
+	if err != nil {
+		return protection, err
+	}
+
+	log.Printf("[INFO] Loaded %d protection rules.", len(protection.Rules))
+	return protection, nil
+}
+
+// parses a list of protection rules, every invalid selector and pattern is reported
+
+func ParseProtection(content []byte) (structInternal.Protection, error) {
+	var fileRules []protectionFileRule
+	if err := yaml.UnmarshalStrict(content, &fileRules); err != nil {
+		return structInternal.Protection{}, fmt.Errorf("PROTECTION_FILE: failed to parse: %w", err)
+	}
+
+	protection := structInternal.Protection{Rules: make([]structInternal.ProtectionRule, 0, len(fileRules))}
+	var errs []error
+
+	for _, fileRule := range fileRules {
+		rule := structInternal.ProtectionRule{
+			Name:       fileRule.Name,
+			OwnerKinds: fileRule.OwnerKinds,
+		}
+
+		if fileRule.Selector != "" {
+			selector, err := labels.Parse(fileRule.Selector)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("PROTECTION_FILE: rule %q: invalid selector: %w", fileRule.Name, err))
+			}
+			rule.Selector = selector
+		}
+
+		if fileRule.NamePattern != "" {
+			pattern, err := regexp.Compile(fileRule.NamePattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("PROTECTION_FILE: rule %q: invalid name pattern: %w", fileRule.Name, err))
+			}
+			rule.NamePattern = pattern
+		}
+
+		// "key" only requires the annotation, "key=value" also its value
+		rule.Annotation, rule.AnnotationValue, _ = strings.Cut(fileRule.Annotation, "=")
+
+		protection.Rules = append(protection.Rules, rule)
+	}
+
+	return protection, errors.Join(errs...)
+}
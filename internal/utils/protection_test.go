@@ -0,0 +1,85 @@
+package utils
+
+import (
+	// standard packages
+	"os"
+	"path/filepath"
+	"testing"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testProtectionFile = `
+- name: databases
+  selector: "app in (postgres, mysql)"
+- name: scratch
+  namePattern: "^scratch-"
+- name: pinned
+  annotation: "example.com/keep=true"
+- name: operators
+  ownerKinds: ["Cluster", "Kafka"]
+`
+
+func TestParseProtection(t *testing.T) {
+	t.Run("every kind of rule", func(t *testing.T) {
+		protection, err := ParseProtection([]byte(testProtectionFile))
+		assert.NoError(t, err)
+		assert.NoError(t, protection.Validate())
+		assert.Len(t, protection.Rules, 4)
+
+		tests := []struct {
+			pvc  metav1.ObjectMeta
+			rule string
+		}{
+			{metav1.ObjectMeta{Name: "data", Labels: map[string]string{"app": "mysql"}}, "databases"},
+			{metav1.ObjectMeta{Name: "scratch-1"}, "scratch"},
+			{metav1.ObjectMeta{Name: "data", Annotations: map[string]string{"example.com/keep": "true"}}, "pinned"},
+			{metav1.ObjectMeta{Name: "data", OwnerReferences: []metav1.OwnerReference{{Kind: "Kafka", Name: "events"}}}, "operators"},
+			{metav1.ObjectMeta{Name: "data", Annotations: map[string]string{"example.com/keep": "false"}}, ""},
+		}
+		for _, tt := range tests {
+			rule, _ := protection.Protects(tt.pvc)
+			assert.Equal(t, tt.rule, rule, tt.pvc.Name)
+		}
+	})
+
+	t.Run("invalid selectors and patterns are reported", func(t *testing.T) {
+		_, err := ParseProtection([]byte(`
+- name: broken-selector
+  selector: "app in postgres"
+- name: broken-pattern
+  namePattern: "scratch-("
+`))
+		assert.ErrorContains(t, err, `rule "broken-selector": invalid selector`)
+		assert.ErrorContains(t, err, `rule "broken-pattern": invalid name pattern`)
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		_, err := ParseProtection([]byte(`[{"name": "pinned", "annotations": "example.com/keep"}]`))
+		assert.ErrorContains(t, err, "PROTECTION_FILE")
+	})
+
+	t.Run("rules are read from the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(testProtectionFile), 0o644); err != nil {
+			t.Fatalf("Error writing protection file: %v", err)
+		}
+
+		protection, err := LoadProtection(path)
+		assert.NoError(t, err)
+		assert.Len(t, protection.Rules, 4)
+
+		protection, err = LoadProtection("")
+		assert.NoError(t, err)
+		assert.Empty(t, protection.Rules)
+
+		protection, err = LoadProtection(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.NoError(t, err)
+		assert.Empty(t, protection.Rules)
+
+		_, err = LoadProtection(t.TempDir())
+		assert.ErrorContains(t, err, "PROTECTION_FILE: failed to read")
+	})
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	// standard packages
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	// external packages
+	"golang.org/x/time/rate"
+)
+
+// read the number of workers provided in the config, an empty value means a single worker
+
+func ParseConcurrency(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+
+	workers, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse concurrency: %w", err)
+	} else if workers < 1 {
+		return 0, errors.New("concurrency must be at least one worker")
+	}
+	return workers, nil
+}
+
+// read a rate in requests per second provided in the config, an empty value means no limit
+
+func ParseRate(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	qps, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse rate: %w", err)
+	} else if qps < 0 || math.IsInf(qps, 0) || math.IsNaN(qps) {
+		return 0, fmt.Errorf("rate must be a positive number of requests per second, got %s", value)
+	}
+	return qps, nil
+}
+
+// returns the burst allowed along with a rate, a second worth of requests and at least one
+
+func RateBurst(qps float64) int {
+	return max(1, int(math.Ceil(qps)))
+}
+
+// http transport that waits for the limiter before every request
+
+type rateLimitedTransport struct {
+	limiter *rate.Limiter
+	base    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(request)
+}
+
+// returns an http client sending at most qps requests per second, shared by every worker
+// a qps of 0 does not limit
+
+func NewRateLimitedClient(timeout time.Duration, qps float64) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if qps > 0 {
+		client.Transport = &rateLimitedTransport{
+			limiter: rate.NewLimiter(rate.Limit(qps), RateBurst(qps)),
+			base:    http.DefaultTransport,
+		}
+	}
+	return client
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	// standard packages
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConcurrency(t *testing.T) {
+	workers, err := ParseConcurrency("")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, workers)
+
+	workers, err = ParseConcurrency("8")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, workers)
+
+	_, err = ParseConcurrency("0")
+	assert.Error(t, err)
+
+	_, err = ParseConcurrency("many")
+	assert.Error(t, err)
+}
+
+func TestParseRate(t *testing.T) {
+	qps, err := ParseRate("")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, qps)
+
+	qps, err = ParseRate("2.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, qps)
+
+	_, err = ParseRate("-1")
+	assert.Error(t, err)
+
+	_, err = ParseRate("fast")
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, RateBurst(0.5))
+	assert.Equal(t, 3, RateBurst(2.5))
+}
+
+func TestNewRateLimitedClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	send := func(client *http.Client, requests int) time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				response, err := client.Get(server.URL)
+				if assert.NoError(t, err) {
+					response.Body.Close()
+				}
+			}()
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	t.Run("requests are spread out", func(t *testing.T) {
+		// a burst of 20, then one request every 50ms
+		elapsed := send(NewRateLimitedClient(time.Second, 20), 25)
+		assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		client := NewRateLimitedClient(time.Second, 0)
+		assert.Nil(t, client.Transport)
+		assert.Less(t, send(client, 25), time.Second)
+	})
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	// standard packages
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+/*
+Archives are uploaded with presigned urls (AWS signature version 4, query parameters) so that the
+jobs reading the pvcs never see the archive keys. A presigned url only allows a single request on
+a single object until it expires. Objects are addressed path style, <endpoint>/<bucket>/<key>,
+which every s3 compatible store (minio, ceph, aws) understands.
+*/
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	amzDayFormat    = "20060102"
+	amzAlgorithm    = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// returns the object key of an archive of a pvc taken at now, e.g. team-a/data/20250715T000000Z.tar.gz
+
+func ArchiveKey(namespace string, name string, now time.Time) string {
+	return fmt.Sprintf("%s/%s/%s.tar.gz", namespace, name, now.UTC().Format(amzDateFormat))
+}
+
+// returns the key of the manifest stored next to an archive, e.g. team-a/data/20250715T000000Z.json
+
+func ManifestKey(archiveKey string) string {
+	return strings.TrimSuffix(archiveKey, ".tar.gz") + ".json"
+}
+
+// returns the key of an archive given its url, as recorded in events and reports, or its key
+
+func ArchiveKeyFromReference(cfg structInternal.ArchiveConfig, reference string) (string, error) {
+	bucket, err := ObjectURL(cfg, "")
+	if err != nil {
+		return "", err
+	}
+
+	key := reference
+	if strings.Contains(reference, "://") {
+		found := false
+		if key, found = strings.CutPrefix(reference, strings.TrimSuffix(bucket.String(), "/")+"/"); !found {
+			return "", fmt.Errorf("%s is not in bucket %s of %s", reference, cfg.Bucket, cfg.Endpoint)
+		}
+	}
+
+	if !strings.HasSuffix(key, ".tar.gz") || strings.Count(key, "/") != 2 {
+		return "", fmt.Errorf("%q is not an archive of volume-cleaner, expected <namespace>/<pvc>/<time>.tar.gz", reference)
+	}
+	return key, nil
+}
+
+// returns the url of an object, without any signature
+
+func ObjectURL(cfg structInternal.ArchiveConfig, key string) (*url.URL, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive endpoint: %w", err)
+	}
+	// the path is signed, so it has to start with a slash even for an endpoint without one
+	object := endpoint.JoinPath(cfg.Bucket, key)
+	if !strings.HasPrefix(object.Path, "/") {
+		object.Path = "/" + object.Path
+		object.RawPath = ""
+	}
+	return object, nil
+}
+
+// returns a url allowing a single PUT of the object until now + expires
+
+func PresignPut(cfg structInternal.ArchiveConfig, key string, now time.Time, expires time.Duration) (string, error) {
+	object, err := ObjectURL(cfg, key)
+	if err != nil {
+		return "", err
+	}
+	return presignURL("PUT", object, cfg.Region, cfg.AccessKey, cfg.SecretKey, now, expires), nil
+}
+
+// returns a url allowing a single GET of the object until now + expires
+
+func PresignGet(cfg structInternal.ArchiveConfig, key string, now time.Time, expires time.Duration) (string, error) {
+	object, err := ObjectURL(cfg, key)
+	if err != nil {
+		return "", err
+	}
+	return presignURL("GET", object, cfg.Region, cfg.AccessKey, cfg.SecretKey, now, expires), nil
+}
+
+// signs a request on u with the query parameters of signature version 4
+// only the host header is signed and the payload is left unsigned, so the url can be handed to any client
+
+func presignURL(method string, u *url.URL, region string, accessKey string, secretKey string, now time.Time, expires time.Duration) string {
+	now = now.UTC()
+	scope := strings.Join([]string{now.Format(amzDayFormat), region, "s3", "aws4_request"}, "/")
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     amzAlgorithm,
+		"X-Amz-Credential":    accessKey + "/" + scope,
+		"X-Amz-Date":          now.Format(amzDateFormat),
+		"X-Amz-Expires":       strconv.Itoa(int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	canonicalQuery := canonicalQueryString(query)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		awsEscape(u.EscapedPath(), false),
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		amzAlgorithm,
+		now.Format(amzDateFormat),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), now.Format(amzDayFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+// returns the query parameters sorted by name and escaped the way signature version 4 expects
+
+func canonicalQueryString(query map[string]string) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, awsEscape(name, true)+"="+awsEscape(query[name], true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escapes every byte outside of A-Z, a-z, 0-9, '-', '.', '_' and '~'
+// an already escaped path is left as is, the % of its escapes is kept
+
+func awsEscape(value string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && !encodeSlash, c == '%' && !encodeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	// standard packages
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	// internal packages
+	structInternal "volume-cleaner/internal/structure"
+)
+
+// read a standard five field cron expression (minute hour day month weekday)
+// supports *, single values, ranges (1-5), lists (1,15) and steps (*/15, 0-30/10)
+
+func ParseSchedule(str string) (structInternal.Schedule, error) {
+	if strings.TrimSpace(str) == "" {
+		return structInternal.Schedule{}, nil
+	}
+
+	fields := strings.Fields(str)
+	if len(fields) != 5 {
+		return structInternal.Schedule{}, fmt.Errorf("failed to parse schedule %q: expected 5 fields, got %d", str, len(fields))
+	}
+
+	minutes, minuteErr := parseCronField(fields[0], 0, 59)
+	hours, hourErr := parseCronField(fields[1], 0, 23)
+	days, dayErr := parseCronField(fields[2], 1, 31)
+	months, monthErr := parseCronField(fields[3], 1, 12)
+	weekdays, weekdayErr := parseCronField(fields[4], 0, 7)
+
+	if err := errors.Join(minuteErr, hourErr, dayErr, monthErr, weekdayErr); err != nil {
+		return structInternal.Schedule{}, err
+	}
+
+	schedule := structInternal.Schedule{
+		Minutes:            minutes,
+		Hours:              hours,
+		Days:               days,
+		Months:             months,
+		Weekdays:           weekdays,
+		DaysRestricted:     fields[2] != "*",
+		WeekdaysRestricted: fields[4] != "*",
+	}
+
+	// both 0 and 7 mean sunday
+	if _, ok := schedule.Weekdays[7]; ok {
+		schedule.Weekdays[0] = struct{}{}
+	}
+
+	return schedule, nil
+}
+
+// expands a single cron field into the set of values it allows
+
+func parseCronField(field string, lower int, upper int) (map[int]struct{}, error) {
+	values := make(map[int]struct{})
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			parsed, err := strconv.Atoi(stepPart)
+			if err != nil || parsed < 1 {
+				return nil, fmt.Errorf("failed to parse schedule step %q", part)
+			}
+			step = parsed
+		}
+
+		low, high := lower, upper
+		if rangePart != "*" {
+			lowStr, highStr, isRange := strings.Cut(rangePart, "-")
+
+			parsed, err := strconv.Atoi(lowStr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse schedule value %q", part)
+			}
+			low, high = parsed, parsed
+
+			if isRange {
+				parsed, err = strconv.Atoi(highStr)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse schedule value %q", part)
+				}
+				high = parsed
+			} else if hasStep {
+				// "5/15" means every 15 starting at 5
+				high = upper
+			}
+		}
+
+		if low < lower || high > upper || low > high {
+			return nil, fmt.Errorf("schedule value %q is out of range %d-%d", part, lower, upper)
+		}
+
+		for v := low; v <= high; v += step {
+			values[v] = struct{}{}
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	// standard packages
+	"testing"
+	"time"
+
+	// external packages
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	t.Run("empty schedule", func(t *testing.T) {
+		schedule, err := ParseSchedule("")
+		assert.NoError(t, err)
+		assert.False(t, schedule.IsSet())
+
+		now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+		assert.Equal(t, now, schedule.Next(now))
+	})
+
+	t.Run("field expansion", func(t *testing.T) {
+		schedule, err := ParseSchedule("*/20 1-3 1,15 * 7")
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[int]struct{}{0: {}, 20: {}, 40: {}}, schedule.Minutes)
+		assert.Equal(t, map[int]struct{}{1: {}, 2: {}, 3: {}}, schedule.Hours)
+		assert.Equal(t, map[int]struct{}{1: {}, 15: {}}, schedule.Days)
+		assert.Len(t, schedule.Months, 12)
+		assert.Equal(t, map[int]struct{}{0: {}, 7: {}}, schedule.Weekdays)
+		assert.True(t, schedule.DaysRestricted)
+		assert.True(t, schedule.WeekdaysRestricted)
+	})
+
+	t.Run("next run", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			schedule string
+			from     time.Time
+			expected time.Time
+		}{
+			{
+				name:     "daily at midnight",
+				schedule: "0 0 * * *",
+				from:     time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC),
+				expected: time.Date(2025, time.June, 2, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				name:     "strictly after",
+				schedule: "0 0 * * *",
+				from:     time.Date(2025, time.June, 2, 0, 0, 0, 0, time.UTC),
+				expected: time.Date(2025, time.June, 3, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				name:     "every 15 minutes",
+				schedule: "*/15 * * * *",
+				from:     time.Date(2025, time.June, 1, 12, 7, 30, 0, time.UTC),
+				expected: time.Date(2025, time.June, 1, 12, 15, 0, 0, time.UTC),
+			},
+			{
+				name:     "weekdays at 6am",
+				schedule: "0 6 * * 1-5",
+				from:     time.Date(2025, time.June, 6, 7, 0, 0, 0, time.UTC), // friday
+				expected: time.Date(2025, time.June, 9, 6, 0, 0, 0, time.UTC),
+			},
+			{
+				name:     "day of month or day of week",
+				schedule: "0 0 10 * 1",
+				from:     time.Date(2025, time.June, 3, 0, 0, 0, 0, time.UTC), // tuesday
+				expected: time.Date(2025, time.June, 9, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				name:     "converted to utc",
+				schedule: "30 4 * * *",
+				from:     time.Date(2025, time.June, 1, 0, 0, 0, 0, time.FixedZone("EDT", -4*3600)),
+				expected: time.Date(2025, time.June, 1, 4, 30, 0, 0, time.UTC),
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				schedule, err := ParseSchedule(tt.schedule)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, schedule.Next(tt.from))
+			})
+		}
+	})
+
+	t.Run("invalid schedules", func(t *testing.T) {
+		invalid := []string{"0 0 * *", "60 0 * * *", "0 0 0 * *", "*/0 * * * *", "a b c d e", "0 5-1 * * *"}
+
+		for _, input := range invalid {
+			_, err := ParseSchedule(input)
+			assert.Error(t, err, "for input: %q", input)
+		}
+	})
+}
@@ -2,21 +2,22 @@ package utils
 
 import (
 	// standard Packages
-
-	"log"
+	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // read list of times provided in the config and convert to a list of ints
 // each values represented a number of days left
 
-func ParseNotifTimes(str string) []int {
+func ParseNotifTimes(str string) ([]int, error) {
 	var intSlice []int
 
 	if str == "" {
-		return []int{}
+		return []int{}, nil
 	}
 
 	// use fields() and join() to get rid of all whitespace
@@ -28,28 +29,42 @@ func ParseNotifTimes(str string) []int {
 	for _, val := range parsedString {
 		converted, err := strconv.Atoi(val)
 		if err != nil {
-			log.Fatalf("[ERROR] Failed to parse notification time: %s", err)
+			return nil, fmt.Errorf("failed to parse notification time: %w", err)
 		}
 		intSlice = append(intSlice, converted)
 	}
 
 	sort.Sort(sort.Reverse(sort.IntSlice(intSlice)))
 
-	return intSlice
+	return intSlice, nil
 }
 
 // read grace period value provided in the config and convert it to an int
 
-func ParseGracePeriod(value string) int {
+func ParseGracePeriod(value string) (int, error) {
 	// Atoi means ASCII to Integer
 
 	days, err := strconv.Atoi(value)
 	if err != nil {
-		log.Fatalf("[ERROR] Failed to parse grace period value: %s", err)
+		return 0, fmt.Errorf("failed to parse grace period value: %w", err)
 	} else if days < 1 {
-		log.Fatal("[ERROR] For safety reasons, grace period cannot be lower than one day.")
+		return 0, errors.New("for safety reasons, grace period cannot be lower than one day")
+	}
+	return days, nil
+}
+
+// read a duration (e.g. "30m", "6h") provided in the config, an empty value means disabled
+
+func ParseInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse interval: %w", err)
 	}
-	return days
+	return interval, nil
 }
 
 func ParseStrList(str string) []string {
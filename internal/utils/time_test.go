@@ -3,6 +3,7 @@ package utils
 import (
 	// standard packages
 	"testing"
+	"time"
 
 	// external packages
 	"github.com/stretchr/testify/assert"
@@ -54,10 +55,16 @@ func TestParseNotifTimes(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual := ParseNotifTimes(tt.input)
+			actual, err := ParseNotifTimes(tt.input)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.expected, actual, "for input: %q", tt.input)
 		})
 	}
+
+	t.Run("invalid value", func(t *testing.T) {
+		_, err := ParseNotifTimes("1, two, 3")
+		assert.Error(t, err)
+	})
 }
 
 // TestParseGracePeriod tests the ParseGracePeriod function.
@@ -76,10 +83,34 @@ func TestParseGracePeriod(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual := ParseGracePeriod(tt.input)
+			actual, err := ParseGracePeriod(tt.input)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.expected, actual, "for input: %q", tt.input)
 		})
 	}
+
+	invalid := []string{"", "thirty", "0", "-5"}
+
+	for _, input := range invalid {
+		t.Run("invalid grace period "+input, func(t *testing.T) {
+			_, err := ParseGracePeriod(input)
+			assert.Error(t, err, "for input: %q", input)
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	interval, err := ParseInterval("6h")
+	assert.NoError(t, err)
+	assert.Equal(t, 6*time.Hour, interval)
+
+	// empty value disables the interval
+	interval, err = ParseInterval("")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval)
+
+	_, err = ParseInterval("daily")
+	assert.Error(t, err)
 }
 
 func TestParseStrList(t *testing.T) {